@@ -259,6 +259,43 @@ func ReadPrivateKey(logger log.Logger, keyFile string) (privateKey ssh.Signer, e
 	return
 }
 
+// ReadCertificateAuthority reads the public key of the authority signing SSH user certificates. The file is either
+// the PEM encoded X.509 certificate of the CA or an OpenSSH public key. Errors reading the file are returned as is.
+func ReadCertificateAuthority(logger log.Logger, filename string) (ssh.PublicKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		logger.Warn("Certificate authority could not be read", "error", err.Error())
+		return nil, err
+	}
+
+	// Read OpenSSH public keys
+	pemBlock, _ := pem.Decode(data)
+	if pemBlock == nil {
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			logger.Warn("Certificate authority could not be parsed", "error", err.Error())
+			return nil, err
+		}
+		return publicKey, nil
+	} else if pemBlock.Type != "CERTIFICATE" {
+		logger.Warn("Certificate authority could not be parsed", "type", pemBlock.Type)
+		return nil, fmt.Errorf("error reading certificate: expected different header")
+	}
+
+	// Convert X.509 certificates
+	cert, err := x509.ParseCertificate(pemBlock.Bytes)
+	if err != nil {
+		logger.Warn("Certificate authority could not be parsed", "error", err.Error())
+		return nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(cert.PublicKey)
+	if err != nil {
+		logger.Warn("Certificate authority could not be converted", "error", err.Error())
+		return nil, err
+	}
+	return publicKey, nil
+}
+
 // CreateCertificate generates a new cert
 func CreateCertificate(logger log.Logger, req *x509.CertificateRequest, key *rsa.PrivateKey, years int, hostList string) ([]byte, error) {
 
@@ -1,11 +1,11 @@
 package commands
 
 import (
-	"crypto/x509"
 	"io/ioutil"
 	"os"
 	"os/signal"
 	"path"
+	"time"
 
 	log "github.com/mgutz/logxi/v1"
 	"github.com/spf13/cobra"
@@ -13,6 +13,9 @@ import (
 	"github.com/subsilent/kappa/auth"
 	"github.com/subsilent/kappa/datamodel"
 	"github.com/subsilent/kappa/ssh"
+	"github.com/subsilent/kappa/ssh/handlers"
+	"github.com/subsilent/kappa/storage"
+	cryptossh "golang.org/x/crypto/ssh"
 )
 
 // ServerCmd is the kappa root command.
@@ -37,6 +40,15 @@ var ServerCmd = &cobra.Command{
 			return
 		}
 
+		// Configure password hashing
+		iterations := viper.GetInt("PasswordIterations")
+		if iterations <= 0 {
+			logger.Error("Invalid password iterations", "iterations", iterations)
+			return
+		}
+		datamodel.DefaultPasswordHasher = datamodel.NewPBKDF2Hasher(iterations)
+		datamodel.RegisterPasswordHasher(datamodel.DefaultPasswordHasher)
+
 		// Connect to database
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -44,9 +56,15 @@ var ServerCmd = &cobra.Command{
 			return
 		}
 
+		// Create log storage engine
+		logDir := path.Join(cwd, viper.GetString("DataPath"), "logs")
+		segmentSize := int64(viper.GetSizeInBytes("SegmentSize"))
+		logger.Info("Opening log storage", "dir", logDir, "segment-size", segmentSize)
+		engine := storage.NewEngine(logDir, storage.Options{SegmentSize: segmentSize})
+
 		file := path.Join(cwd, viper.GetString("DataPath"), "meta.db")
 		logger.Info("Connecting to database", "file", file)
-		system, err := datamodel.NewSystem(file)
+		system, err := datamodel.NewSystem(file, engine)
 		if err != nil {
 			logger.Error("Could not connect to database", "error", err.Error())
 			return
@@ -61,65 +79,73 @@ var ServerCmd = &cobra.Command{
 			return
 		}
 
-		// Get admin certificate
-		adminCertFile := viper.GetString("AdminCert")
-		logger.Info("Reading admin public key", "file", adminCertFile)
-
-		// Read admin certificate
-		cert, err := ioutil.ReadFile(adminCertFile)
-		if err != nil {
-			logger.Error("admin certificate could not be read", "filename", viper.GetString("AdminCert"))
-			return
-		}
-
-		// Add admin cert to key ring
+		// Get user store
 		userStore, err := system.Users()
 		if err != nil {
 			logger.Error("could not get user store", "error", err.Error())
 			return
 		}
 
-		// Create admin account
-		admin, err := userStore.Create("admin")
-		if err != nil {
-			logger.Error("error creating admin account", "error", err.Error())
-			return
-		}
+		// Read admin certificate, which is only required to bootstrap the initial superuser
+		adminCertFile := viper.GetString("AdminCert")
+		cert, certErr := ioutil.ReadFile(adminCertFile)
 
-		// Add admin certificate
-		keyRing := admin.KeyRing()
-		fingerprint, err := keyRing.AddPublicKey(cert)
-		if err != nil {
-			logger.Error("admin certificate could not be added", "error", err.Error())
+		// Bootstrap the initial superuser with the admin certificate unless a superuser exists
+		adminUser := viper.GetString("AdminUser")
+		admin, err := userStore.Bootstrap(adminUser, cert)
+		if err == datamodel.ErrSuperuserExists {
+			logger.Info("Superuser exists, skipping bootstrap", "username", adminUser)
+		} else if certErr != nil {
+			logger.Error("admin certificate could not be read", "filename", adminCertFile, "error", certErr.Error())
 			return
-		}
-		logger.Info("Added admin certificate", "fingerprint", fingerprint)
-
-		// Read root cert
-		rootPem, err := ioutil.ReadFile(viper.GetString("CACert"))
-		if err != nil {
-			logger.Error("root certificate could not be read", "filename", viper.GetString("CACert"))
+		} else if err != nil {
+			logger.Error("error bootstrapping superuser", "username", adminUser, "error", err.Error())
 			return
+		} else {
+			logger.Info("Bootstrapped superuser", "username", admin.Username(), "certificate", adminCertFile)
 		}
 
-		// Create certificate pool
-		roots := x509.NewCertPool()
-		if ok := roots.AppendCertsFromPEM(rootPem); !ok {
-			logger.Error("failed to parse root certificate")
-			return
+		// Read the certificate authority signing SSH user certificates if one is trusted
+		var authorities []cryptossh.PublicKey
+		if caCertFile := viper.GetString("CACert"); caCertFile == "" {
+			logger.Info("Certificate authentication disabled")
+		} else {
+			logger.Info("Reading certificate authority", "file", caCertFile)
+			authority, err := auth.ReadCertificateAuthority(logger, caCertFile)
+			if err != nil {
+				return
+			}
+
+			authorities = append(authorities, authority)
+			logger.Info("Accepting user certificates", "fingerprint", auth.CreateFingerprint(authority.Marshal()),
+				"auto-create-users", viper.GetBool("AutoCreateUsers"))
 		}
 
 		// Setup SSH Server
 		sshLogger := log.NewLogger(writer, "ssh")
-		sshServer, err := ssh.NewSSHServer(sshLogger, system, privateKey, roots)
+		sshServer, err := ssh.NewSSHServer(&ssh.Config{
+			Deadline: time.Second,
+			Handlers: map[string]handlers.SSHHandler{
+				"session": handlers.NewShellHandler(sshLogger, system),
+			},
+			Logger:     sshLogger,
+			Bind:       viper.GetString("SSHListen"),
+			PrivateKey: privateKey,
+			System:     system,
+
+			CertificateAuthorities: authorities,
+			AutoCreateUsers:        viper.GetBool("AutoCreateUsers"),
+
+			PasswordAuth:            viper.GetBool("PasswordAuth"),
+			KeyboardInteractiveAuth: viper.GetBool("KeyboardInteractiveAuth"),
+			MaxAuthFailures:         viper.GetInt("MaxAuthFailures"),
+			LockoutDuration:         viper.GetDuration("LockoutDuration"),
+		})
 		if err != nil {
 			logger.Error("SSH Server could not be configured", "error", err.Error())
 			return
 		}
-
-		// Setup signal structures
-		closer := make(chan bool)
-		sshServer.Run(logger, closer)
+		sshServer.Start()
 
 		// Handle signals
 		sig := make(chan os.Signal, 1)
@@ -133,8 +159,8 @@ var ServerCmd = &cobra.Command{
 
 		// Shut down SSH server
 		logger.Info("Shutting down servers.")
-		sshServer.Wait()
-		<-closer
+		sshServer.Stop()
+		system.Close()
 	},
 }
 
@@ -143,26 +169,42 @@ var serverCmd *cobra.Command
 
 // Command line args
 var (
-	SSHKey     string
-	AdminCert  string
-	CACert     string
-	TLSCert    string
-	TLSKey     string
-	DataPath   string
-	SSHListen  string
-	HTTPListen string
+	SSHKey                  string
+	AdminCert               string
+	AdminUser               string
+	CACert                  string
+	AutoCreateUsers         bool
+	PasswordAuth            bool
+	KeyboardInteractiveAuth bool
+	MaxAuthFailures         int
+	LockoutDuration         string
+	PasswordIterations      int
+	TLSCert                 string
+	TLSKey                  string
+	DataPath                string
+	SSHListen               string
+	HTTPListen              string
+	SegmentSize             string
 )
 
 func init() {
 
 	ServerCmd.PersistentFlags().StringVarP(&SSHKey, "ssh-key", "", "", "Private key to identify server with")
 	ServerCmd.PersistentFlags().StringVarP(&AdminCert, "admin-cert", "", "", "Public certificate for admin user")
-	ServerCmd.PersistentFlags().StringVarP(&CACert, "ca-cert", "", "", "Root Certificate")
+	ServerCmd.PersistentFlags().StringVarP(&AdminUser, "admin-user", "", "admin", "Username of the initial superuser")
+	ServerCmd.PersistentFlags().StringVarP(&CACert, "ca-cert", "", "", "Certificate authority trusted to sign SSH user certificates")
+	ServerCmd.PersistentFlags().BoolVarP(&AutoCreateUsers, "auto-create-users", "", false, "Create users logging in with a certificate")
+	ServerCmd.PersistentFlags().BoolVarP(&PasswordAuth, "password-auth", "", false, "Allow logging in with passwords")
+	ServerCmd.PersistentFlags().BoolVarP(&KeyboardInteractiveAuth, "keyboard-interactive-auth", "", false, "Allow logging in with passwords and verification codes")
+	ServerCmd.PersistentFlags().IntVarP(&MaxAuthFailures, "max-auth-failures", "", 5, "Failed password logins before users and addresses are locked out")
+	ServerCmd.PersistentFlags().StringVarP(&LockoutDuration, "lockout-duration", "", "15m", "Duration of lockouts after failed password logins")
+	ServerCmd.PersistentFlags().IntVarP(&PasswordIterations, "password-iterations", "", datamodel.DefaultPBKDF2Iterations, "PBKDF2 iterations of new password hashes")
 	ServerCmd.PersistentFlags().StringVarP(&TLSCert, "tls-cert", "", "", "TLS certificate file")
 	ServerCmd.PersistentFlags().StringVarP(&TLSKey, "tls-key", "", "", "TLS private key file")
 	ServerCmd.PersistentFlags().StringVarP(&DataPath, "data", "D", "", "Data directory")
 	ServerCmd.PersistentFlags().StringVarP(&SSHListen, "ssh-listen", "S", "", "Host and port for SSH server to listen on")
 	ServerCmd.PersistentFlags().StringVarP(&HTTPListen, "http-listen", "H", ":", "Host and port for HTTP server to listen on")
+	ServerCmd.PersistentFlags().StringVarP(&SegmentSize, "segment-size", "", "64MB", "Size at which log segments are rolled")
 	serverCmd = ServerCmd
 }
 
@@ -171,23 +213,59 @@ func InitializeServerConfig(logger log.Logger) error {
 
 	// Load default settings
 	logger.Info("Loading default server settings")
-	viper.SetDefault("CACert", "ca.crt")
+	viper.SetDefault("CACert", "")
 	viper.SetDefault("AdminCert", "admin.crt")
+	viper.SetDefault("AdminUser", "admin")
+	viper.SetDefault("AutoCreateUsers", false)
+	viper.SetDefault("PasswordAuth", false)
+	viper.SetDefault("KeyboardInteractiveAuth", false)
+	viper.SetDefault("MaxAuthFailures", 5)
+	viper.SetDefault("LockoutDuration", "15m")
+	viper.SetDefault("PasswordIterations", datamodel.DefaultPBKDF2Iterations)
 	viper.SetDefault("SSHKey", "ssh-identity.key")
 	viper.SetDefault("TLSCert", "tls-identity.crt")
 	viper.SetDefault("TLSKey", "tls-identity.key")
 	viper.SetDefault("DataPath", "./data")
 	viper.SetDefault("SSHListen", ":9022")
 	viper.SetDefault("HTTPListen", ":19022")
+	viper.SetDefault("SegmentSize", "64MB")
 
 	if serverCmd.PersistentFlags().Lookup("ca-cert").Changed {
 		logger.Info("", "CACert", CACert)
 		viper.Set("CACert", CACert)
 	}
+	if serverCmd.PersistentFlags().Lookup("auto-create-users").Changed {
+		logger.Info("", "AutoCreateUsers", AutoCreateUsers)
+		viper.Set("AutoCreateUsers", AutoCreateUsers)
+	}
+	if serverCmd.PersistentFlags().Lookup("password-auth").Changed {
+		logger.Info("", "PasswordAuth", PasswordAuth)
+		viper.Set("PasswordAuth", PasswordAuth)
+	}
+	if serverCmd.PersistentFlags().Lookup("keyboard-interactive-auth").Changed {
+		logger.Info("", "KeyboardInteractiveAuth", KeyboardInteractiveAuth)
+		viper.Set("KeyboardInteractiveAuth", KeyboardInteractiveAuth)
+	}
+	if serverCmd.PersistentFlags().Lookup("max-auth-failures").Changed {
+		logger.Info("", "MaxAuthFailures", MaxAuthFailures)
+		viper.Set("MaxAuthFailures", MaxAuthFailures)
+	}
+	if serverCmd.PersistentFlags().Lookup("lockout-duration").Changed {
+		logger.Info("", "LockoutDuration", LockoutDuration)
+		viper.Set("LockoutDuration", LockoutDuration)
+	}
+	if serverCmd.PersistentFlags().Lookup("password-iterations").Changed {
+		logger.Info("", "PasswordIterations", PasswordIterations)
+		viper.Set("PasswordIterations", PasswordIterations)
+	}
 	if serverCmd.PersistentFlags().Lookup("admin-cert").Changed {
 		logger.Info("", "AdminCert", AdminCert)
 		viper.Set("AdminCert", AdminCert)
 	}
+	if serverCmd.PersistentFlags().Lookup("admin-user").Changed {
+		logger.Info("", "AdminUser", AdminUser)
+		viper.Set("AdminUser", AdminUser)
+	}
 	if serverCmd.PersistentFlags().Lookup("ssh-key").Changed {
 		logger.Info("", "SSHKey", SSHKey)
 		viper.Set("SSHKey", SSHKey)
@@ -212,6 +290,10 @@ func InitializeServerConfig(logger log.Logger) error {
 		logger.Info("", "DataPath", DataPath)
 		viper.Set("DataPath", DataPath)
 	}
+	if serverCmd.PersistentFlags().Lookup("segment-size").Changed {
+		logger.Info("", "SegmentSize", SegmentSize)
+		viper.Set("SegmentSize", SegmentSize)
+	}
 
 	return nil
 }
@@ -7,6 +7,11 @@ const (
 	OK StatusCode = iota + 2000
 	NamespaceAlreadyExists
 	UserAlreadyExists
+	LogAlreadyExists
+	TypeAlreadyExists
+	AlreadySubscribed
+	ViewAlreadyExists
+	RoleAlreadyExists
 )
 
 // Authentication related error codes
@@ -21,6 +26,28 @@ const (
 	NamespaceDoesNotExist
 	UserDoesNotExist
 	CreateNamespaceError
+	NoNamespaceSelected
+	LogDoesNotExist
+	CreateLogError
+	InvalidRecord
+	InsertError
+	TypeDoesNotExist
+	CreateTypeError
+	NotSubscribed
+	ViewDoesNotExist
+	CreateViewError
+	DropNamespaceError
+	NamespaceHasChildren
+	CreateUserError
+	DropUserError
+	UpdateUserError
+	InvalidPublicKey
+	KeyDoesNotExist
+	RoleDoesNotExist
+	UpdateRoleError
+	SyntaxError
+	LastSuperuser
+	NamespaceHasDependents
 )
 
 var statusCodes = map[StatusCode]string{
@@ -29,6 +56,11 @@ var statusCodes = map[StatusCode]string{
 	OK: "OK",
 	NamespaceAlreadyExists: "NamespaceAlreadyExists",
 	UserAlreadyExists:      "UserAlreadyExists",
+	LogAlreadyExists:       "LogAlreadyExists",
+	TypeAlreadyExists:      "TypeAlreadyExists",
+	AlreadySubscribed:      "AlreadySubscribed",
+	ViewAlreadyExists:      "ViewAlreadyExists",
+	RoleAlreadyExists:      "RoleAlreadyExists",
 
 	// Security errors
 	Unauthorized: "Unauthorized",
@@ -39,4 +71,39 @@ var statusCodes = map[StatusCode]string{
 	NamespaceDoesNotExist: "NamespaceDoesNotExist",
 	UserDoesNotExist:      "UserDoesNotExist",
 	CreateNamespaceError:  "CreateNamespaceError",
+	NoNamespaceSelected:   "NoNamespaceSelected",
+	LogDoesNotExist:       "LogDoesNotExist",
+	CreateLogError:        "CreateLogError",
+	InvalidRecord:         "InvalidRecord",
+	InsertError:           "InsertError",
+	TypeDoesNotExist:      "TypeDoesNotExist",
+	CreateTypeError:       "CreateTypeError",
+	NotSubscribed:         "NotSubscribed",
+	ViewDoesNotExist:      "ViewDoesNotExist",
+	CreateViewError:       "CreateViewError",
+	DropNamespaceError:    "DropNamespaceError",
+	NamespaceHasChildren:  "NamespaceHasChildren",
+	CreateUserError:       "CreateUserError",
+	DropUserError:         "DropUserError",
+	UpdateUserError:       "UpdateUserError",
+	InvalidPublicKey:      "InvalidPublicKey",
+	KeyDoesNotExist:       "KeyDoesNotExist",
+	RoleDoesNotExist:      "RoleDoesNotExist",
+	UpdateRoleError:       "UpdateRoleError",
+	SyntaxError:           "SyntaxError",
+	LastSuperuser:         "LastSuperuser",
+	NamespaceHasDependents: "NamespaceHasDependents",
+}
+
+// ExitStatus converts a status code into a process exit status for non-interactive sessions.
+// Success codes exit with 0, security errors with 4 and all other errors with 5.
+func (c StatusCode) ExitStatus() uint32 {
+	switch {
+	case c < Unauthorized:
+		return 0
+	case c < InternalServerError:
+		return 4
+	default:
+		return 5
+	}
 }
@@ -0,0 +1,107 @@
+package common
+
+import "fmt"
+
+// Category groups error codes by the kind of failure
+type Category string
+
+// Error categories
+const (
+	AuthCategory     Category = "auth"
+	ParseCategory    Category = "parse"
+	InvalidCategory  Category = "invalid"
+	NotFoundCategory Category = "not-found"
+	ConflictCategory Category = "conflict"
+	InternalCategory Category = "internal"
+)
+
+// Category returns the category of a status code. Success codes, including those reporting that an object already
+// exists, have no category as they exit with status 0. Codes without a more specific category are internal errors.
+func (c StatusCode) Category() Category {
+	if c < Unauthorized {
+		return ""
+	}
+
+	switch c {
+	case Unauthorized:
+		return AuthCategory
+	case SyntaxError:
+		return ParseCategory
+	case NoNamespaceSelected, InvalidRecord, InvalidPublicKey:
+		return InvalidCategory
+	case NamespaceDoesNotExist, UserDoesNotExist, LogDoesNotExist, TypeDoesNotExist, ViewDoesNotExist,
+		KeyDoesNotExist, RoleDoesNotExist, NotSubscribed:
+		return NotFoundCategory
+	case NamespaceHasChildren, NamespaceHasDependents, LastSuperuser:
+		return ConflictCategory
+	}
+	return InternalCategory
+}
+
+// Position is the line and character of a statement in a query, starting at 1
+type Position struct {
+	Line int `json:"line"`
+	Char int `json:"char"`
+}
+
+// Error is a failure with a status code. The position and details are optional.
+type Error struct {
+	Code     StatusCode
+	Category Category
+	Message  string
+	Position *Position
+	Details  map[string]interface{}
+}
+
+// NewError creates an error with a formatted message. The category is determined by the status code.
+func NewError(code StatusCode, format string, args ...interface{}) *Error {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	return &Error{Code: code, Category: code.Category(), Message: message}
+}
+
+// Error returns the message and position of the error
+func (e *Error) Error() string {
+	if e.Position != nil {
+		return fmt.Sprintf("%s at line %d, char %d", e.Message, e.Position.Line, e.Position.Char)
+	}
+	return e.Message
+}
+
+// At returns a copy of the error at the given position
+func (e *Error) At(pos Position) *Error {
+	err := *e
+	err.Position = &pos
+	return &err
+}
+
+// With returns a copy of the error with an additional detail
+func (e *Error) With(key string, value interface{}) *Error {
+	err := *e
+	err.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		err.Details[k] = v
+	}
+	err.Details[key] = value
+	return &err
+}
+
+// StatusError is implemented by errors which convert themselves into an Error
+type StatusError interface {
+	StatusError() *Error
+}
+
+// AsError converts an error into an Error. Errors which are neither an Error nor a StatusError are internal errors.
+func AsError(err error) *Error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *Error:
+		return err
+	case StatusError:
+		return err.StatusError()
+	}
+	return NewError(InternalServerError, "%s", err.Error())
+}
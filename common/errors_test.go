@@ -0,0 +1,163 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure error codes have categories and success codes don't
+func TestStatusCodeCategory(t *testing.T) {
+	var tests = []struct {
+		code     StatusCode
+		category Category
+	}{
+		{OK, ""},
+		{Unauthorized, AuthCategory},
+		{SyntaxError, ParseCategory},
+		{NoNamespaceSelected, InvalidCategory},
+		{NamespaceDoesNotExist, NotFoundCategory},
+		{LogAlreadyExists, ""},
+		{AlreadySubscribed, ""},
+		{NamespaceHasChildren, ConflictCategory},
+		{LastSuperuser, ConflictCategory},
+		{InternalServerError, InternalCategory},
+		{CreateLogError, InternalCategory},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.category, tt.code.Category(), "%s", statusName(tt.code))
+	}
+}
+
+// Ensure only codes exiting with a non-zero status have a category
+func TestStatusCodeCategoryExitStatus(t *testing.T) {
+	for code := range statusCodes {
+		assert.Equal(t, code.ExitStatus() == 0, code.Category() == "", "%s", statusName(code))
+	}
+}
+
+// Ensure errors are copied when positions and details are added
+func TestErrorCopies(t *testing.T) {
+	sentinel := NewError(LogDoesNotExist, "log does not exist")
+
+	err := sentinel.At(Position{Line: 2, Char: 5}).With("name", "events")
+	assert.Equal(t, "log does not exist at line 2, char 5", err.Error())
+	assert.Equal(t, map[string]interface{}{"name": "events"}, err.Details)
+	assert.Equal(t, NotFoundCategory, err.Category)
+
+	assert.Nil(t, sentinel.Position)
+	assert.Nil(t, sentinel.Details)
+	assert.Equal(t, "log does not exist", sentinel.Error())
+}
+
+type statusError struct{}
+
+func (statusError) Error() string       { return "bad input" }
+func (statusError) StatusError() *Error { return NewError(InvalidRecord, "bad input") }
+
+// Ensure errors are converted into Errors
+func TestAsError(t *testing.T) {
+	sentinel := NewError(UserDoesNotExist, "user does not exist")
+	assert.True(t, AsError(sentinel) == sentinel)
+	assert.Nil(t, AsError(nil))
+
+	err := AsError(statusError{})
+	assert.Equal(t, InvalidRecord, err.Code)
+	assert.Equal(t, InvalidCategory, err.Category)
+
+	err = AsError(errors.New("disk full"))
+	assert.Equal(t, InternalServerError, err.Code)
+	assert.Equal(t, "disk full", err.Message)
+}
+
+// Ensure failures are rendered with the same structure by every format
+func TestWriteError(t *testing.T) {
+	err := NewError(NamespaceDoesNotExist, "acme").With("namespace", "acme")
+	var tests = []struct {
+		format string
+		output string
+	}{
+		{"", " NamespaceDoesNotExist (5002): acme at line 1, char 10\r\n"},
+		{"json", `{"status":"NamespaceDoesNotExist","code":5002,"category":"not-found","message":"acme",` +
+			`"position":{"line":1,"char":10},"details":{"namespace":"acme"}}` + "\n"},
+		{"csv", `#NamespaceDoesNotExist,5002,acme,not-found,1,10,"{""namespace"":""acme""}"` + "\n"},
+		{"tsv", "#NamespaceDoesNotExist\t5002\tacme\tnot-found\t1\t10\t\"{\"\"namespace\"\":\"\"acme\"\"}\"\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := ResponseWriter{Writer: &buf, Format: tt.format}
+		w.SetPosition(&Position{Line: 1, Char: 10})
+		w.Error(err)
+
+		assert.Equal(t, tt.output, buf.String(), "%q", tt.format)
+		assert.Equal(t, NamespaceDoesNotExist, w.Status())
+		assert.Equal(t, 1, w.Failures())
+	}
+}
+
+// Ensure streamed rows follow a header in formats which have one
+func TestWriteRows(t *testing.T) {
+	var tests = []struct {
+		format string
+		output string
+	}{
+		{"", " id | name  \r\n----+-------\r\n 1  | login \r\n 2  | null  \r\n OK (2000): 2 rows\r\n"},
+		{"json", `{"id":1,"name":"login"}` + "\n" + `{"id":2,"name":null}` + "\n" +
+			`{"status":"OK","code":2000,"message":"2 rows"}` + "\n"},
+		{"csv", "id,name\n1,login\n2,\n#OK,2000,2 rows\n"},
+		{"tsv", "id\tname\n1\tlogin\n2\t\n#OK\t2000\t2 rows\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := ResponseWriter{Writer: &buf, Format: tt.format}
+		columns := []string{"id", "name"}
+		w.WriteHeader(columns)
+		w.WriteRow(columns, []interface{}{1, "login"})
+		w.WriteRow(columns, []interface{}{2, nil})
+		w.Success(OK, "2 rows")
+
+		assert.Equal(t, tt.output, buf.String(), "%q", tt.format)
+	}
+}
+
+// Ensure streamed tables are aligned by display width and written in batches
+func TestWriteRowsTable(t *testing.T) {
+	var buf bytes.Buffer
+	w := ResponseWriter{Writer: &buf}
+	columns := []string{"id", "name"}
+
+	// Tables without rows have a header
+	w.WriteHeader(columns)
+	w.Success(OK, "0 rows")
+	assert.Equal(t, " id | name \r\n----+------\r\n OK (2000): 0 rows\r\n", buf.String())
+
+	// Wide characters take two columns and combining marks none
+	buf.Reset()
+	w.WriteHeader(columns)
+	w.WriteRow(columns, []interface{}{1, "東京"})
+	w.WriteRow(columns, []interface{}{2, "cafe\u0301"})
+	assert.Empty(t, buf.String())
+	w.Success(OK, "2 rows")
+	assert.Equal(t, " id | name \r\n----+------\r\n 1  | 東京 \r\n 2  | cafe\u0301 \r\n OK (2000): 2 rows\r\n", buf.String())
+
+	// Full batches are written as rows are streamed. Later batches keep their widths unless a value is wider.
+	buf.Reset()
+	w.WriteHeader(columns)
+	for i := 0; i < TableBatchSize; i++ {
+		w.WriteRow(columns, []interface{}{i, "login"})
+	}
+	lines := strings.Split(buf.String(), "\r\n")
+	assert.Len(t, lines, TableBatchSize+3)
+	assert.Equal(t, " 99 | login ", lines[TableBatchSize+1])
+
+	buf.Reset()
+	w.WriteRow(columns, []interface{}{100, "logout"})
+	w.Success(OK, "101 rows")
+	assert.Equal(t, " 100 | logout \r\n OK (2000): 101 rows\r\n", buf.String())
+}
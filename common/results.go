@@ -0,0 +1,374 @@
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultFormat is the output format used when none is selected
+const DefaultFormat = "table"
+
+// ResultSet is a set of rows with named columns. Values are strings, numbers, booleans, times or nil.
+type ResultSet struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Renderer writes result sets and status codes in an output format
+type Renderer interface {
+
+	// Results writes a complete result set
+	Results(w io.Writer, colors ColorCodes, rs ResultSet)
+
+	// Header writes the header of a result set which is streamed as single rows, if the format has one
+	Header(w io.Writer, colors ColorCodes, columns []string)
+
+	// Row writes a single row without a header, such as the records of a subscription which are written as they
+	// arrive. The row must be written in a single call so it is not interleaved with other output.
+	Row(w io.Writer, colors ColorCodes, columns []string, values []interface{})
+
+	// Status writes the success status code of a statement and its message
+	Status(w io.Writer, colors ColorCodes, code StatusCode, message string)
+
+	// Error writes the failure of a statement
+	Error(w io.Writer, colors ColorCodes, err *Error)
+}
+
+// Renderers are the available output formats by name
+var Renderers = map[string]Renderer{
+	"table": tableRenderer{},
+	"json":  jsonRenderer{},
+	"csv":   delimitedRenderer{','},
+	"tsv":   delimitedRenderer{'\t'},
+}
+
+// statusName returns the name of a status code
+func statusName(code StatusCode) string {
+	if name, ok := statusCodes[code]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// formatValue formats a value for text output. Strings are written as is and missing values as null.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// TableBatchSize is the number of streamed rows whose columns are aligned together in tables. Later batches keep the
+// widths of earlier ones unless a value is wider. Rows are written once a batch is full or the result set ends.
+const TableBatchSize = 100
+
+// tableRenderer writes result sets as aligned tables for terminals
+type tableRenderer struct{}
+
+func (r tableRenderer) Results(w io.Writer, colors ColorCodes, rs ResultSet) {
+	r.rows(w, colors, rs.Columns, rs.Rows, nil, true)
+}
+
+// Header writes nothing as the rows of tables are buffered by the ResponseWriter and written with rows
+func (tableRenderer) Header(w io.Writer, colors ColorCodes, columns []string) {
+}
+
+func (tableRenderer) Row(w io.Writer, colors ColorCodes, columns []string, values []interface{}) {
+	var buf bytes.Buffer
+	buf.Write(colors.Yellow)
+	for i, column := range columns {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&buf, " %s=%s", column, formatValue(value))
+	}
+	buf.WriteString("\r\n")
+	buf.Write(colors.Reset)
+	w.Write(buf.Bytes())
+}
+
+// rows writes rows as a table with columns at least as wide as the given widths, starting with the header if
+// requested. It returns the widths of the columns so later rows of the same result set can be aligned with them.
+func (tableRenderer) rows(w io.Writer, colors ColorCodes, columns []string, rows [][]interface{}, widths []int,
+	header bool) []int {
+
+	// Columns are as wide as their widest value
+	widths = append(make([]int, 0, len(columns)), widths...)
+	for len(widths) < len(columns) {
+		widths = append(widths, 0)
+	}
+	if header {
+		for i, column := range columns {
+			if width := displayWidth(column); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j := range columns {
+			if j < len(row) {
+				cells[i][j] = formatValue(row[j])
+			} else {
+				cells[i][j] = formatValue(nil)
+			}
+			if width := displayWidth(cells[i][j]); width > widths[j] {
+				widths[j] = width
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(colors.Yellow)
+
+	// Write header
+	if header {
+		var separator []string
+		for i := range columns {
+			separator = append(separator, strings.Repeat("-", widths[i]+2))
+		}
+		buf.WriteString(tableLine(columns, widths) + "\r\n")
+		buf.WriteString(strings.Join(separator, "+") + "\r\n")
+	}
+
+	// Write rows
+	for _, row := range cells {
+		buf.WriteString(tableLine(row, widths) + "\r\n")
+	}
+
+	buf.Write(colors.Reset)
+	w.Write(buf.Bytes())
+	return widths
+}
+
+// tableLine joins the cells of a table line padded to the widths of their columns
+func tableLine(cells []string, widths []int) string {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		padded[i] = " " + cell + strings.Repeat(" ", widths[i]-displayWidth(cell)+1)
+	}
+	return strings.Join(padded, "|")
+}
+
+// displayWidth returns the number of terminal columns a string takes. East Asian wide characters and emoji take two
+// columns, while combining marks and control characters take none.
+func displayWidth(s string) (width int) {
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return
+}
+
+// wideRanges are the ranges of East Asian wide and fullwidth characters and of emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f}, {0x2e80, 0x303e}, {0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe30, 0xfe4f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x1f300, 0x1f64f},
+	{0x1f900, 0x1f9ff}, {0x20000, 0x3fffd},
+}
+
+// isWide determines if a character takes two terminal columns
+func isWide(r rune) bool {
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r tableRenderer) Status(w io.Writer, colors ColorCodes, code StatusCode, message string) {
+	r.status(w, colors.LightGreen, colors.Reset, code, message)
+}
+
+// Details are left out of tables. The position is written after the message.
+func (r tableRenderer) Error(w io.Writer, colors ColorCodes, err *Error) {
+	r.status(w, colors.LightRed, colors.Reset, err.Code, err.Error())
+}
+
+func (tableRenderer) status(w io.Writer, color, reset []byte, code StatusCode, message string) {
+	var buf bytes.Buffer
+	buf.Write(color)
+
+	// Write status name, code and message
+	fmt.Fprintf(&buf, " %s (%d)", statusName(code), int(code))
+	if len(message) > 0 {
+		buf.WriteString(": " + message)
+	}
+
+	// Reset terminal colors
+	buf.Write(reset)
+	buf.WriteString("\r\n")
+	w.Write(buf.Bytes())
+}
+
+// jsonRenderer writes each row as a JSON object on its own line with the keys in column order. Status codes are
+// written as objects with a "status" key. Failures also have a category and optionally a position and details.
+type jsonRenderer struct{}
+
+func (r jsonRenderer) Results(w io.Writer, colors ColorCodes, rs ResultSet) {
+	for _, row := range rs.Rows {
+		r.Row(w, colors, rs.Columns, row)
+	}
+}
+
+// Header writes nothing as every row names its columns
+func (jsonRenderer) Header(w io.Writer, colors ColorCodes, columns []string) {
+}
+
+func (jsonRenderer) Row(w io.Writer, colors ColorCodes, columns []string, values []interface{}) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range columns {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSON(&buf, column)
+		buf.WriteByte(':')
+		writeJSON(&buf, value)
+	}
+	buf.WriteString("}\n")
+	w.Write(buf.Bytes())
+}
+
+func (r jsonRenderer) Status(w io.Writer, colors ColorCodes, code StatusCode, message string) {
+	r.status(w, jsonStatus{Status: statusName(code), Code: int(code), Message: message})
+}
+
+func (r jsonRenderer) Error(w io.Writer, colors ColorCodes, err *Error) {
+	r.status(w, jsonStatus{
+		Status:   statusName(err.Code),
+		Code:     int(err.Code),
+		Category: err.Category,
+		Message:  err.Message,
+		Position: err.Position,
+		Details:  err.Details,
+	})
+}
+
+func (jsonRenderer) status(w io.Writer, status jsonStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+
+		// Details which can't be encoded are left out
+		status.Details = nil
+		data, _ = json.Marshal(status)
+	}
+	w.Write(append(data, '\n'))
+}
+
+// jsonStatus is the JSON encoding of status codes and failures
+type jsonStatus struct {
+	Status   string                 `json:"status"`
+	Code     int                    `json:"code"`
+	Category Category               `json:"category,omitempty"`
+	Message  string                 `json:"message"`
+	Position *Position              `json:"position,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeJSON writes a value as JSON. Values which can't be encoded are written as null.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte("null")
+	}
+	buf.Write(data)
+}
+
+// delimitedRenderer writes result sets as delimited records with a header record. Status codes are written as
+// records starting with '#' so they can be skipped as comments. Failures add the category, the line and character
+// of the position and the details encoded as JSON.
+type delimitedRenderer struct {
+	comma rune
+}
+
+func (r delimitedRenderer) Results(w io.Writer, colors ColorCodes, rs ResultSet) {
+	var buf bytes.Buffer
+	writer := r.writer(&buf)
+	writer.Write(rs.Columns)
+	for _, row := range rs.Rows {
+		writer.Write(r.record(rs.Columns, row))
+	}
+	writer.Flush()
+	w.Write(buf.Bytes())
+}
+
+func (r delimitedRenderer) Header(w io.Writer, colors ColorCodes, columns []string) {
+	r.writeRecord(w, columns)
+}
+
+func (r delimitedRenderer) Row(w io.Writer, colors ColorCodes, columns []string, values []interface{}) {
+	var buf bytes.Buffer
+	writer := r.writer(&buf)
+	writer.Write(r.record(columns, values))
+	writer.Flush()
+	w.Write(buf.Bytes())
+}
+
+func (r delimitedRenderer) Status(w io.Writer, colors ColorCodes, code StatusCode, message string) {
+	r.writeRecord(w, []string{"#" + statusName(code), strconv.Itoa(int(code)), message})
+}
+
+func (r delimitedRenderer) Error(w io.Writer, colors ColorCodes, err *Error) {
+	record := []string{"#" + statusName(err.Code), strconv.Itoa(int(err.Code)), err.Message, string(err.Category), "", "", ""}
+	if err.Position != nil {
+		record[4], record[5] = strconv.Itoa(err.Position.Line), strconv.Itoa(err.Position.Char)
+	}
+	if len(err.Details) > 0 {
+		if data, e := json.Marshal(err.Details); e == nil {
+			record[6] = string(data)
+		}
+	}
+	r.writeRecord(w, record)
+}
+
+func (r delimitedRenderer) writeRecord(w io.Writer, record []string) {
+	var buf bytes.Buffer
+	writer := r.writer(&buf)
+	writer.Write(record)
+	writer.Flush()
+	w.Write(buf.Bytes())
+}
+
+func (r delimitedRenderer) writer(w io.Writer) *csv.Writer {
+	writer := csv.NewWriter(w)
+	writer.Comma = r.comma
+	return writer
+}
+
+// record formats the values of a row. Missing values are written as empty fields.
+func (r delimitedRenderer) record(columns []string, values []interface{}) []string {
+	record := make([]string, len(columns))
+	for i := range columns {
+		if i < len(values) && values[i] != nil {
+			record[i] = formatValue(values[i])
+		}
+	}
+	return record
+}
@@ -7,6 +7,9 @@ type Terminal interface {
 	GetPrompt() string
 	ResetPrompt()
 	SetPrompt(p string)
+
+	// Write writes output above the prompt and redraws the line being edited
+	Write(data []byte) (int, error)
 }
 
 // NewTerminal creates a new terminal wrapper
@@ -33,3 +36,7 @@ func (t *basicTerminal) SetPrompt(p string) {
 	t.currentPrompt = p
 	t.term.SetPrompt(p)
 }
+
+func (t *basicTerminal) Write(data []byte) (int, error) {
+	return t.term.Write(data)
+}
@@ -44,39 +44,143 @@ var DefaultColorCodes = ColorCodes{
 type ResponseWriter struct {
 	Colors ColorCodes
 	Writer io.Writer
+
+	// Format is the name of the renderer used for result sets and status codes. Defaults to "table".
+	Format string
+
+	// Number of failures written
+	failures int
+
+	// Last status code written
+	status StatusCode
+
+	// Position of the statement being executed
+	position *Position
+
+	// Table being streamed with WriteRow, if any
+	table *streamedTable
 }
 
-func (r *ResponseWriter) colorCode(color []byte, code StatusCode, format string, args ...interface{}) {
+// streamedTable buffers the rows of a table streamed with WriteRow until a batch of them can be aligned
+type streamedTable struct {
+	columns []string
+	rows    [][]interface{}
 
-	// Set color
-	r.Writer.Write(color)
+	// Widths of the columns written so far, which are nil until the header has been written
+	widths []int
+}
 
-	// Write error name and code
-	if t, ok := statusCodes[code]; ok {
-		r.Writer.Write([]byte(fmt.Sprintf(" %s (%d)", t, int(code))))
-	} else {
-		r.Writer.Write([]byte(fmt.Sprintf(" Unknown (%d)", int(code))))
+// renderer returns the renderer of the selected format
+func (r *ResponseWriter) renderer() Renderer {
+	if renderer, ok := Renderers[r.Format]; ok {
+		return renderer
 	}
+	return Renderers[DefaultFormat]
+}
 
-	// Write the error message if there is one
-	if len(format) > 0 {
-		r.Writer.Write([]byte(": "))
-		fmt.Fprintf(r.Writer, format, args...)
+// Fail writes the error status code to the Writer
+func (r *ResponseWriter) Fail(code StatusCode, format string, args ...interface{}) {
+	r.Error(NewError(code, format, args...))
+}
+
+// Error writes a failure to the Writer. Errors are converted with AsError so sentinel errors get their status
+// codes. The position of the current statement is added if the error has none.
+func (r *ResponseWriter) Error(err error) {
+	if err == nil {
+		return
+	}
+
+	e := AsError(err)
+	if e.Position == nil && r.position != nil {
+		e = e.At(*r.position)
 	}
 
-	// Reset terminal colors
-	r.Writer.Write(r.Colors.Reset)
-	r.Writer.Write([]byte("\r\n"))
+	r.endTable()
+	r.failures++
+	r.status = e.Code
+	r.renderer().Error(r.Writer, r.Colors, e)
 }
 
-// Fail writes the error status code to the Writer
-func (r *ResponseWriter) Fail(code StatusCode, format string, args ...interface{}) {
-	r.colorCode(r.Colors.LightRed, code, format, args...)
+// SetPosition sets the position of the statement being executed which is added to failures. A nil position
+// clears it.
+func (r *ResponseWriter) SetPosition(pos *Position) {
+	r.position = pos
+}
+
+// Status returns the last status code written. It is zero if no status code has been written.
+func (r *ResponseWriter) Status() StatusCode {
+	return r.status
+}
+
+// Failures returns the number of error status codes written
+func (r *ResponseWriter) Failures() int {
+	return r.failures
 }
 
 // Success writes the status code to the Writer
 func (r *ResponseWriter) Success(code StatusCode, format string, args ...interface{}) {
-	r.colorCode(r.Colors.LightGreen, code, format, args...)
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+
+	r.endTable()
+	r.status = code
+	r.renderer().Status(r.Writer, r.Colors, code, message)
+}
+
+// Structured returns true if the selected format is machine readable rather than a table for terminals
+func (r *ResponseWriter) Structured() bool {
+	_, ok := r.renderer().(tableRenderer)
+	return !ok
+}
+
+// WriteResults writes a result set in the selected format
+func (r *ResponseWriter) WriteResults(rs ResultSet) {
+	r.endTable()
+	r.renderer().Results(r.Writer, r.Colors, rs)
+}
+
+// WriteHeader writes the header of a result set which is streamed with WriteRow. Tables buffer the rows of the
+// result set and write them in batches of TableBatchSize rows with aligned columns. The last batch is written with
+// the next status code or result set.
+func (r *ResponseWriter) WriteHeader(columns []string) {
+	r.endTable()
+	if _, ok := r.renderer().(tableRenderer); ok {
+		r.table = &streamedTable{columns: columns}
+		return
+	}
+	r.renderer().Header(r.Writer, r.Colors, columns)
+}
+
+// WriteRow writes a single row in the selected format. Rows written without a header are written on their own.
+func (r *ResponseWriter) WriteRow(columns []string, values []interface{}) {
+	if r.table == nil {
+		r.renderer().Row(r.Writer, r.Colors, columns, values)
+		return
+	}
+
+	r.table.rows = append(r.table.rows, values)
+	if len(r.table.rows) >= TableBatchSize {
+		r.flushTable()
+	}
+}
+
+// flushTable writes the buffered rows of the streamed table. The header is written with the first batch, even if
+// the table has no rows.
+func (r *ResponseWriter) flushTable() {
+	if r.table == nil || (len(r.table.rows) == 0 && r.table.widths != nil) {
+		return
+	}
+	header := r.table.widths == nil
+	r.table.widths = tableRenderer{}.rows(r.Writer, r.Colors, r.table.columns, r.table.rows, r.table.widths, header)
+	r.table.rows = nil
+}
+
+// endTable writes the rest of the streamed table, if any
+func (r *ResponseWriter) endTable() {
+	r.flushTable()
+	r.table = nil
 }
 
 // Write is a pass through function into the underlying Writer
@@ -1,17 +1,25 @@
 package datamodel
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"net/url"
+	"time"
 )
 
 // SaltSize is the size of the salt for encrypting passwords
 const SaltSize = 16
 
 // GenerateSalt creates a new salt and encodes the given password.
-// It returns the new salt, the ecrypted password and a possible error
+// It returns the new salt, the ecrypted password and a possible error.
+// This is the legacy encoding of passwords, which are now hashed with a PasswordHasher.
 func GenerateSalt(secret []byte) ([]byte, []byte, error) {
 	buf := make([]byte, SaltSize, SaltSize+sha256.Size)
 	_, err := io.ReadFull(rand.Reader, buf)
@@ -26,6 +34,14 @@ func GenerateSalt(secret []byte) ([]byte, []byte, error) {
 	return buf, hash.Sum(nil), nil
 }
 
+// ValidateSalt determines if a password matches a salted password created by GenerateSalt
+func ValidateSalt(salt, saltedpw, secret []byte) bool {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write(secret)
+	return SecureCompare(hash.Sum(nil), saltedpw)
+}
+
 // SecureCompare compares salted passwords in constant time
 // http://stackoverflow.com/questions/20663468/secure-compare-of-strings-in-go
 func SecureCompare(given, actual []byte) bool {
@@ -36,3 +52,89 @@ func SecureCompare(given, actual []byte) bool {
 	/* Securely compare actual to itself to keep constant time, but always return false */
 	return subtle.ConstantTimeCompare(actual, actual) == 1 && false
 }
+
+// TOTP second factors follow RFC 6238 with HMAC-SHA1, 30 second periods and 6 digit codes, which is what
+// authenticator apps expect by default.
+const (
+
+	// TOTPSecretSize is the size of generated TOTP secrets
+	TOTPSecretSize = 20
+
+	// TOTPPeriod is the duration for which a code is valid
+	TOTPPeriod = 30 * time.Second
+
+	// TOTPDigits is the number of digits of a code
+	TOTPDigits = 6
+
+	// TOTPSkew is the number of periods before and after the current one whose codes are also accepted to allow
+	// for clock drift
+	TOTPSkew = 1
+)
+
+// totpEncoding encodes TOTP secrets for authenticator apps
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret creates a new random TOTP secret
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, TOTPSecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret encodes a TOTP secret as base32, the format authenticator apps accept
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI returns the otpauth URI of a TOTP secret, which authenticator apps import from QR codes
+func TOTPURI(secret []byte, issuer, account string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{"secret": {EncodeTOTPSecret(secret)}, "issuer": {issuer}}
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPCode returns the code of a TOTP secret for the period containing the given time
+func TOTPCode(secret []byte, t time.Time) string {
+	return hotp(secret, uint64(t.Unix()/int64(TOTPPeriod/time.Second)))
+}
+
+// ValidateTOTP determines if a code is valid for a TOTP secret at the given time. Codes of the adjacent periods
+// are accepted as well.
+func ValidateTOTP(secret []byte, code string, t time.Time) bool {
+	_, valid := MatchTOTP(secret, code, t)
+	return valid
+}
+
+// MatchTOTP returns the time step of the period a code is valid for if it is the code of the period containing the
+// given time or of an adjacent period. Time steps count the periods since the Unix epoch.
+func MatchTOTP(secret []byte, code string, t time.Time) (step uint64, valid bool) {
+	counter := t.Unix() / int64(TOTPPeriod/time.Second)
+	for i := counter - TOTPSkew; i <= counter+TOTPSkew; i++ {
+		if SecureCompare([]byte(code), []byte(hotp(secret, uint64(i)))) {
+			step, valid = uint64(i), true
+		}
+	}
+	return
+}
+
+// hotp returns the HOTP code of a secret for a counter as defined by RFC 4226
+func hotp(secret []byte, counter uint64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	var modulo uint32 = 1
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo)
+}
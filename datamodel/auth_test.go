@@ -0,0 +1,69 @@
+package datamodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure TOTP codes match the SHA1 test vectors of RFC 6238, truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	var tests = []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.code, TOTPCode(secret, time.Unix(tt.unix, 0)), "%d. code at %d", i, tt.unix)
+	}
+}
+
+// Ensure codes of adjacent periods are accepted
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, TOTPSecretSize)
+
+	now := time.Unix(1234567890, 0)
+	assert.True(t, ValidateTOTP(secret, TOTPCode(secret, now), now))
+	assert.True(t, ValidateTOTP(secret, TOTPCode(secret, now.Add(-TOTPPeriod)), now))
+	assert.True(t, ValidateTOTP(secret, TOTPCode(secret, now.Add(TOTPPeriod)), now))
+	assert.False(t, ValidateTOTP(secret, TOTPCode(secret, now.Add(-2*TOTPPeriod)), now))
+	assert.False(t, ValidateTOTP(secret, TOTPCode(secret, now.Add(2*TOTPPeriod)), now))
+	assert.False(t, ValidateTOTP(secret, "", now))
+	assert.False(t, ValidateTOTP([]byte("other"), TOTPCode(secret, now), now))
+}
+
+// Ensure TOTP URIs can be imported by authenticator apps
+func TestTOTPURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", EncodeTOTPSecret(secret))
+	assert.Equal(t, "otpauth://totp/kappa:bob?issuer=kappa&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		TOTPURI(secret, "kappa", "bob"))
+}
+
+// Ensure the time step of the period a code is valid for is returned
+func TestMatchTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.Nil(t, err)
+
+	now := time.Unix(1234567890, 0)
+	step := uint64(1234567890 / 30)
+	for _, offset := range []int{-1, 0, 1} {
+		matched, valid := MatchTOTP(secret, TOTPCode(secret, now.Add(time.Duration(offset)*TOTPPeriod)), now)
+		assert.True(t, valid, "offset %d", offset)
+		assert.Equal(t, step+uint64(offset), matched, "offset %d", offset)
+	}
+
+	_, valid := MatchTOTP(secret, TOTPCode(secret, now.Add(2*TOTPPeriod)), now)
+	assert.False(t, valid)
+}
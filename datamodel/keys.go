@@ -0,0 +1,133 @@
+package datamodel
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/subsilent/kappa/common"
+	"golang.org/x/crypto/ssh"
+)
+
+// Options of authorized_keys lines which are enforced
+const (
+
+	// commandOption sets the script executed instead of the shell or script requested by the client
+	commandOption = "command"
+
+	// expiryTimeOption sets the time from which a key can no longer be used
+	expiryTimeOption = "expiry-time"
+)
+
+// disabledFeatureOptions are authorized_keys options disabling features the server doesn't offer, so they are
+// enforced without restricting the key
+var disabledFeatureOptions = map[string]bool{
+	"no-agent-forwarding": true,
+	"no-port-forwarding":  true,
+	"no-user-rc":          true,
+	"no-x11-forwarding":   true,
+}
+
+// errUnsupportedKeyOption returns the error for an authorized_keys option which can't be enforced
+func errUnsupportedKeyOption(option string) *common.Error {
+	return common.NewError(common.InvalidPublicKey, "unsupported key option '%s'", option).With("option", option)
+}
+
+// ParsePublicKey parses a public key added to a key ring. Keys are either PEM encoded X.509 certificates, such as
+// those issued by new-cert, or OpenSSH public keys in any algorithm supported by the SSH server. OpenSSH keys may be
+// authorized_keys lines with options and a comment. The comment and the command and expiry-time options are returned
+// as attributes of the key. Other options are rejected with an InvalidPublicKey error naming the option as they
+// can't be enforced, except for those disabling features the server doesn't offer such as forwarding. SSH
+// certificates are rejected as they are authenticated by the certificate authority which signed them.
+func ParsePublicKey(data []byte) (ssh.PublicKey, KeyAttributes, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, KeyAttributes{}, ErrInvalidCertificate
+	}
+
+	// Convert X.509 certificates to SSH keys
+	if bytes.HasPrefix(data, []byte("-----BEGIN")) {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, KeyAttributes{}, ErrInvalidCertificate
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, KeyAttributes{}, ErrInvalidCertificate
+		}
+
+		key, err := ssh.NewPublicKey(cert.PublicKey)
+		if err != nil {
+			return nil, KeyAttributes{}, ErrFailedKeyConvertion
+		}
+		return key, KeyAttributes{}, nil
+	}
+
+	// Parse a single authorized_keys line
+	key, comment, options, rest, err := ssh.ParseAuthorizedKey(data)
+	if err != nil || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, KeyAttributes{}, ErrInvalidPublicKey
+	} else if _, ok := key.(*ssh.Certificate); ok {
+		return nil, KeyAttributes{}, ErrInvalidPublicKey
+	}
+
+	attributes := KeyAttributes{Comment: comment}
+	for _, option := range options {
+		parts := strings.SplitN(option, "=", 2)
+		name := strings.ToLower(parts[0])
+		switch {
+		case len(parts) == 1 && disabledFeatureOptions[name]:
+		case len(parts) == 2 && name == commandOption:
+			if attributes.Command, err = unquoteOption(parts[1]); err != nil || attributes.Command == "" {
+				return nil, KeyAttributes{}, ErrInvalidPublicKey
+			}
+		case len(parts) == 2 && name == expiryTimeOption:
+			if attributes.Expires, err = parseExpiryTime(parts[1]); err != nil {
+				return nil, KeyAttributes{}, ErrInvalidPublicKey
+			}
+		default:
+			return nil, KeyAttributes{}, errUnsupportedKeyOption(parts[0])
+		}
+	}
+	return key, attributes, nil
+}
+
+// unquoteOption returns the value of an authorized_keys option. Values are enclosed in double quotes which are
+// escaped with a backslash within the value.
+func unquoteOption(value string) (string, error) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", ErrInvalidPublicKey
+	}
+	return strings.Replace(value[1:len(value)-1], `\"`, `"`, -1), nil
+}
+
+// parseExpiryTime parses the value of the expiry-time option. Times are formatted as YYYYMMDD[HHMM[SS]] in the local
+// time zone or in UTC if followed by a Z.
+func parseExpiryTime(value string) (time.Time, error) {
+	value, err := unquoteOption(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	location := time.Local
+	if strings.HasSuffix(value, "Z") {
+		value, location = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+
+	layout := "20060102150405"
+	switch len(value) {
+	case 8, 12, 14:
+		layout = layout[:len(value)]
+	default:
+		return time.Time{}, ErrInvalidPublicKey
+	}
+
+	expires, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expires.UTC(), nil
+}
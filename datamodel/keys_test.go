@@ -0,0 +1,178 @@
+package datamodel
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/common"
+	"golang.org/x/crypto/ssh"
+)
+
+// generateKey creates an SSH public key of the given algorithm
+func generateKey(t *testing.T, algorithm string) (ssh.PublicKey, crypto.Signer) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case ssh.KeyAlgoED25519:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case ssh.KeyAlgoECDSA256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ssh.KeyAlgoECDSA384:
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case ssh.KeyAlgoRSA:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, signer
+}
+
+// Ensure OpenSSH public keys of every algorithm are parsed
+func TestParsePublicKeyAlgorithms(t *testing.T) {
+	for _, algorithm := range []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoRSA} {
+		expected, _ := generateKey(t, algorithm)
+
+		// Keys without a comment
+		key, attributes, err := ParsePublicKey(ssh.MarshalAuthorizedKey(expected))
+		assert.Nil(t, err, algorithm)
+		assert.Equal(t, expected.Marshal(), key.Marshal(), algorithm)
+		assert.Equal(t, KeyAttributes{}, attributes, algorithm)
+
+		// Keys with a comment as written to id_*.pub files
+		line := bytes.TrimSpace(ssh.MarshalAuthorizedKey(expected))
+		key, attributes, err = ParsePublicKey(append(line, " ci@build.example.com\n"...))
+		assert.Nil(t, err, algorithm)
+		assert.Equal(t, expected.Marshal(), key.Marshal(), algorithm)
+		assert.Equal(t, "ci@build.example.com", attributes.Comment, algorithm)
+	}
+}
+
+// Ensure the options of authorized_keys lines are parsed
+func TestParsePublicKeyOptions(t *testing.T) {
+	expected, _ := generateKey(t, ssh.KeyAlgoED25519)
+	line := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(expected)))
+
+	var tests = []struct {
+		options string
+		command string
+		expires time.Time
+	}{
+		{options: `no-port-forwarding,no-agent-forwarding,no-X11-forwarding,no-user-rc`},
+		{options: `command="SHOW NAMESPACES"`, command: "SHOW NAMESPACES"},
+		{options: `COMMAND="INSERT INTO \"events\" {id: 1}",no-port-forwarding`, command: `INSERT INTO "events" {id: 1}`},
+		{options: `expiry-time="20301231"`, expires: time.Date(2030, 12, 31, 0, 0, 0, 0, time.Local)},
+		{options: `no-agent-forwarding,EXPIRY-TIME="203012311530Z"`, expires: time.Date(2030, 12, 31, 15, 30, 0, 0, time.UTC)},
+		{options: `expiry-time="20301231153045Z",command="SELECT * FROM events"`, command: "SELECT * FROM events",
+			expires: time.Date(2030, 12, 31, 15, 30, 45, 0, time.UTC)},
+	}
+
+	for i, tt := range tests {
+		key, attributes, err := ParsePublicKey([]byte(tt.options + " " + line + " deploy key"))
+		if !assert.Nil(t, err, "%d. %s", i, tt.options) {
+			continue
+		}
+		assert.Equal(t, expected.Marshal(), key.Marshal(), "%d. %s", i, tt.options)
+		assert.Equal(t, "deploy key", attributes.Comment, "%d. %s", i, tt.options)
+		assert.Equal(t, tt.command, attributes.Command, "%d. %s", i, tt.options)
+		assert.True(t, tt.expires.Equal(attributes.Expires), "%d. %s: %s", i, tt.options, attributes.Expires)
+	}
+}
+
+// Ensure options which can't be enforced are rejected rather than ignored
+func TestParsePublicKeyUnsupportedOptions(t *testing.T) {
+	key, _ := generateKey(t, ssh.KeyAlgoED25519)
+	line := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+
+	var tests = []struct {
+		options string
+		option  string
+	}{
+		{options: `from="10.0.0.0/8"`, option: "from"},
+		{options: `restrict,command="SHOW NAMESPACES"`, option: "restrict"},
+		{options: `command="SHOW NAMESPACES",no-pty`, option: "no-pty"},
+		{options: `no-port-forwarding,permitopen="localhost:80"`, option: "permitopen"},
+		{options: `environment="KAPPA_FORMAT=json"`, option: "environment"},
+		{options: `principals="ana"`, option: "principals"},
+		{options: `cert-authority`, option: "cert-authority"},
+		{options: `no-port-forwarding=yes`, option: "no-port-forwarding"},
+	}
+
+	for i, tt := range tests {
+		parsed, _, err := ParsePublicKey([]byte(tt.options + " " + line))
+		assert.Nil(t, parsed, "%d. %s", i, tt.options)
+		if e, ok := err.(*common.Error); assert.True(t, ok, "%d. %s", i, tt.options) {
+			assert.Equal(t, common.InvalidPublicKey, e.Code, "%d. %s", i, tt.options)
+			assert.Equal(t, "unsupported key option '"+tt.option+"'", e.Message, "%d. %s", i, tt.options)
+			assert.Equal(t, tt.option, e.Details["option"], "%d. %s", i, tt.options)
+		}
+	}
+}
+
+// Ensure X.509 certificates are converted to SSH keys
+func TestParsePublicKeyCertificate(t *testing.T) {
+	expected, signer := generateKey(t, ssh.KeyAlgoRSA)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	crt, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	assert.Nil(t, err)
+
+	key, attributes, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt}))
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Marshal(), key.Marshal())
+	assert.Equal(t, KeyAttributes{}, attributes)
+}
+
+// Ensure invalid keys are rejected
+func TestParsePublicKeyInvalid(t *testing.T) {
+	key, signer := generateKey(t, ssh.KeyAlgoED25519)
+	line := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+
+	// SSH certificates are authenticated by their authority
+	certificate := &ssh.Certificate{Key: key, CertType: ssh.UserCert, ValidBefore: ssh.CertTimeInfinity}
+	authority, err := ssh.NewSignerFromSigner(signer)
+	assert.Nil(t, err)
+	assert.Nil(t, certificate.SignCert(rand.Reader, authority))
+
+	var tests = []struct {
+		key string
+		err error
+	}{
+		{key: "", err: ErrInvalidCertificate},
+		{key: " \n", err: ErrInvalidCertificate},
+		{key: "-----BEGIN CERTIFICATE-----\nblah\n-----END CERTIFICATE-----", err: ErrInvalidCertificate},
+		{key: "blahblah", err: ErrInvalidPublicKey},
+		{key: "ssh-ed25519 blahblah", err: ErrInvalidPublicKey},
+		{key: line + "\n" + line, err: ErrInvalidPublicKey},
+		{key: `expiry-time="2030" ` + line, err: ErrInvalidPublicKey},
+		{key: `expiry-time="20301340" ` + line, err: ErrInvalidPublicKey},
+		{key: `expiry-time=20301231 ` + line, err: ErrInvalidPublicKey},
+		{key: `command="" ` + line, err: ErrInvalidPublicKey},
+		{key: string(ssh.MarshalAuthorizedKey(certificate)), err: ErrInvalidPublicKey},
+	}
+
+	for i, tt := range tests {
+		key, _, err := ParsePublicKey([]byte(tt.key))
+		assert.Nil(t, key, "%d. %q", i, tt.key)
+		assert.Equal(t, tt.err, err, "%d. %q", i, tt.key)
+	}
+}
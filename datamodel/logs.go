@@ -0,0 +1,226 @@
+package datamodel
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/storage"
+)
+
+var (
+
+	// ErrLogDoesNotExist is returned if a log does not exist when an operation is attempted to be performed on it
+	ErrLogDoesNotExist = common.NewError(common.LogDoesNotExist, "log does not exist")
+
+	// ErrLogAlreadyExists is returned when creating a log which already exists
+	ErrLogAlreadyExists = common.NewError(common.LogAlreadyExists, "log already exists")
+)
+
+// Log represents a log in the database. The log definition is stored in the system database while the records
+// are stored in an append-only segmented log on disk.
+type Log interface {
+
+	// Name returns the fully qualified log name
+	Name() string
+
+	// Namespace returns the namespace the log belongs to
+	Namespace() string
+
+	// Created returns the time the log was created
+	Created() time.Time
+
+	// Type returns the fully qualified name of the type records are validated against. Untyped logs return an
+	// empty string.
+	Type() string
+
+	// Open returns the segmented log containing the log records
+	Open() (storage.Log, error)
+}
+
+// LogStore contains log definitions
+type LogStore interface {
+
+	// Get returns a Log by name
+	Get(name string) (Log, error)
+
+	// Create inserts a new log into the given namespace. The type name may be empty for untyped logs.
+	Create(namespace, name, typeName string) (Log, error)
+
+	// Delete removes a log and all of its records
+	Delete(name string) error
+
+	// Stream returns a channel of log names
+	Stream() chan string
+}
+
+// NewBoltLogStore creates a new LogStore using the given keyspace and storage engine
+func NewBoltLogStore(ks leaf.Keyspace, engine storage.Engine) LogStore {
+	return &boltLogStore{ks, engine}
+}
+
+type boltLogStore struct {
+	ks     leaf.Keyspace
+	engine storage.Engine
+}
+
+// Create adds a log to the database
+func (b boltLogStore) Create(namespace, name, typeName string) (l Log, err error) {
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Verify the log does not exist
+		if bkt.Bucket([]byte(name)) != nil {
+			err = ErrLogAlreadyExists
+			return
+		}
+
+		// Create bucket
+		logBucket, e := bkt.CreateBucket([]byte(name))
+		if e != nil {
+			err = e
+			return
+		}
+
+		// Save log definition
+		if err = logBucket.Put([]byte("namespace"), []byte(namespace)); err != nil {
+			return
+		}
+		if err = logBucket.Put([]byte("created"), []byte(time.Now().UTC().Format(time.RFC3339Nano))); err != nil {
+			return
+		}
+		if err = logBucket.Put([]byte("type"), []byte(typeName)); err != nil {
+			return
+		}
+		l = boltLog{[]byte(name), b.ks, b.engine}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	// Create the first segment
+	if _, err = b.engine.Open(name); err != nil {
+		l = nil
+	}
+	return
+}
+
+// Get returns a Log, returning an error if it doesn't exist
+func (b boltLogStore) Get(name string) (l Log, err error) {
+	b.ks.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get log bucket
+		if bkt.Bucket([]byte(name)) == nil {
+			err = ErrLogDoesNotExist
+			return
+		}
+		l = boltLog{[]byte(name), b.ks, b.engine}
+		return
+	})
+	return
+}
+
+// Delete removes a log definition and all of its records
+func (b boltLogStore) Delete(name string) (err error) {
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Delete bucket
+		if err = bkt.DeleteBucket([]byte(name)); err == bolt.ErrBucketNotFound {
+			err = ErrLogDoesNotExist
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	// Remove segments
+	return b.engine.Drop(name)
+}
+
+// Stream returns a channel of log names
+func (b boltLogStore) Stream() chan string {
+	out := make(chan string)
+
+	// Read logs in background
+	go func(channel chan<- string) {
+		b.ks.ReadTx(func(bkt *bolt.Bucket) {
+			cur := bkt.Cursor()
+
+			// Iterate over keys
+			for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+				channel <- string(k)
+			}
+
+			// Close channel
+			close(channel)
+			return
+		})
+	}(out)
+	return out
+}
+
+// boltLog implements the Log interface on top of boltdb
+//
+// Each log has a bucket in the keyspace containing the namespace, creation time and type name.
+type boltLog struct {
+	name   []byte
+	logs   leaf.Keyspace
+	engine storage.Engine
+}
+
+// Name returns the fully qualified log name
+func (b boltLog) Name() string {
+	return string(b.name)
+}
+
+// Namespace returns the namespace the log belongs to
+func (b boltLog) Namespace() (namespace string) {
+	b.logs.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get log bucket
+		log := bkt.Bucket(b.name)
+		if log == nil {
+			return
+		}
+		namespace = string(log.Get([]byte("namespace")))
+		return
+	})
+	return
+}
+
+// Created returns the time the log was created
+func (b boltLog) Created() (created time.Time) {
+	b.logs.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get log bucket
+		log := bkt.Bucket(b.name)
+		if log == nil {
+			return
+		}
+		created, _ = time.Parse(time.RFC3339Nano, string(log.Get([]byte("created"))))
+		return
+	})
+	return
+}
+
+// Type returns the fully qualified name of the type records are validated against
+func (b boltLog) Type() (typeName string) {
+	b.logs.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get log bucket
+		log := bkt.Bucket(b.name)
+		if log == nil {
+			return
+		}
+		typeName = string(log.Get([]byte("type")))
+		return
+	})
+	return
+}
+
+// Open returns the segmented log containing the log records
+func (b boltLog) Open() (storage.Log, error) {
+	return b.engine.Open(string(b.name))
+}
@@ -0,0 +1,149 @@
+package datamodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/stretchr/testify/suite"
+	"github.com/subsilent/kappa/storage"
+)
+
+// TestLogTestSuite runs the LogTestSuite
+func TestLogTestSuite(t *testing.T) {
+	suite.Run(t, new(LogTestSuite))
+}
+
+// LogTestSuite tests the log store
+type LogTestSuite struct {
+	suite.Suite
+	Dir    string
+	DB     leaf.KeyValueDatabase
+	Engine storage.Engine
+	LS     LogStore
+	KS     leaf.Keyspace
+}
+
+// SetupSuite prepares the suite before any tests are ran
+func (suite *LogTestSuite) SetupSuite() {
+
+	// Create temp directory
+	suite.Dir, _ = ioutil.TempDir("", "datamodel.test")
+
+	// Connect to database
+	db, err := leaf.NewLeaf(path.Join(suite.Dir, "test.db"))
+	if err != nil {
+		suite.T().Log("Error creating database")
+		suite.T().FailNow()
+	}
+	suite.DB = db
+
+	// Create keyspace
+	ks, err := db.GetOrCreateKeyspace(Logs)
+	suite.Nil(err)
+	suite.KS = ks
+
+	// Create log store
+	suite.Engine = storage.NewEngine(path.Join(suite.Dir, "logs"), storage.Options{})
+	suite.LS = NewBoltLogStore(ks, suite.Engine)
+}
+
+// TearDownSuite cleans up suite state after all the tests have completed
+func (suite *LogTestSuite) TearDownSuite() {
+
+	// Close database
+	suite.Engine.Close()
+	suite.DB.Close()
+
+	// Clear test directory
+	os.RemoveAll(suite.Dir)
+}
+
+// TestCreateLog ensures a log can be created
+func (suite *LogTestSuite) TestCreateLog() {
+	l, err := suite.LS.Create("acme", "acme.create", "acme.Event")
+	suite.Nil(err)
+	suite.NotNil(l)
+	suite.Equal("acme.create", l.Name())
+	suite.Equal("acme", l.Namespace())
+	suite.WithinDuration(time.Now(), l.Created(), time.Minute)
+	suite.Equal("acme.Event", l.Type())
+
+	// Test that the log was created
+	suite.KS.ReadTx(func(bkt *bolt.Bucket) {
+		suite.NotNil(bkt.Bucket([]byte("acme.create")))
+	})
+
+	// Test that the first segment was created
+	_, err = os.Stat(path.Join(suite.Dir, "logs", "acme.create"))
+	suite.Nil(err)
+
+	// Creating the log again fails
+	l, err = suite.LS.Create("acme", "acme.create", "acme.Event")
+	suite.Equal(ErrLogAlreadyExists, err)
+	suite.Nil(l)
+}
+
+// TestGetLog ensures a log can be retrieved
+func (suite *LogTestSuite) TestGetLog() {
+	l, err := suite.LS.Get("acme.none")
+	suite.Equal(ErrLogDoesNotExist, err)
+	suite.Nil(l)
+
+	_, err = suite.LS.Create("acme", "acme.get", "")
+	suite.Nil(err)
+
+	l, err = suite.LS.Get("acme.get")
+	suite.Nil(err)
+	suite.Equal("acme.get", l.Name())
+	suite.Equal("", l.Type())
+}
+
+// TestOpenLog ensures records can be appended to a log
+func (suite *LogTestSuite) TestOpenLog() {
+	l, err := suite.LS.Create("acme", "acme.open", "")
+	suite.Nil(err)
+
+	records, err := l.Open()
+	suite.Nil(err)
+
+	offsets, err := records.Append([]byte("a"), []byte("b"))
+	suite.Nil(err)
+	suite.Equal([]uint64{0, 1}, offsets)
+}
+
+// TestDeleteLog ensures a log and its records can be deleted
+func (suite *LogTestSuite) TestDeleteLog() {
+	_, err := suite.LS.Create("acme", "acme.delete", "")
+	suite.Nil(err)
+
+	suite.Nil(suite.LS.Delete("acme.delete"))
+	suite.Equal(ErrLogDoesNotExist, suite.LS.Delete("acme.delete"))
+
+	// Test that the log was deleted
+	suite.KS.ReadTx(func(bkt *bolt.Bucket) {
+		suite.Nil(bkt.Bucket([]byte("acme.delete")))
+	})
+
+	_, err = os.Stat(path.Join(suite.Dir, "logs", "acme.delete"))
+	suite.True(os.IsNotExist(err))
+}
+
+// TestStreamLogs ensures all logs are streamed
+func (suite *LogTestSuite) TestStreamLogs() {
+	_, err := suite.LS.Create("acme", "acme.stream", "")
+	suite.Nil(err)
+
+	var found bool
+	for name := range suite.LS.Stream() {
+		if name == "acme.stream" {
+			found = true
+		}
+	}
+	suite.True(found)
+}
@@ -1,17 +1,18 @@
 package datamodel
 
 import (
-	"fmt"
+	"bytes"
 	"strings"
 
 	"github.com/boltdb/bolt"
 	"github.com/eliquious/leaf"
+	"github.com/subsilent/kappa/common"
 )
 
 var (
 
 	// ErrNamespaceDoesNotExist is returned if a namespace does not exist when an operation is attempted to be performed on it
-	ErrNamespaceDoesNotExist = fmt.Errorf("namespace does not exist")
+	ErrNamespaceDoesNotExist = common.NewError(common.NamespaceDoesNotExist, "namespace does not exist")
 )
 
 // Namespace represents a namespace in the database. Each Namespace has users, logs and views.
@@ -32,9 +33,12 @@ type Namespace interface {
 	// RevokePermission removes a permission from the given role
 	RevokePermission(role string, permission string) error
 
-	// HasPermission detmines if the given role has a certain permission
+	// HasPermission determines if the given role has a certain permission. Entries may be wildcards or denials.
 	HasPermission(role string, permission string) bool
 
+	// Permissions returns the permissions granted to the given role
+	Permissions(role string) []string
+
 	// AddUser registers a user with the namespace
 	AddUser(username string) error
 
@@ -65,6 +69,9 @@ type NamespaceStore interface {
 
 	// Stream returns a channel of namespaces
 	Stream() chan string
+
+	// Children returns the names of every namespace below the given namespace
+	Children(name string) []string
 }
 
 // NewBoltNamespaceStore creates a new NamespaceStore using the given keyspace
@@ -127,6 +134,21 @@ func (b boltNamespaceStore) Stream() chan string {
 	return out
 }
 
+// Children returns the names of every namespace below the given namespace
+func (b boltNamespaceStore) Children(name string) (children []string) {
+	b.ks.ReadTx(func(bkt *bolt.Bucket) {
+		prefix := []byte(name + ".")
+		cur := bkt.Cursor()
+
+		// Child names share the parent name as a prefix so they are stored together
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			children = append(children, string(k))
+		}
+		return
+	})
+	return
+}
+
 // Delete removes a namespace from the database
 func (b boltNamespaceStore) Delete(name string) (err error) {
 	b.ks.WriteTx(func(bkt *bolt.Bucket) {
@@ -263,8 +285,8 @@ func (b boltNamespace) AddRole(name string) (err error) {
 		}
 
 		// Get roles bucket
-		roles, err := ns.CreateBucketIfNotExists([]byte("roles"))
-		if err != nil {
+		var roles *bolt.Bucket
+		if roles, err = ns.CreateBucketIfNotExists([]byte("roles")); err != nil {
 			return
 		}
 
@@ -289,8 +311,8 @@ func (b boltNamespace) RemoveRole(name string) (err error) {
 		}
 
 		// Get roles bucket
-		roles, err := ns.CreateBucketIfNotExists([]byte("roles"))
-		if err != nil {
+		var roles *bolt.Bucket
+		if roles, err = ns.CreateBucketIfNotExists([]byte("roles")); err != nil {
 			return
 		}
 
@@ -339,8 +361,8 @@ func (b boltNamespace) GrantPermissions(role string, permissions ...string) (err
 		}
 
 		// Get roles bucket
-		roles, err := ns.CreateBucketIfNotExists([]byte("roles"))
-		if err != nil {
+		var roles *bolt.Bucket
+		if roles, err = ns.CreateBucketIfNotExists([]byte("roles")); err != nil {
 			return
 		}
 
@@ -349,9 +371,9 @@ func (b boltNamespace) GrantPermissions(role string, permissions ...string) (err
 		if len(perms) > 0 {
 
 			list := []string{string(perms), strings.Join(permissions, ",")}
-			roles.Put([]byte(role), []byte(strings.Join(list, ",")))
+			err = roles.Put([]byte(role), []byte(strings.Join(list, ",")))
 		} else {
-			roles.Put([]byte(role), []byte(strings.Join(permissions, ",")))
+			err = roles.Put([]byte(role), []byte(strings.Join(permissions, ",")))
 		}
 		return
 	})
@@ -370,8 +392,8 @@ func (b boltNamespace) RevokePermission(role string, permission string) (err err
 		}
 
 		// Get roles bucket
-		roles, err := ns.CreateBucketIfNotExists([]byte("roles"))
-		if err != nil {
+		var roles *bolt.Bucket
+		if roles, err = ns.CreateBucketIfNotExists([]byte("roles")); err != nil {
 			return
 		}
 
@@ -394,8 +416,16 @@ func (b boltNamespace) RevokePermission(role string, permission string) (err err
 	return
 }
 
-func (b boltNamespace) HasPermission(role string, permission string) (allow bool) {
-	b.namespaces.WriteTx(func(bkt *bolt.Bucket) {
+// HasPermission determines if the entries of a role grant a permission. The most specific matching entry decides
+// and deny entries decide over allow entries which are as specific.
+func (b boltNamespace) HasPermission(role string, permission string) bool {
+	best := bestMatch(nil, role, b.Permissions(role), permission)
+	return best != nil && !best.deny()
+}
+
+// Permissions returns the permissions granted to the given role
+func (b boltNamespace) Permissions(role string) (permissions []string) {
+	b.namespaces.ReadTx(func(bkt *bolt.Bucket) {
 
 		// Get namespace bucket
 		ns := bkt.Bucket(b.name)
@@ -404,22 +434,16 @@ func (b boltNamespace) HasPermission(role string, permission string) (allow bool
 		}
 
 		// Get roles bucket
-		roles, err := ns.CreateBucketIfNotExists([]byte("roles"))
-		if err != nil {
+		roles := ns.Bucket([]byte("roles"))
+		if roles == nil {
 			return
 		}
 
 		// Get permissions
 		perms := roles.Get([]byte(role))
 		if len(perms) > 0 {
-			for _, p := range strings.Split(string(perms), ",") {
-				if p == permission {
-					allow = true
-					break
-				}
-			}
+			permissions = strings.Split(string(perms), ",")
 		}
-
 		return
 	})
 	return
@@ -462,8 +486,9 @@ func (b boltNamespace) CreateChild(child string) (sub Namespace, e error) {
 		})
 
 		// Copy users
-		users := parent.Get([]byte("users"))
-		childBucket.Put([]byte(users), users)
+		if users := parent.Get([]byte("users")); len(users) > 0 {
+			childBucket.Put([]byte("users"), users)
+		}
 
 		// Create sub namespace
 		sub = &boltNamespace{[]byte(child), b.namespaces}
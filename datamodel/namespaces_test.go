@@ -906,3 +906,45 @@ func (suite *NamespaceTestSuite) TestCreateChild() {
     suite.True(child.HasPermission("admin", "create.namespace"))
     suite.True(child.HasPermission("dev", "create.log"))
 }
+
+func (suite *NamespaceTestSuite) TestCreateChildCopiesUsers() {
+    name := "acme.copy.users"
+
+    // Create namespace
+    ns, _ := suite.createNamespace(name)
+    ns.AddUser("marvin.martian")
+
+    // Create child namespace
+    child, err := ns.CreateChild(name + ".child")
+    suite.Nil(err)
+
+    // Check users
+    suite.Equal([]string{"marvin.martian"}, child.Users())
+}
+
+func (suite *NamespaceTestSuite) TestPermissions() {
+    name := "acme.permissions"
+
+    // Create namespace
+    ns, _ := suite.createNamespace(name)
+    ns.AddRole("dev")
+    ns.GrantPermissions("dev", "create.log", "select")
+
+    // Check permissions
+    suite.Equal([]string{"create.log", "select"}, ns.Permissions("dev"))
+    suite.Nil(ns.Permissions("none"))
+}
+
+func (suite *NamespaceTestSuite) TestChildren() {
+    name := "acme.children"
+
+    // Create namespaces
+    suite.createNamespace(name)
+    suite.createNamespace(name + ".a")
+    suite.createNamespace(name + ".a.b")
+    suite.createNamespace(name + "x")
+
+    // Check children
+    suite.Equal([]string{name + ".a", name + ".a.b"}, suite.NS.Children(name))
+    suite.Nil(suite.NS.Children(name + ".a.b"))
+}
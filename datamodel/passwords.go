@@ -0,0 +1,210 @@
+package datamodel
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/subsilent/kappa/common"
+)
+
+// Passwords are stored as encoded hashes of the form
+//
+//	$<hasher>$v=<version>$<parameters>$<salt>$<hash>
+//
+// where the salt and hash are base64 encoded without padding. The hasher identifies the PasswordHasher which
+// created the hash and the version the layout of its parameters, so existing hashes remain verifiable when the
+// default hasher or its cost parameters change. Hashes which weren't created by the default hasher with its current
+// parameters are replaced on the next successful login.
+
+// ErrInvalidPasswordHash is returned when an encoded password hash can't be decoded
+var ErrInvalidPasswordHash = common.NewError(common.InternalServerError, "invalid password hash")
+
+// PasswordHasher hashes passwords for storage
+type PasswordHasher interface {
+
+	// ID identifies the hasher in encoded hashes
+	ID() string
+
+	// Hash returns the encoded hash of a password using a new random salt
+	Hash(password []byte) (string, error)
+
+	// Verify determines if a password matches an encoded hash created by the hasher. Outdated is true if the hash
+	// was created with other parameters than those of the hasher and should be replaced.
+	Verify(password []byte, encoded string) (match, outdated bool, err error)
+}
+
+// PasswordHashers are the hashers which can verify stored passwords by their ID
+var PasswordHashers = map[string]PasswordHasher{}
+
+// DefaultPasswordHasher hashes new passwords
+var DefaultPasswordHasher PasswordHasher = NewPBKDF2Hasher(DefaultPBKDF2Iterations)
+
+func init() {
+	RegisterPasswordHasher(DefaultPasswordHasher)
+}
+
+// RegisterPasswordHasher adds a hasher to the hashers which can verify stored passwords. A hasher with the same ID
+// is replaced.
+func RegisterPasswordHasher(hasher PasswordHasher) {
+	PasswordHashers[hasher.ID()] = hasher
+}
+
+// HashPassword hashes a password with the default hasher
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordHasher.Hash([]byte(password))
+}
+
+// VerifyPassword determines if a password matches an encoded hash. Rehash is true if the password matches but the
+// hash wasn't created by the default hasher with its current parameters.
+func VerifyPassword(password, encoded string) (match, rehash bool) {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		return false, false
+	}
+
+	hasher, ok := PasswordHashers[parts[1]]
+	if !ok {
+		return false, false
+	}
+
+	match, outdated, err := hasher.Verify([]byte(password), encoded)
+	if err != nil || !match {
+		return false, false
+	}
+	return true, outdated || hasher.ID() != DefaultPasswordHasher.ID()
+}
+
+// noPassword is the hash passwords are verified against when there is no user or the user has no password
+var noPassword struct {
+	sync.Once
+	encoded string
+}
+
+// RejectPassword verifies a password against a hash of the default hasher which no password matches. Rejecting
+// passwords of unknown users or of users without a password takes as long as verifying those of other users, so
+// the time taken doesn't reveal which users exist.
+func RejectPassword(password string) bool {
+	noPassword.Do(func() {
+		salt := make([]byte, 16)
+		io.ReadFull(rand.Reader, salt)
+		noPassword.encoded, _ = HashPassword(base64.RawStdEncoding.EncodeToString(salt))
+	})
+	VerifyPassword(password, noPassword.encoded)
+	return false
+}
+
+// PBKDF2 parameters
+const (
+
+	// DefaultPBKDF2Iterations is the number of iterations used by the default hasher
+	DefaultPBKDF2Iterations = 600000
+
+	// pbkdf2Version is the version of the PBKDF2 hash encoding
+	pbkdf2Version = 1
+)
+
+// PBKDF2Hasher hashes passwords with PBKDF2-HMAC-SHA256 as defined by RFC 8018. Hashes are encoded as
+//
+//	$pbkdf2-sha256$v=1$i=<iterations>$<salt>$<hash>
+type PBKDF2Hasher struct {
+
+	// Iterations is the cost of hashing a password
+	Iterations int
+
+	// SaltSize and KeySize are the sizes of the salt and of the derived key in bytes
+	SaltSize int
+	KeySize  int
+}
+
+// NewPBKDF2Hasher creates a PBKDF2 hasher with the given number of iterations, 16 byte salts and 32 byte keys
+func NewPBKDF2Hasher(iterations int) *PBKDF2Hasher {
+	return &PBKDF2Hasher{Iterations: iterations, SaltSize: 16, KeySize: sha256.Size}
+}
+
+// ID identifies the hasher in encoded hashes
+func (h *PBKDF2Hasher) ID() string {
+	return "pbkdf2-sha256"
+}
+
+// Hash returns the encoded hash of a password using a new random salt
+func (h *PBKDF2Hasher) Hash(password []byte) (string, error) {
+	salt := make([]byte, h.SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+
+	key := PBKDF2(password, salt, h.Iterations, h.KeySize)
+	return fmt.Sprintf("$%s$v=%d$i=%d$%s$%s", h.ID(), pbkdf2Version, h.Iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify determines if a password matches an encoded hash created by the hasher
+func (h *PBKDF2Hasher) Verify(password []byte, encoded string) (match, outdated bool, err error) {
+
+	// Decode hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != h.ID() || parts[2] != "v="+strconv.Itoa(pbkdf2Version) ||
+		!strings.HasPrefix(parts[3], "i=") {
+		return false, false, ErrInvalidPasswordHash
+	}
+
+	iterations, err := strconv.Atoi(strings.TrimPrefix(parts[3], "i="))
+	if err != nil || iterations <= 0 {
+		return false, false, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrInvalidPasswordHash
+	}
+
+	// Compare keys
+	match = SecureCompare(PBKDF2(password, salt, iterations, len(key)), key)
+	outdated = iterations != h.Iterations || len(salt) != h.SaltSize || len(key) != h.KeySize
+	return match, outdated, nil
+}
+
+// PBKDF2 derives a key of the given size from a password with PBKDF2-HMAC-SHA256
+func PBKDF2(password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (size + prf.Size() - 1) / prf.Size()
+
+	var index [4]byte
+	key := make([]byte, 0, blocks*prf.Size())
+	u := make([]byte, 0, prf.Size())
+	for block := 1; block <= blocks; block++ {
+
+		// The first iteration hashes the salt and the block index
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(index[:], uint32(block))
+		prf.Write(index[:])
+		u = prf.Sum(u[:0])
+
+		// Later iterations hash the previous one and all of them are combined
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:size]
+}
@@ -0,0 +1,155 @@
+package datamodel
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure keys match the PBKDF2-HMAC-SHA256 test vectors of RFC 7914
+func TestPBKDF2(t *testing.T) {
+	var tests = []struct {
+		password   string
+		salt       string
+		iterations int
+		key        string
+	}{
+		{
+			password: "passwd", salt: "salt", iterations: 1,
+			key: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		},
+		{
+			password: "Password", salt: "NaCl", iterations: 80000,
+			key: "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+				"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d",
+		},
+	}
+
+	for i, tt := range tests {
+		key := PBKDF2([]byte(tt.password), []byte(tt.salt), tt.iterations, 64)
+		assert.Equal(t, tt.key, hex.EncodeToString(key), "%d. %s", i, tt.password)
+
+		// Shorter keys are prefixes of longer ones
+		key = PBKDF2([]byte(tt.password), []byte(tt.salt), tt.iterations, 20)
+		assert.Equal(t, tt.key[:40], hex.EncodeToString(key), "%d. %s", i, tt.password)
+	}
+}
+
+// Ensure PBKDF2 hashes are encoded with their parameters and verified
+func TestPBKDF2Hasher(t *testing.T) {
+	hasher := NewPBKDF2Hasher(1000)
+	encoded, err := hasher.Hash([]byte("password"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$pbkdf2-sha256$v=1$i=1000$"), encoded)
+
+	// Salts are random
+	other, err := hasher.Hash([]byte("password"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, encoded, other)
+
+	match, outdated, err := hasher.Verify([]byte("password"), encoded)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.False(t, outdated)
+
+	match, _, err = hasher.Verify([]byte("Password"), encoded)
+	assert.Nil(t, err)
+	assert.False(t, match)
+
+	// Hashes with other parameters are outdated
+	match, outdated, err = NewPBKDF2Hasher(2000).Verify([]byte("password"), encoded)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.True(t, outdated)
+}
+
+// Ensure invalid PBKDF2 hashes are rejected
+func TestPBKDF2HasherInvalid(t *testing.T) {
+	hasher := NewPBKDF2Hasher(1000)
+	for _, encoded := range []string{
+		"",
+		"password",
+		"$pbkdf2-sha256$v=1$i=1000$c2FsdA",
+		"$pbkdf2-sha512$v=1$i=1000$c2FsdA$a2V5",
+		"$pbkdf2-sha256$v=2$i=1000$c2FsdA$a2V5",
+		"$pbkdf2-sha256$v=1$n=1000$c2FsdA$a2V5",
+		"$pbkdf2-sha256$v=1$i=0$c2FsdA$a2V5",
+		"$pbkdf2-sha256$v=1$i=1000$!$a2V5",
+		"$pbkdf2-sha256$v=1$i=1000$c2FsdA$",
+	} {
+		match, _, err := hasher.Verify([]byte("password"), encoded)
+		assert.Equal(t, ErrInvalidPasswordHash, err, encoded)
+		assert.False(t, match, encoded)
+	}
+}
+
+// Ensure passwords are verified by the hasher which created them and rehashed with the default hasher
+func TestVerifyPassword(t *testing.T) {
+	encoded, err := HashPassword("password")
+	assert.Nil(t, err)
+
+	match, rehash := VerifyPassword("password", encoded)
+	assert.True(t, match)
+	assert.False(t, rehash)
+
+	match, rehash = VerifyPassword("Password", encoded)
+	assert.False(t, match)
+	assert.False(t, rehash)
+
+	// Hashes with other parameters are rehashed
+	outdated, err := NewPBKDF2Hasher(1000).Hash([]byte("password"))
+	assert.Nil(t, err)
+	match, rehash = VerifyPassword("password", outdated)
+	assert.True(t, match)
+	assert.True(t, rehash)
+
+	// Hashes of unknown hashers never match
+	match, rehash = VerifyPassword("password", "$plain$v=1$password")
+	assert.False(t, match)
+	assert.False(t, rehash)
+	match, _ = VerifyPassword("password", "password")
+	assert.False(t, match)
+}
+
+// Ensure hashes of registered hashers are verified and rehashed with the default hasher
+func TestRegisterPasswordHasher(t *testing.T) {
+	RegisterPasswordHasher(legacyHasher{})
+	defer delete(PasswordHashers, legacyHasher{}.ID())
+
+	encoded, err := legacyHasher{}.Hash([]byte("password"))
+	assert.Nil(t, err)
+
+	match, rehash := VerifyPassword("password", encoded)
+	assert.True(t, match)
+	assert.True(t, rehash)
+}
+
+// legacyHasher encodes salted SHA-256 passwords created by GenerateSalt
+type legacyHasher struct{}
+
+func (legacyHasher) ID() string { return "sha256" }
+
+func (legacyHasher) Hash(password []byte) (string, error) {
+	salt, saltedpw, err := GenerateSalt(password)
+	return "$sha256$v=1$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(saltedpw), err
+}
+
+func (legacyHasher) Verify(password []byte, encoded string) (bool, bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return false, false, ErrInvalidPasswordHash
+	}
+	salt, _ := hex.DecodeString(parts[3])
+	saltedpw, _ := hex.DecodeString(parts[4])
+	return ValidateSalt(salt, saltedpw, password), false, nil
+}
+
+// Ensure passwords are rejected by verifying them against a hash of the default hasher
+func TestRejectPassword(t *testing.T) {
+	assert.False(t, RejectPassword(""))
+	assert.False(t, RejectPassword("password"))
+	assert.True(t, strings.HasPrefix(noPassword.encoded, "$"+DefaultPasswordHasher.ID()+"$"), noPassword.encoded)
+}
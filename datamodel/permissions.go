@@ -0,0 +1,166 @@
+package datamodel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permissions granted to roles are period delimited names such as 'create.log'. The last part of a permission entry
+// may be a wildcard which matches the permission before it and every permission below it, so 'create.*' matches
+// 'create' and 'create.log' and '*' matches every permission. Entries starting with the deny prefix deny the
+// permissions they match rather than granting them.
+const (
+
+	// Wildcard is the last part of permission entries matching every permission below the preceding parts
+	Wildcard = "*"
+
+	// DenyPrefix starts permission entries which deny access
+	DenyPrefix = "-"
+)
+
+// ParentNamespace returns the parent of a namespace or an empty string for root namespaces
+func ParentNamespace(namespace string) string {
+	if index := strings.LastIndex(namespace, "."); index >= 0 {
+		return namespace[:index]
+	}
+	return ""
+}
+
+// MatchPermission determines if a permission entry matches a permission. The deny prefix of the entry is ignored.
+// The specificity ranks matching entries: an exact entry ranks above every wildcard matching the permission and
+// wildcards rank by the number of parts before them.
+func MatchPermission(entry, permission string) (specificity int, ok bool) {
+	entry = strings.TrimPrefix(entry, DenyPrefix)
+	if entry == permission {
+		return strings.Count(permission, ".") + 2, true
+	} else if entry == Wildcard {
+		return 0, true
+	}
+
+	prefix := strings.TrimSuffix(entry, "."+Wildcard)
+	if prefix == entry {
+		return 0, false
+	} else if permission == prefix || strings.HasPrefix(permission, prefix+".") {
+		return strings.Count(prefix, ".") + 1, true
+	}
+	return 0, false
+}
+
+// match is a permission entry of a role matching a permission
+type match struct {
+	role        string
+	entry       string
+	specificity int
+}
+
+// deny is true if the entry denies the permission
+func (m match) deny() bool {
+	return strings.HasPrefix(m.entry, DenyPrefix)
+}
+
+// decides determines if the entry decides over another match. The most specific entry decides and deny entries
+// decide over allow entries which are as specific. Otherwise the first match decides.
+func (m match) decides(other *match) bool {
+	if other == nil || m.specificity > other.specificity {
+		return true
+	}
+	return m.specificity == other.specificity && m.deny() && !other.deny()
+}
+
+// bestMatch returns the entry of a role deciding a permission or nil if no entry matches it
+func bestMatch(best *match, role string, entries []string, permission string) *match {
+	for _, entry := range entries {
+		if specificity, ok := MatchPermission(entry, permission); ok {
+			if m := (match{role, entry, specificity}); m.decides(best) {
+				best = &m
+			}
+		}
+	}
+	return best
+}
+
+// Decision is the outcome of resolving a permission for a user and the reason for it
+type Decision struct {
+
+	// Allowed is true if the permission was granted
+	Allowed bool
+
+	// Admin is true if the permission was granted because the user is a superuser
+	Admin bool
+
+	// Permission and Namespace are the permission which was resolved and the namespace it was resolved for
+	Permission string
+	Namespace  string
+
+	// Source is the namespace of the role which decided. It is either the namespace the permission was resolved for
+	// or one of its parents. Source, Role and Entry are empty if no entry matched the permission.
+	Source string
+
+	// Role is the role of the user with the entry which decided
+	Role string
+
+	// Entry is the permission entry which decided
+	Entry string
+}
+
+// Reason explains the decision
+func (d Decision) Reason() string {
+	switch {
+	case d.Admin:
+		return "superusers have every permission"
+	case d.Entry == "":
+		return fmt.Sprintf("no role grants '%s' on namespace '%s' or its parents", d.Permission, d.Namespace)
+	}
+
+	verb := "granted"
+	if !d.Allowed {
+		verb = "denied"
+	}
+	reason := fmt.Sprintf("%s by '%s' of role '%s' on namespace '%s'", verb, d.Entry, d.Role, d.Source)
+	if d.Source != d.Namespace {
+		reason += " and inherited by '" + d.Namespace + "'"
+	}
+	return reason
+}
+
+// ResolvePermission determines if a user has been granted a permission for a namespace and explains why.
+//
+// The roles a user has for a namespace are inherited by its children. The namespace is searched first followed by
+// each of its parents up to the root, and the first namespace in which one of the user's roles has an entry matching
+// the permission decides, so roles of child namespaces override those of their parents. Within a namespace the most
+// specific matching entry decides and deny entries decide over allow entries which are as specific. Roles are
+// searched by name and their entries in the order they were granted, so the first of several equal entries decides.
+// Permissions without a matching entry are denied. Superusers have every permission.
+func ResolvePermission(namespaces NamespaceStore, user User, namespace, permission string) Decision {
+	decision := Decision{Permission: permission, Namespace: namespace}
+	if user.IsAdmin() {
+		decision.Allowed, decision.Admin = true, true
+		return decision
+	}
+
+	for source := namespace; source != ""; source = ParentNamespace(source) {
+		roles := user.Roles(source)
+		if len(roles) == 0 {
+			continue
+		}
+
+		ns, err := namespaces.Get(source)
+		if err != nil {
+			continue
+		}
+
+		sort.Strings(roles)
+		var best *match
+		for _, role := range roles {
+			best = bestMatch(best, role, ns.Permissions(role), permission)
+		}
+
+		if best != nil {
+			decision.Allowed = !best.deny()
+			decision.Source, decision.Role, decision.Entry = source, best.role, best.entry
+			return decision
+		}
+	}
+	return decision
+}
@@ -0,0 +1,249 @@
+package datamodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"testing"
+
+	"github.com/eliquious/leaf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// Ensure permission entries match permissions and rank by specificity
+func TestMatchPermission(t *testing.T) {
+	var tests = []struct {
+		entry       string
+		permission  string
+		ok          bool
+		specificity int
+	}{
+		{entry: "select", permission: "select", ok: true, specificity: 2},
+		{entry: "create.log", permission: "create.log", ok: true, specificity: 3},
+		{entry: "-create.log", permission: "create.log", ok: true, specificity: 3},
+		{entry: "create.*", permission: "create.log", ok: true, specificity: 1},
+		{entry: "create.*", permission: "create", ok: true, specificity: 1},
+		{entry: "create.log.*", permission: "create.log.view", ok: true, specificity: 2},
+		{entry: "*", permission: "drop.namespace", ok: true, specificity: 0},
+		{entry: "-*", permission: "select", ok: true, specificity: 0},
+		{entry: "create", permission: "create.log"},
+		{entry: "create.log", permission: "create"},
+		{entry: "create.*", permission: "createlog"},
+		{entry: "drop.*", permission: "create.log"},
+		{entry: "select*", permission: "select"},
+	}
+
+	for i, tt := range tests {
+		specificity, ok := MatchPermission(tt.entry, tt.permission)
+		assert.Equal(t, tt.ok, ok, "%d. %s matches %s", i, tt.entry, tt.permission)
+		assert.Equal(t, tt.specificity, specificity, "%d. %s matches %s", i, tt.entry, tt.permission)
+	}
+}
+
+// Ensure parent namespaces are found
+func TestParentNamespace(t *testing.T) {
+	assert.Equal(t, "", ParentNamespace("acme"))
+	assert.Equal(t, "acme", ParentNamespace("acme.metrics"))
+	assert.Equal(t, "acme.metrics", ParentNamespace("acme.metrics.cpu"))
+}
+
+// TestPermissionTestSuite runs the PermissionTestSuite
+func TestPermissionTestSuite(t *testing.T) {
+	suite.Run(t, new(PermissionTestSuite))
+}
+
+// PermissionTestSuite tests permission resolution across namespaces
+type PermissionTestSuite struct {
+	suite.Suite
+	Dir string
+	DB  leaf.KeyValueDatabase
+	NS  NamespaceStore
+	US  UserStore
+}
+
+// SetupSuite prepares the suite before any tests are ran
+func (suite *PermissionTestSuite) SetupSuite() {
+
+	// Create temp directory
+	suite.Dir, _ = ioutil.TempDir("", "datamodel.test")
+
+	// Connect to database
+	db, err := leaf.NewLeaf(path.Join(suite.Dir, "test.db"))
+	if err != nil {
+		suite.T().Log("Error creating database")
+		suite.T().FailNow()
+	}
+	suite.DB = db
+
+	// Create namespace store
+	ks, err := db.GetOrCreateKeyspace(Namespaces)
+	suite.Nil(err)
+	suite.NS = NewBoltNamespaceStore(ks)
+
+	// Create user store
+	ks, err = db.GetOrCreateKeyspace(Users)
+	suite.Nil(err)
+	suite.US = NewBoltUserStore(ks)
+}
+
+// TearDownSuite cleans up suite state after all the tests have completed
+func (suite *PermissionTestSuite) TearDownSuite() {
+
+	// Close database
+	suite.DB.Close()
+
+	// Clear test directory
+	os.RemoveAll(suite.Dir)
+}
+
+// createRole creates a namespace if it does not exist and a role with the given permission entries
+func (suite *PermissionTestSuite) createRole(namespace, role string, permissions ...string) {
+	ns, err := suite.NS.Get(namespace)
+	if err == ErrNamespaceDoesNotExist {
+		ns, err = suite.NS.Create(namespace)
+	}
+	suite.Nil(err)
+	suite.Nil(ns.AddRole(role))
+	suite.Nil(ns.GrantPermissions(role, permissions...))
+}
+
+// createUser creates a user with a role for each namespace
+func (suite *PermissionTestSuite) createUser(username string, roles map[string]string) User {
+	user, err := suite.US.Create(username)
+	suite.Nil(err)
+	for namespace, role := range roles {
+		suite.Nil(user.AddRole(namespace, role))
+	}
+	return user
+}
+
+// TestHasPermissionWildcards ensures the entries of a single role may be wildcards and denials
+func (suite *PermissionTestSuite) TestHasPermissionWildcards() {
+	suite.createRole("wildcards", "writer", "create.*", "-create.namespace", "insert.log")
+	ns, err := suite.NS.Get("wildcards")
+	suite.Nil(err)
+
+	suite.True(ns.HasPermission("writer", "create.log"))
+	suite.True(ns.HasPermission("writer", "create.view"))
+	suite.True(ns.HasPermission("writer", "insert.log"))
+	suite.False(ns.HasPermission("writer", "create.namespace"))
+	suite.False(ns.HasPermission("writer", "drop.log"))
+	suite.False(ns.HasPermission("guest", "create.log"))
+}
+
+// TestResolveAdmin ensures superusers have every permission
+func (suite *PermissionTestSuite) TestResolveAdmin() {
+	admin := suite.createUser("root", nil)
+	suite.Nil(admin.SetAdmin(true))
+
+	decision := ResolvePermission(suite.NS, admin, "nowhere", "drop.namespace")
+	suite.True(decision.Allowed)
+	suite.True(decision.Admin)
+	suite.Equal("superusers have every permission", decision.Reason())
+}
+
+// TestResolveWithoutRoles ensures permissions are denied without a matching entry
+func (suite *PermissionTestSuite) TestResolveWithoutRoles() {
+	suite.createRole("empty", "reader", "select.log")
+	user := suite.createUser("empty.user", map[string]string{"empty": "reader"})
+
+	decision := ResolvePermission(suite.NS, user, "empty", "insert.log")
+	suite.False(decision.Allowed)
+	suite.Equal(Decision{Permission: "insert.log", Namespace: "empty"}, decision)
+	suite.Equal("no role grants 'insert.log' on namespace 'empty' or its parents", decision.Reason())
+
+	decision = ResolvePermission(suite.NS, user, "other", "select.log")
+	suite.False(decision.Allowed)
+	suite.Equal("", decision.Source)
+}
+
+// TestResolveInherited ensures roles of a namespace apply to its children
+func (suite *PermissionTestSuite) TestResolveInherited() {
+	suite.createRole("inherit", "reader", "select.*")
+	suite.createRole("inherit.metrics", "reader")
+	suite.createRole("inherit.metrics.cpu", "reader")
+	user := suite.createUser("inherit.user", map[string]string{"inherit": "reader"})
+
+	decision := ResolvePermission(suite.NS, user, "inherit.metrics.cpu", "select.log")
+	suite.True(decision.Allowed)
+	suite.Equal(Decision{
+		Allowed:    true,
+		Permission: "select.log",
+		Namespace:  "inherit.metrics.cpu",
+		Source:     "inherit",
+		Role:       "reader",
+		Entry:      "select.*",
+	}, decision)
+	suite.Equal("granted by 'select.*' of role 'reader' on namespace 'inherit' and inherited by 'inherit.metrics.cpu'",
+		decision.Reason())
+
+	// Namespaces named alike are not children
+	decision = ResolvePermission(suite.NS, user, "inheritance", "select.log")
+	suite.False(decision.Allowed)
+}
+
+// TestResolveOverride ensures roles of a child namespace override those of its parents
+func (suite *PermissionTestSuite) TestResolveOverride() {
+	suite.createRole("override", "writer", "*")
+	suite.createRole("override.audit", "auditor", "select.log", "-insert.*")
+	user := suite.createUser("override.user", map[string]string{
+		"override":       "writer",
+		"override.audit": "auditor",
+	})
+
+	// Denied by the child namespace
+	decision := ResolvePermission(suite.NS, user, "override.audit", "insert.log")
+	suite.False(decision.Allowed)
+	suite.Equal("override.audit", decision.Source)
+	suite.Equal("-insert.*", decision.Entry)
+	suite.Equal("denied by '-insert.*' of role 'auditor' on namespace 'override.audit'", decision.Reason())
+
+	// Granted by the child namespace
+	decision = ResolvePermission(suite.NS, user, "override.audit", "select.log")
+	suite.True(decision.Allowed)
+	suite.Equal("override.audit", decision.Source)
+
+	// Entries of the child namespace which don't match fall back to the parent
+	decision = ResolvePermission(suite.NS, user, "override.audit", "create.log")
+	suite.True(decision.Allowed)
+	suite.Equal("override", decision.Source)
+	suite.Equal("*", decision.Entry)
+}
+
+// TestResolveSpecificity ensures the most specific entry decides and denials decide ties
+func (suite *PermissionTestSuite) TestResolveSpecificity() {
+	suite.createRole("specific", "reader", "-*", "select.log")
+	suite.createRole("specific", "writer", "insert.*", "-insert.log")
+	suite.createRole("specific", "operator", "insert.log", "drop.*")
+	suite.createRole("specific", "guard", "-drop.*")
+	user := suite.createUser("specific.user", map[string]string{"specific": "reader"})
+	suite.Nil(user.AddRole("specific", "writer"))
+	suite.Nil(user.AddRole("specific", "operator"))
+	suite.Nil(user.AddRole("specific", "guard"))
+
+	var tests = []struct {
+		permission string
+		allowed    bool
+		role       string
+		entry      string
+	}{
+
+		// An exact entry decides over a wildcard
+		{permission: "select.log", allowed: true, role: "reader", entry: "select.log"},
+		{permission: "subscribe.log", allowed: false, role: "reader", entry: "-*"},
+		{permission: "insert.view", allowed: true, role: "writer", entry: "insert.*"},
+
+		// Denials decide over equally specific grants of other roles
+		{permission: "insert.log", allowed: false, role: "writer", entry: "-insert.log"},
+		{permission: "drop.log", allowed: false, role: "guard", entry: "-drop.*"},
+	}
+
+	for _, tt := range tests {
+		decision := ResolvePermission(suite.NS, user, "specific", tt.permission)
+		suite.Equal(tt.allowed, decision.Allowed, tt.permission)
+		suite.Equal(tt.role, decision.Role, tt.permission)
+		suite.Equal(tt.entry, decision.Entry, tt.permission)
+	}
+}
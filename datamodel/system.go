@@ -1,6 +1,9 @@
 package datamodel
 
-import "github.com/eliquious/leaf"
+import (
+    "github.com/eliquious/leaf"
+    "github.com/subsilent/kappa/storage"
+)
 
 const (
 
@@ -9,28 +12,41 @@ const (
 
     // Namespaces is the name of the namespace keyspace
     Namespaces = "namespaces"
+
+    // Logs is the name of the log keyspace
+    Logs = "logs"
+
+    // Types is the name of the type keyspace
+    Types = "types"
+
+    // Views is the name of the view keyspace
+    Views = "views"
 )
 
 // System provides an interface for accessing information about the database.
 type System interface {
     Users() (UserStore, error)
     Namespaces() (NamespaceStore, error)
+    Logs() (LogStore, error)
+    Types() (TypeStore, error)
+    Views() (ViewStore, error)
 
     Close()
 }
 
-// NewSystem creates a database connection to access system metadata
-func NewSystem(filename string) (System, error) {
+// NewSystem creates a database connection to access system metadata. Log records are stored using the given engine.
+func NewSystem(filename string, engine storage.Engine) (System, error) {
     leaf, err := leaf.NewLeaf(filename)
     if err != nil {
         return nil, err
     }
-    return &BoltSystemStore{leaf}, nil
+    return &BoltSystemStore{leaf, engine}, nil
 }
 
 // BoltSystemStore implements the System interface on top of a boltdb connection
 type BoltSystemStore struct {
-    db leaf.KeyValueDatabase
+    db     leaf.KeyValueDatabase
+    engine storage.Engine
 }
 
 // Users returns a UserStore
@@ -51,7 +67,35 @@ func (s BoltSystemStore) Namespaces() (NamespaceStore, error) {
     return NewBoltNamespaceStore(ks), nil
 }
 
-// Close closes the database connection
+// Logs returns a LogStore
+func (s BoltSystemStore) Logs() (LogStore, error) {
+    ks, err := s.db.GetOrCreateKeyspace(Logs)
+    if err != nil {
+        return nil, err
+    }
+    return NewBoltLogStore(ks, s.engine), nil
+}
+
+// Types returns a TypeStore
+func (s BoltSystemStore) Types() (TypeStore, error) {
+    ks, err := s.db.GetOrCreateKeyspace(Types)
+    if err != nil {
+        return nil, err
+    }
+    return NewBoltTypeStore(ks), nil
+}
+
+// Views returns a ViewStore
+func (s BoltSystemStore) Views() (ViewStore, error) {
+    ks, err := s.db.GetOrCreateKeyspace(Views)
+    if err != nil {
+        return nil, err
+    }
+    return NewBoltViewStore(ks), nil
+}
+
+// Close closes the database connection and all open logs
 func (s BoltSystemStore) Close() {
+    s.engine.Close()
     s.db.Close()
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/eliquious/leaf"
 	"github.com/stretchr/testify/suite"
+	"github.com/subsilent/kappa/storage"
 )
 
 // TestSystemTestSuite runs the SystemTestSuite
@@ -36,7 +37,7 @@ func (suite *SystemTestSuite) SetupSuite() {
 		suite.T().FailNow()
 	}
 	suite.DB = db
-	suite.System = BoltSystemStore{db}
+	suite.System = BoltSystemStore{db, storage.NewEngine(path.Join(suite.Dir, "logs"), storage.Options{})}
 }
 
 // TearDownSuite cleans up suite state after all the tests have completed
@@ -68,3 +69,21 @@ func (suite *SystemTestSuite) TestGetUserStore() {
 	suite.Nil(err)
 	suite.NotNil(nss)
 }
+
+func (suite *SystemTestSuite) TestGetLogStore() {
+	logs, err := suite.System.Logs()
+	suite.Nil(err)
+	suite.NotNil(logs)
+}
+
+func (suite *SystemTestSuite) TestGetTypeStore() {
+	types, err := suite.System.Types()
+	suite.Nil(err)
+	suite.NotNil(types)
+}
+
+func (suite *SystemTestSuite) TestGetViewStore() {
+	views, err := suite.System.Views()
+	suite.Nil(err)
+	suite.NotNil(views)
+}
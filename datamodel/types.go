@@ -0,0 +1,389 @@
+package datamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/subsilent/kappa/common"
+)
+
+var (
+
+	// ErrTypeDoesNotExist is returned if a type does not exist when an operation is attempted to be performed on it
+	ErrTypeDoesNotExist = common.NewError(common.TypeDoesNotExist, "type does not exist")
+
+	// ErrTypeAlreadyExists is returned when creating a type which already exists
+	ErrTypeAlreadyExists = common.NewError(common.TypeAlreadyExists, "type already exists")
+
+	// ErrInvalidFieldType is returned when creating a type with an unknown field type
+	ErrInvalidFieldType = common.NewError(common.CreateTypeError, "invalid field type")
+)
+
+// FieldType is the name of a built-in field type
+type FieldType string
+
+// Built-in field types. The names match the type tokens of the query language.
+const (
+	StringField    FieldType = "string"
+	Uint8Field     FieldType = "uint8"
+	Uint16Field    FieldType = "uint16"
+	Uint32Field    FieldType = "uint32"
+	Uint64Field    FieldType = "uint64"
+	Int8Field      FieldType = "int8"
+	Int16Field     FieldType = "int16"
+	Int32Field     FieldType = "int32"
+	Int64Field     FieldType = "int64"
+	Float32Field   FieldType = "float32"
+	Float64Field   FieldType = "float64"
+	TimestampField FieldType = "timestamp"
+	BooleanField   FieldType = "boolean"
+)
+
+// TimestampFormats are the accepted string layouts for timestamp fields
+var TimestampFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Valid determines if the field type is a built-in type
+func (t FieldType) Valid() bool {
+	switch t {
+	case StringField, Uint8Field, Uint16Field, Uint32Field, Uint64Field, Int8Field, Int16Field, Int32Field, Int64Field,
+		Float32Field, Float64Field, TimestampField, BooleanField:
+		return true
+	}
+	return false
+}
+
+// Field is a named field of a type
+type Field struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required"`
+}
+
+// Type is a record schema. Logs declaring a type only accept records which validate against it.
+type Type interface {
+
+	// Name returns the fully qualified type name
+	Name() string
+
+	// Namespace returns the namespace the type belongs to
+	Namespace() string
+
+	// Fields returns the field definitions in declaration order
+	Fields() []Field
+
+	// Validate verifies a record against the schema and returns a copy with values coerced to the field types.
+	// Integers are returned as int64 or uint64, floats as float64 and timestamps as time.Time.
+	Validate(record map[string]interface{}) (map[string]interface{}, error)
+}
+
+// TypeStore contains type definitions
+type TypeStore interface {
+
+	// Get returns a Type by name
+	Get(name string) (Type, error)
+
+	// Create inserts a new type into the given namespace
+	Create(namespace, name string, fields []Field) (Type, error)
+
+	// Delete removes a type
+	Delete(name string) error
+
+	// Stream returns a channel of type names
+	Stream() chan string
+}
+
+// NewBoltTypeStore creates a new TypeStore using the given keyspace
+func NewBoltTypeStore(ks leaf.Keyspace) TypeStore {
+	return &boltTypeStore{ks}
+}
+
+type boltTypeStore struct {
+	ks leaf.Keyspace
+}
+
+// Create adds a type to the database
+func (b boltTypeStore) Create(namespace, name string, fields []Field) (t Type, err error) {
+
+	// Verify field types
+	for _, field := range fields {
+		if !field.Type.Valid() {
+			return nil, ErrInvalidFieldType
+		}
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Verify the type does not exist
+		if bkt.Bucket([]byte(name)) != nil {
+			err = ErrTypeAlreadyExists
+			return
+		}
+
+		// Create bucket
+		typeBucket, e := bkt.CreateBucket([]byte(name))
+		if e != nil {
+			err = e
+			return
+		}
+
+		// Save type definition
+		if err = typeBucket.Put([]byte("namespace"), []byte(namespace)); err != nil {
+			return
+		}
+		if err = typeBucket.Put([]byte("fields"), encoded); err != nil {
+			return
+		}
+		t = schema{name, namespace, fields}
+		return
+	})
+	return
+}
+
+// Get returns a Type, returning an error if it doesn't exist
+func (b boltTypeStore) Get(name string) (t Type, err error) {
+	b.ks.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get type bucket
+		typeBucket := bkt.Bucket([]byte(name))
+		if typeBucket == nil {
+			err = ErrTypeDoesNotExist
+			return
+		}
+
+		// Decode definition
+		var fields []Field
+		if err = json.Unmarshal(typeBucket.Get([]byte("fields")), &fields); err != nil {
+			return
+		}
+		t = schema{name, string(typeBucket.Get([]byte("namespace"))), fields}
+		return
+	})
+	return
+}
+
+// Delete removes a type definition
+func (b boltTypeStore) Delete(name string) (err error) {
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Delete bucket
+		if err = bkt.DeleteBucket([]byte(name)); err == bolt.ErrBucketNotFound {
+			err = ErrTypeDoesNotExist
+		}
+		return
+	})
+	return
+}
+
+// Stream returns a channel of type names
+func (b boltTypeStore) Stream() chan string {
+	out := make(chan string)
+
+	// Read types in background
+	go func(channel chan<- string) {
+		b.ks.ReadTx(func(bkt *bolt.Bucket) {
+			cur := bkt.Cursor()
+
+			// Iterate over keys
+			for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+				channel <- string(k)
+			}
+
+			// Close channel
+			close(channel)
+			return
+		})
+	}(out)
+	return out
+}
+
+// schema implements the Type interface
+//
+// Types are immutable once created so the definition is read once and kept in memory.
+type schema struct {
+	name      string
+	namespace string
+	fields    []Field
+}
+
+// Name returns the fully qualified type name
+func (s schema) Name() string {
+	return s.name
+}
+
+// Namespace returns the namespace the type belongs to
+func (s schema) Namespace() string {
+	return s.namespace
+}
+
+// Fields returns the field definitions in declaration order
+func (s schema) Fields() []Field {
+	return s.fields
+}
+
+// Validate verifies a record against the schema and returns a copy with values coerced to the field types
+func (s schema) Validate(record map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(record))
+
+	// Verify every field is declared. Names are sorted so errors are deterministic.
+	var names []string
+	for name := range record {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !s.declares(name) {
+			return nil, fmt.Errorf("unknown field '%s'", name)
+		}
+	}
+
+	for _, field := range s.fields {
+		value, ok := record[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				return nil, fmt.Errorf("missing required field '%s'", field.Name)
+			}
+			continue
+		}
+
+		coerced, err := coerce(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %s", field.Name, err)
+		}
+		out[field.Name] = coerced
+	}
+	return out, nil
+}
+
+// declares determines if the schema has a field with the given name
+func (s schema) declares(name string) bool {
+	for _, field := range s.fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// coerce converts a value to the Go representation of a field type
+func coerce(t FieldType, value interface{}) (interface{}, error) {
+	switch t {
+	case StringField:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case BooleanField:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case Int8Field:
+		return coerceInt(value, math.MinInt8, math.MaxInt8, t)
+	case Int16Field:
+		return coerceInt(value, math.MinInt16, math.MaxInt16, t)
+	case Int32Field:
+		return coerceInt(value, math.MinInt32, math.MaxInt32, t)
+	case Int64Field:
+		return coerceInt(value, math.MinInt64, math.MaxInt64, t)
+	case Uint8Field:
+		return coerceUint(value, math.MaxUint8, t)
+	case Uint16Field:
+		return coerceUint(value, math.MaxUint16, t)
+	case Uint32Field:
+		return coerceUint(value, math.MaxUint32, t)
+	case Uint64Field:
+		return coerceUint(value, math.MaxUint64, t)
+	case Float32Field, Float64Field:
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		default:
+			return nil, fmt.Errorf("expected %s, found %v", t, value)
+		}
+		if t == Float32Field && math.Abs(f) > math.MaxFloat32 {
+			return nil, fmt.Errorf("%v out of range for %s", value, t)
+		}
+		return f, nil
+	case TimestampField:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case string:
+			for _, layout := range TimestampFormats {
+				if ts, err := time.Parse(layout, v); err == nil {
+					return ts.UTC(), nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("expected %s, found %v", t, value)
+}
+
+// coerceInt converts an integral value to an int64 within the given range
+func coerceInt(value interface{}, min, max int64, t FieldType) (interface{}, error) {
+	var i int64
+	switch v := value.(type) {
+	case int64:
+		i = v
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("%v out of range for %s", value, t)
+		}
+		i = int64(v)
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Errorf("expected %s, found %v", t, value)
+		}
+		i = int64(v)
+	default:
+		return nil, fmt.Errorf("expected %s, found %v", t, value)
+	}
+
+	if i < min || i > max {
+		return nil, fmt.Errorf("%v out of range for %s", value, t)
+	}
+	return i, nil
+}
+
+// coerceUint converts an integral value to a uint64 within the given range
+func coerceUint(value interface{}, max uint64, t FieldType) (interface{}, error) {
+	var u uint64
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return nil, fmt.Errorf("%v out of range for %s", value, t)
+		}
+		u = uint64(v)
+	case uint64:
+		u = v
+	case float64:
+		if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+			return nil, fmt.Errorf("expected %s, found %v", t, value)
+		}
+		u = uint64(v)
+	default:
+		return nil, fmt.Errorf("expected %s, found %v", t, value)
+	}
+
+	if u > max {
+		return nil, fmt.Errorf("%v out of range for %s", value, t)
+	}
+	return u, nil
+}
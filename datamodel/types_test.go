@@ -0,0 +1,189 @@
+package datamodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/stretchr/testify/suite"
+)
+
+// TestTypeTestSuite runs the TypeTestSuite
+func TestTypeTestSuite(t *testing.T) {
+	suite.Run(t, new(TypeTestSuite))
+}
+
+// TypeTestSuite tests the type store
+type TypeTestSuite struct {
+	suite.Suite
+	Dir string
+	DB  leaf.KeyValueDatabase
+	TS  TypeStore
+	KS  leaf.Keyspace
+}
+
+// SetupSuite prepares the suite before any tests are ran
+func (suite *TypeTestSuite) SetupSuite() {
+
+	// Create temp directory
+	suite.Dir, _ = ioutil.TempDir("", "datamodel.test")
+
+	// Connect to database
+	db, err := leaf.NewLeaf(path.Join(suite.Dir, "test.db"))
+	if err != nil {
+		suite.T().Log("Error creating database")
+		suite.T().FailNow()
+	}
+	suite.DB = db
+
+	// Create keyspace
+	ks, err := db.GetOrCreateKeyspace(Types)
+	suite.Nil(err)
+	suite.KS = ks
+
+	// Create type store
+	suite.TS = NewBoltTypeStore(ks)
+}
+
+// TearDownSuite cleans up suite state after all the tests have completed
+func (suite *TypeTestSuite) TearDownSuite() {
+
+	// Close database
+	suite.DB.Close()
+
+	// Clear test directory
+	os.RemoveAll(suite.Dir)
+}
+
+// eventFields returns the fields used by the test types
+func eventFields() []Field {
+	return []Field{
+		{"id", Uint64Field, true},
+		{"name", StringField, false},
+		{"at", TimestampField, true},
+		{"level", Int8Field, false},
+		{"score", Float32Field, false},
+		{"active", BooleanField, false},
+	}
+}
+
+// TestCreateType ensures a type can be created
+func (suite *TypeTestSuite) TestCreateType() {
+	t, err := suite.TS.Create("acme", "acme.Create", eventFields())
+	suite.Nil(err)
+	suite.NotNil(t)
+	suite.Equal("acme.Create", t.Name())
+	suite.Equal("acme", t.Namespace())
+	suite.Equal(eventFields(), t.Fields())
+
+	// Test that the type was created
+	suite.KS.ReadTx(func(bkt *bolt.Bucket) {
+		suite.NotNil(bkt.Bucket([]byte("acme.Create")))
+	})
+
+	// Creating the type again fails
+	t, err = suite.TS.Create("acme", "acme.Create", eventFields())
+	suite.Equal(ErrTypeAlreadyExists, err)
+	suite.Nil(t)
+
+	// Unknown field types are rejected
+	t, err = suite.TS.Create("acme", "acme.Invalid", []Field{{"id", FieldType("uint128"), true}})
+	suite.Equal(ErrInvalidFieldType, err)
+	suite.Nil(t)
+}
+
+// TestGetType ensures a type can be retrieved
+func (suite *TypeTestSuite) TestGetType() {
+	t, err := suite.TS.Get("acme.None")
+	suite.Equal(ErrTypeDoesNotExist, err)
+	suite.Nil(t)
+
+	_, err = suite.TS.Create("acme", "acme.Get", eventFields())
+	suite.Nil(err)
+
+	t, err = suite.TS.Get("acme.Get")
+	suite.Nil(err)
+	suite.Equal("acme.Get", t.Name())
+	suite.Equal("acme", t.Namespace())
+	suite.Equal(eventFields(), t.Fields())
+}
+
+// TestDeleteType ensures a type can be deleted
+func (suite *TypeTestSuite) TestDeleteType() {
+	_, err := suite.TS.Create("acme", "acme.Delete", eventFields())
+	suite.Nil(err)
+
+	suite.Nil(suite.TS.Delete("acme.Delete"))
+	suite.Equal(ErrTypeDoesNotExist, suite.TS.Delete("acme.Delete"))
+}
+
+// TestStreamTypes ensures all types are streamed
+func (suite *TypeTestSuite) TestStreamTypes() {
+	_, err := suite.TS.Create("acme", "acme.Stream", eventFields())
+	suite.Nil(err)
+
+	var found bool
+	for name := range suite.TS.Stream() {
+		if name == "acme.Stream" {
+			found = true
+		}
+	}
+	suite.True(found)
+}
+
+// TestValidate ensures records are validated and coerced
+func (suite *TypeTestSuite) TestValidate() {
+	t, err := suite.TS.Create("acme", "acme.Validate", eventFields())
+	suite.Nil(err)
+
+	// Values are coerced to the field types
+	record, err := t.Validate(map[string]interface{}{
+		"id":     int64(1),
+		"name":   "login",
+		"at":     "2015-06-01T12:30:00Z",
+		"level":  float64(3),
+		"score":  int64(10),
+		"active": true,
+	})
+	suite.Nil(err)
+	suite.Equal(map[string]interface{}{
+		"id":     uint64(1),
+		"name":   "login",
+		"at":     time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC),
+		"level":  int64(3),
+		"score":  float64(10),
+		"active": true,
+	}, record)
+
+	// Optional fields may be omitted
+	record, err = t.Validate(map[string]interface{}{"id": int64(2), "at": "2015-06-01"})
+	suite.Nil(err)
+	suite.Len(record, 2)
+
+	var tests = []struct {
+		record map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"at": "2015-06-01"}, "missing required field 'id'"},
+		{map[string]interface{}{"id": int64(1), "at": "2015-06-01", "other": "x"}, "unknown field 'other'"},
+		{map[string]interface{}{"id": int64(-1), "at": "2015-06-01"}, "field 'id': -1 out of range for uint64"},
+		{map[string]interface{}{"id": 1.5, "at": "2015-06-01"}, "field 'id': expected uint64, found 1.5"},
+		{map[string]interface{}{"id": int64(1), "at": "yesterday"}, "field 'at': expected timestamp, found yesterday"},
+		{map[string]interface{}{"id": int64(1), "at": "2015-06-01", "level": int64(128)}, "field 'level': 128 out of range for int8"},
+		{map[string]interface{}{"id": int64(1), "at": "2015-06-01", "name": int64(1)}, "field 'name': expected string, found 1"},
+		{map[string]interface{}{"id": int64(1), "at": "2015-06-01", "active": "yes"}, "field 'active': expected boolean, found yes"},
+		{map[string]interface{}{"id": int64(1), "at": "2015-06-01", "score": 1e39}, "field 'score': 1e+39 out of range for float32"},
+	}
+
+	for _, test := range tests {
+		_, err := t.Validate(test.record)
+		if suite.NotNil(err) {
+			suite.Equal(test.err, err.Error())
+		}
+	}
+}
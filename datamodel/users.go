@@ -1,14 +1,14 @@
 package datamodel
 
 import (
-    "crypto/sha256"
-    "crypto/x509"
-    "encoding/pem"
-    "fmt"
+    "bytes"
+    "encoding/binary"
     "strings"
+    "time"
 
     "github.com/boltdb/bolt"
     "github.com/eliquious/leaf"
+    "github.com/subsilent/kappa/common"
     "github.com/subsilent/kappa/auth"
     "golang.org/x/crypto/ssh"
 )
@@ -16,19 +16,93 @@ import (
 var (
 
     // ErrUserDoesNotExist signifies that a user does not exist
-    ErrUserDoesNotExist = fmt.Errorf("user does not exist")
+    ErrUserDoesNotExist = common.NewError(common.UserDoesNotExist, "user does not exist")
 
     // ErrInvalidCertificate is returned when the certificate can't be decoded
-    ErrInvalidCertificate = fmt.Errorf("unable to load certificate")
+    ErrInvalidCertificate = common.NewError(common.InvalidPublicKey, "unable to load certificate")
+
+    // ErrInvalidPublicKey is returned when a public key is neither a certificate nor an OpenSSH public key
+    ErrInvalidPublicKey = common.NewError(common.InvalidPublicKey, "unable to parse public key")
 
     // ErrFailedKeyConvertion means that the public key could not be converted to an SSH key
-    ErrFailedKeyConvertion = fmt.Errorf("error converting public key to SSH key format")
+    ErrFailedKeyConvertion = common.NewError(common.InvalidPublicKey, "error converting public key to SSH key format")
+
+    // ErrLastSuperuser is returned when the last superuser would be demoted or deleted
+    ErrLastSuperuser = common.NewError(common.LastSuperuser, "the last superuser cannot be removed")
+
+    // ErrSuperuserExists is returned when bootstrapping a superuser while one already exists
+    ErrSuperuserExists = common.NewError(common.UserAlreadyExists, "a superuser already exists")
+
+    // ErrKeyDoesNotExist is returned if a key is not in the user's key ring
+    ErrKeyDoesNotExist = common.NewError(common.KeyDoesNotExist, "key does not exist")
+)
+
+// superuserKey is the key of the superuser flag in the user bucket
+var superuserKey = []byte("superuser")
+
+// Keys of the TOTP secret and of the time step of the last accepted verification code in the user bucket
+var (
+    totpSecretKey = []byte("totp_secret")
+    totpStepKey   = []byte("totp_step")
 )
 
+// Keys of the password hash in the user bucket. Passwords set before password hashers were introduced are stored as
+// a salt and a salted SHA-256 hash.
+var (
+    passwordHashKey   = []byte("password_hash")
+    saltKey           = []byte("salt")
+    saltedPasswordKey = []byte("salted_password")
+)
+
+// KeyAttributes are the properties of a public key. Keys without an expiry never expire and keys which aren't
+// restricted to namespaces can be used in every namespace the user has access to.
+type KeyAttributes struct {
+
+    // Comment describes the key, such as the pipeline using it
+    Comment string
+
+    // Created is the time the key was added. It is zero for keys added before keys had attributes.
+    Created time.Time
+
+    // Expires is the time from which the key can no longer be used to log in
+    Expires time.Time
+
+    // LastUsed is the last time the key was used to log in
+    LastUsed time.Time
+
+    // Namespaces restricts the key to these namespaces and their children
+    Namespaces []string
+
+    // ReadOnly restricts the key to statements which don't modify data
+    ReadOnly bool
+
+    // Command is a script which is executed instead of the shell or script requested when logging in with the key
+    Command string
+}
+
+// Expired determines if the key has expired at the given time
+func (a KeyAttributes) Expired(now time.Time) bool {
+    return !a.Expires.IsZero() && !now.Before(a.Expires)
+}
+
+// AllowsNamespace determines if the key can be used in a namespace
+func (a KeyAttributes) AllowsNamespace(namespace string) bool {
+    if len(a.Namespaces) == 0 {
+        return true
+    }
+    for _, allowed := range a.Namespaces {
+        if namespace == allowed || strings.HasPrefix(namespace, allowed+".") {
+            return true
+        }
+    }
+    return false
+}
+
 // PublicKey wraps an ssh.PublicKey byte array and simply provides methods for validation.
 type PublicKey struct {
     fingerprint []byte
     sshKey      []byte
+    attributes  KeyAttributes
 }
 
 // Fingerprint provides a string hash representing a PublicKey
@@ -36,16 +110,31 @@ func (p *PublicKey) Fingerprint() string {
     return string(p.fingerprint)
 }
 
+// Attributes returns the properties of the key
+func (p *PublicKey) Attributes() KeyAttributes {
+    return p.attributes
+}
+
 // Equals determines the equivalence of two PublicKeys
 func (p *PublicKey) Equals(key []byte) bool {
     return SecureCompare(p.sshKey, key)
 }
 
-// PublicKeyRing provides an interface for interacting with a user's public keys
+// PublicKeyRing provides an interface for interacting with a user's public keys. Keys are added in any of the formats
+// accepted by ParsePublicKey.
 type PublicKeyRing interface {
 
     // AddPublicKey simply adds a public key to the user's key ring
-    AddPublicKey(pemBytes []byte) (string, error)
+    AddPublicKey(keyBytes []byte) (string, error)
+
+    // AddPublicKeyWithAttributes adds a public key with attributes. The creation time is set to the current time.
+    AddPublicKeyWithAttributes(keyBytes []byte, attributes KeyAttributes) (string, error)
+
+    // Attributes returns the attributes of a key
+    Attributes(fingerprint string) (KeyAttributes, error)
+
+    // Touch records the time a key was used to log in
+    Touch(fingerprint string, used time.Time) error
 
     // RemovePublicKey will remove a public key from a user's key ring
     RemovePublicKey(fingerprint string) error
@@ -63,15 +152,28 @@ type User interface {
     // Username returns the user alias
     Username() string
 
-    // IsAdmin returns whether the user has admin priviliges
+    // IsAdmin returns whether the user is a superuser with admin priviliges
     IsAdmin() bool
 
+    // SetAdmin promotes the user to a superuser or demotes it. The last superuser can't be demoted.
+    SetAdmin(admin bool) error
+
     // ValidatePassword determines the validity of a password.
     ValidatePassword(password string) bool
 
-    // UpdatePassword updates a user's password. This password is only used to log into the web ui.
+    // UpdatePassword updates a user's password, which is used for password logins over SSH.
     UpdatePassword(password string) error
 
+    // TOTPSecret returns the secret of the user's TOTP second factor or nil if it isn't enabled
+    TOTPSecret() []byte
+
+    // SetTOTPSecret enables a TOTP second factor for password logins. A nil secret disables it.
+    SetTOTPSecret(secret []byte) error
+
+    // AcceptTOTP determines if a verification code of the user's TOTP second factor is valid at the given time.
+    // Each code is accepted once and codes of periods before the last accepted one are rejected.
+    AcceptTOTP(code string, t time.Time) bool
+
     // KeyRing returns a PublicKeyRing containing all of a user's public keys
     KeyRing() PublicKeyRing
 
@@ -86,6 +188,9 @@ type User interface {
 
     // RemoveRole removed a role for a namespace
     RemoveRole(namespace, role string) error
+
+    // RemoveNamespace removes the namespace and all of the user's roles for it
+    RemoveNamespace(namespace string) error
 }
 
 // UserStore stores all user information
@@ -97,8 +202,15 @@ type UserStore interface {
     // Create inserts a new user
     Create(username string) (User, error)
 
-    // Delete removes a user account from a namespace
+    // Delete removes a user account. The last superuser can't be deleted.
     Delete(username string) error
+
+    // Bootstrap creates the initial superuser with a public key if there is no superuser yet. An existing account
+    // with the username is promoted, which migrates databases created before superusers.
+    Bootstrap(username string, keyBytes []byte) (User, error)
+
+    // Stream returns a channel of usernames
+    Stream() chan string
 }
 
 // NewBoltUserStore returns a UserStore backed by boltdb. If the user keyspace does not already exist, it will be created.
@@ -144,6 +256,12 @@ func (b boltUserStore) Get(name string) (u User, err error) {
 func (b boltUserStore) Delete(name string) (err error) {
     b.ks.WriteTx(func(bkt *bolt.Bucket) {
 
+        // Keep the last superuser
+        if user := bkt.Bucket([]byte(name)); user != nil && isSuperuser(user) && lastSuperuser(bkt, []byte(name)) {
+            err = ErrLastSuperuser
+            return
+        }
+
         // Delete bucket
         err = bkt.DeleteBucket([]byte(name))
         return
@@ -151,15 +269,129 @@ func (b boltUserStore) Delete(name string) (err error) {
     return
 }
 
+// Bootstrap creates a superuser with a public key unless a superuser already exists. An existing account, such as
+// the admin account of databases created before superusers, is promoted.
+func (b boltUserStore) Bootstrap(name string, keyBytes []byte) (u User, err error) {
+    b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Only bootstrap without superusers
+        if !lastSuperuser(bkt, nil) {
+            err = ErrSuperuserExists
+            return
+        }
+
+        // Parse public key before creating the superuser
+        var key ssh.PublicKey
+        var parsed KeyAttributes
+        if key, parsed, err = ParsePublicKey(keyBytes); err != nil {
+            return
+        }
+
+        // Create or promote superuser
+        var user *bolt.Bucket
+        if user, err = bkt.CreateBucketIfNotExists([]byte(name)); err != nil {
+            return
+        } else if err = user.Put(superuserKey, []byte{1}); err != nil {
+            return
+        } else if _, err = putPublicKey(user, key, parsed, KeyAttributes{}); err != nil {
+            return
+        }
+        u = boltUser{[]byte(name), b.ks}
+        return
+    })
+    return
+}
+
+// Stream returns a channel of usernames
+func (b boltUserStore) Stream() chan string {
+    out := make(chan string)
+
+    // Read users in background
+    go func(channel chan<- string) {
+        b.ks.ReadTx(func(bkt *bolt.Bucket) {
+            cur := bkt.Cursor()
+
+            // Iterate over keys
+            for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+                channel <- string(k)
+            }
+
+            // Close channel
+            close(channel)
+            return
+        })
+    }(out)
+    return out
+}
+
 // boltUser implements the User interface on top of boltdb
 type boltUser struct {
     name  []byte
     users leaf.Keyspace
 }
 
-// IsAdmin returns whether the user is an admin
-func (b boltUser) IsAdmin() bool {
-    return string(b.name) == "admin"
+// IsAdmin returns whether the user is a superuser
+func (b boltUser) IsAdmin() (admin bool) {
+    b.users.ReadTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        if user := bkt.Bucket(b.name); user != nil {
+            admin = isSuperuser(user)
+        }
+        return
+    })
+    return
+}
+
+// SetAdmin promotes the user to a superuser or demotes it
+func (b boltUser) SetAdmin(admin bool) (err error) {
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.name)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            err = ErrUserDoesNotExist
+            return
+        }
+
+        if admin {
+            err = user.Put(superuserKey, []byte{1})
+            return
+        }
+
+        // Keep the last superuser
+        if isSuperuser(user) && lastSuperuser(bkt, b.name) {
+            err = ErrLastSuperuser
+            return
+        }
+        err = user.Delete(superuserKey)
+        return
+    })
+    return
+}
+
+// isSuperuser determines if a user bucket has the superuser flag
+func isSuperuser(user *bolt.Bucket) bool {
+    flag := user.Get(superuserKey)
+    return len(flag) > 0 && flag[0] == 1
+}
+
+// lastSuperuser determines if no user other than the given one is a superuser
+func lastSuperuser(bkt *bolt.Bucket, name []byte) bool {
+    cur := bkt.Cursor()
+    for k, v := cur.First(); k != nil; k, v = cur.Next() {
+
+        // Users are stored as buckets
+        if v != nil || bytes.Equal(k, name) {
+            continue
+        }
+        if user := bkt.Bucket(k); user != nil && isSuperuser(user) {
+            return false
+        }
+    }
+    return true
 }
 
 // Username returns the user alias
@@ -167,8 +399,11 @@ func (b boltUser) Username() string {
     return string(b.name)
 }
 
-// ValidatePassword determines the validity of a password.
+// ValidatePassword determines the validity of a password. Passwords hashed with a legacy salted SHA-256 or with
+// other parameters than the default hasher are rehashed once they have been validated. Users without a password
+// reject passwords as slowly as other users.
 func (b boltUser) ValidatePassword(password string) (match bool) {
+    var encoded, salt, saltedpw []byte
     b.users.ReadTx(func(bkt *bolt.Bucket) {
 
         // Get user bucket
@@ -179,33 +414,114 @@ func (b boltUser) ValidatePassword(password string) (match bool) {
             return
         }
 
-        // Get salt, if salt is nil return false.
-        // If the salt is nil, a user password has not been set
-        salt := user.Get([]byte("salt"))
-        if salt == nil {
+        // Get the password hash or the legacy salt and salted password.
+        // If neither is set, a user password has not been set
+        encoded = append([]byte(nil), user.Get(passwordHashKey)...)
+        salt = append([]byte(nil), user.Get(saltKey)...)
+        saltedpw = append([]byte(nil), user.Get(saltedPasswordKey)...)
+        return
+    })
+
+    // Verify password
+    var rehash bool
+    if encoded != nil {
+        match, rehash = VerifyPassword(password, string(encoded))
+    } else if salt != nil && saltedpw != nil {
+        match = ValidateSalt(salt, saltedpw, []byte(password))
+        rehash = match
+    } else {
+        return RejectPassword(password)
+    }
+
+    // Failing to replace the hash doesn't fail the login as the password is still valid
+    if rehash {
+        b.rehashPassword(password, encoded, saltedpw)
+    }
+    return
+}
+
+// rehashPassword replaces a password hash with one created by the default hasher unless the password has been
+// updated since it was validated
+func (b boltUser) rehashPassword(password string, encoded, saltedpw []byte) (err error) {
+    hash, err := HashPassword(password)
+    if err != nil {
+        return
+    }
+
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.name)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            err = ErrUserDoesNotExist
+            return
+        }
+
+        // Keep passwords updated in the meantime
+        if !bytes.Equal(user.Get(passwordHashKey), encoded) || !bytes.Equal(user.Get(saltedPasswordKey), saltedpw) {
             return
         }
+        err = putPassword(user, hash)
+        return
+    })
+    return
+}
+
+// UpdatePassword updates a user's password, which is used for password logins over SSH.
+func (b boltUser) UpdatePassword(password string) (err error) {
+
+    // Hash password before locking the database
+    hash, err := HashPassword(password)
+    if err != nil {
+        return
+    }
+
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
 
-        // Get the salted password
-        saltedpw := user.Get([]byte("salted_password"))
-        if saltedpw == nil {
+        // Get user bucket
+        user := bkt.Bucket(b.name)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            err = ErrUserDoesNotExist
             return
         }
 
-        // Salt password
-        hash := sha256.New()
-        hash.Write(salt)
-        hash.Write([]byte(password))
+        err = putPassword(user, hash)
+        return
+    })
+    return
+}
+
+// putPassword stores an encoded password hash in a user bucket and removes the legacy salted password
+func putPassword(user *bolt.Bucket, hash string) error {
+    if err := user.Put(passwordHashKey, []byte(hash)); err != nil {
+        return err
+    } else if err := user.Delete(saltKey); err != nil {
+        return err
+    }
+    return user.Delete(saltedPasswordKey)
+}
 
-        // compare byte strings
-        match = SecureCompare(hash.Sum(nil), saltedpw)
+// TOTPSecret returns the secret of the user's TOTP second factor or nil if it isn't enabled
+func (b boltUser) TOTPSecret() (secret []byte) {
+    b.users.ReadTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        if user := bkt.Bucket(b.name); user != nil {
+            if value := user.Get(totpSecretKey); value != nil {
+                secret = append([]byte{}, value...)
+            }
+        }
         return
     })
     return
 }
 
-// UpdatePassword updates a user's password. This password is only used to log into the web ui.
-func (b boltUser) UpdatePassword(password string) (err error) {
+// SetTOTPSecret enables a TOTP second factor for password logins. A nil secret disables it.
+func (b boltUser) SetTOTPSecret(secret []byte) (err error) {
     b.users.WriteTx(func(bkt *bolt.Bucket) {
 
         // Get user bucket
@@ -217,21 +533,52 @@ func (b boltUser) UpdatePassword(password string) (err error) {
             return
         }
 
-        // Generate salt and salted password
-        salt, saltedpw, err := GenerateSalt([]byte(password))
-        if err != nil {
+        // Codes of the previous secret don't restrict the new one
+        if err = user.Delete(totpStepKey); err != nil {
             return
         }
 
-        // Save salt
-        if err = user.Put([]byte("salt"), salt); err != nil {
+        if secret == nil {
+            err = user.Delete(totpSecretKey)
             return
         }
+        err = user.Put(totpSecretKey, secret)
+        return
+    })
+    return
+}
 
-        // Save salted password
-        if err = user.Put([]byte("salted_password"), saltedpw); err != nil {
+// AcceptTOTP determines if a verification code of the user's TOTP second factor is valid at the given time. The
+// time step of accepted codes is stored so codes can't be replayed.
+func (b boltUser) AcceptTOTP(code string, t time.Time) (accepted bool) {
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.name)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            return
+        }
+
+        // Verify code
+        secret := user.Get(totpSecretKey)
+        if secret == nil {
+            return
+        }
+        step, valid := MatchTOTP(secret, code, t)
+        if !valid {
+            return
+        }
+
+        // Reject codes of the period of the last accepted code or earlier
+        if last := user.Get(totpStepKey); len(last) == 8 && step <= binary.BigEndian.Uint64(last) {
             return
         }
+
+        var value [8]byte
+        binary.BigEndian.PutUint64(value[:], step)
+        accepted = user.Put(totpStepKey, value[:]) == nil
         return
     })
     return
@@ -310,8 +657,8 @@ func (b boltUser) AddRole(namespace, role string) (err error) {
         }
 
         // Get namespaces bucket
-        namespaces, err := ns.CreateBucketIfNotExists([]byte("namespaces"))
-        if err != nil {
+        var namespaces *bolt.Bucket
+        if namespaces, err = ns.CreateBucketIfNotExists([]byte("namespaces")); err != nil {
             return
         }
 
@@ -320,9 +667,9 @@ func (b boltUser) AddRole(namespace, role string) (err error) {
         if len(roles) > 0 {
 
             list := []string{string(roles), role}
-            namespaces.Put([]byte(namespace), []byte(strings.Join(list, ",")))
+            err = namespaces.Put([]byte(namespace), []byte(strings.Join(list, ",")))
         } else {
-            namespaces.Put([]byte(namespace), []byte(role))
+            err = namespaces.Put([]byte(namespace), []byte(role))
         }
         return
     })
@@ -341,8 +688,8 @@ func (b boltUser) RemoveRole(namespace, role string) (err error) {
         }
 
         // Get namespaces bucket
-        namespaces, err := ns.CreateBucketIfNotExists([]byte("namespaces"))
-        if err != nil {
+        var namespaces *bolt.Bucket
+        if namespaces, err = ns.CreateBucketIfNotExists([]byte("namespaces")); err != nil {
             return
         }
 
@@ -365,15 +712,45 @@ func (b boltUser) RemoveRole(namespace, role string) (err error) {
     return
 }
 
+// RemoveNamespace removes the namespace and all of the user's roles for it
+func (b boltUser) RemoveNamespace(namespace string) (err error) {
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.name)
+        if user == nil {
+            err = ErrUserDoesNotExist
+            return
+        }
+
+        // Get namespaces bucket
+        var namespaces *bolt.Bucket
+        if namespaces, err = user.CreateBucketIfNotExists([]byte("namespaces")); err != nil {
+            return
+        }
+
+        // Delete namespace
+        err = namespaces.Delete([]byte(namespace))
+        return
+    })
+    return
+}
+
 type boltKeyRing struct {
     username []byte
     users    leaf.Keyspace
 }
 
 // AddPublicKey simply adds a public key to the user's key ring
-func (b *boltKeyRing) AddPublicKey(pemBytes []byte) (fingerprint string, e error) {
+func (b *boltKeyRing) AddPublicKey(keyBytes []byte) (string, error) {
+    return b.AddPublicKeyWithAttributes(keyBytes, KeyAttributes{})
+}
+
+// AddPublicKeyWithAttributes adds a public key with attributes to the user's key ring. The comment, expiry and
+// command of an authorized_keys line are used unless the attributes set them.
+func (b *boltKeyRing) AddPublicKeyWithAttributes(keyBytes []byte, attributes KeyAttributes) (fingerprint string, e error) {
     b.users.WriteTx(func(bkt *bolt.Bucket) {
-        if len(pemBytes) == 0 {
+        if len(keyBytes) == 0 {
             e = ErrInvalidCertificate
             return
         }
@@ -387,43 +764,176 @@ func (b *boltKeyRing) AddPublicKey(pemBytes []byte) (fingerprint string, e error
             return
         }
 
-        // Get keys sub-bucket
-        keys, err := user.CreateBucketIfNotExists([]byte("keys"))
+        // Parse public key
+        key, parsed, err := ParsePublicKey(keyBytes)
         if err != nil {
+            e = err
             return
         }
+        fingerprint, e = putPublicKey(user, key, parsed, attributes)
+        return
+    })
+    return
+}
 
-        // Decode PEM bytes
-        block, _ := pem.Decode(pemBytes)
-        if block == nil {
-            e = ErrInvalidCertificate
+// putPublicKey writes a key and its attributes to a user bucket. The attributes parsed from the key apply unless
+// they are overridden.
+func putPublicKey(user *bolt.Bucket, sshKey ssh.PublicKey, parsed, attributes KeyAttributes) (string, error) {
+
+    // Get keys sub-bucket
+    keys, err := user.CreateBucketIfNotExists([]byte("keys"))
+    if err != nil {
+        return "", err
+    }
+
+    // Options of the key apply unless they are overridden
+    if attributes.Comment == "" {
+        attributes.Comment = parsed.Comment
+    }
+    if attributes.Expires.IsZero() {
+        attributes.Expires = parsed.Expires
+    }
+    if attributes.Command == "" {
+        attributes.Command = parsed.Command
+    }
+
+    // Convert key to bytes
+    key := sshKey.Marshal()
+    fingerprint := auth.CreateFingerprint(key)
+
+    // Write key to keys bucket
+    if err = keys.Put([]byte(fingerprint), key); err != nil {
+        return "", err
+    }
+
+    // Write attributes
+    attributes.Created = time.Now().UTC()
+    attributes.LastUsed = time.Time{}
+    return fingerprint, putKeyAttributes(user, fingerprint, attributes)
+}
+
+// Attributes returns the attributes of a key
+func (b *boltKeyRing) Attributes(fingerprint string) (attributes KeyAttributes, err error) {
+    b.users.ReadTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.username)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            err = ErrUserDoesNotExist
             return
         }
 
-        pub, err := x509.ParseCertificate(block.Bytes)
-        if err != nil {
-            e = ErrInvalidCertificate
+        // Verify the key exists
+        keys := user.Bucket([]byte("keys"))
+        if keys == nil || keys.Get([]byte(fingerprint)) == nil {
+            err = ErrKeyDoesNotExist
             return
         }
+        attributes = getKeyAttributes(user, fingerprint)
+        return
+    })
+    return
+}
 
-        // Convert Public Key to SSH format
-        sshKey, err := ssh.NewPublicKey(pub.PublicKey)
-        if err != nil {
-            e = ErrFailedKeyConvertion
+// Touch records the time a key was used to log in
+func (b *boltKeyRing) Touch(fingerprint string, used time.Time) (err error) {
+    b.users.WriteTx(func(bkt *bolt.Bucket) {
+
+        // Get user bucket
+        user := bkt.Bucket(b.username)
+
+        // If user is nil, the user does not exist
+        if user == nil {
+            err = ErrUserDoesNotExist
             return
         }
 
-        // Convert key to bytes
-        key := sshKey.Marshal()
-        fingerprint = auth.CreateFingerprint(key)
+        // Verify the key exists
+        keys := user.Bucket([]byte("keys"))
+        if keys == nil || keys.Get([]byte(fingerprint)) == nil {
+            err = ErrKeyDoesNotExist
+            return
+        }
 
-        // Write key to keys bucket
-        e = keys.Put([]byte(fingerprint), key)
+        // Update attributes
+        attributes := getKeyAttributes(user, fingerprint)
+        attributes.LastUsed = used.UTC()
+        err = putKeyAttributes(user, fingerprint, attributes)
         return
     })
     return
 }
 
+// putKeyAttributes writes the attributes of a key to a sub-bucket of the user's "key_attributes" bucket
+func putKeyAttributes(user *bolt.Bucket, fingerprint string, attributes KeyAttributes) error {
+    all, err := user.CreateBucketIfNotExists([]byte("key_attributes"))
+    if err != nil {
+        return err
+    }
+    bkt, err := all.CreateBucketIfNotExists([]byte(fingerprint))
+    if err != nil {
+        return err
+    }
+
+    values := map[string]string{
+        "comment":    attributes.Comment,
+        "command":    attributes.Command,
+        "created":    formatTime(attributes.Created),
+        "expires":    formatTime(attributes.Expires),
+        "last_used":  formatTime(attributes.LastUsed),
+        "namespaces": strings.Join(attributes.Namespaces, ","),
+        "read_only":  "",
+    }
+    if attributes.ReadOnly {
+        values["read_only"] = "true"
+    }
+    for key, value := range values {
+        if err = bkt.Put([]byte(key), []byte(value)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// getKeyAttributes reads the attributes of a key. Keys added before keys had attributes have none.
+func getKeyAttributes(user *bolt.Bucket, fingerprint string) (attributes KeyAttributes) {
+    all := user.Bucket([]byte("key_attributes"))
+    if all == nil {
+        return
+    }
+    bkt := all.Bucket([]byte(fingerprint))
+    if bkt == nil {
+        return
+    }
+
+    attributes.Comment = string(bkt.Get([]byte("comment")))
+    attributes.Created = parseTime(bkt.Get([]byte("created")))
+    attributes.Expires = parseTime(bkt.Get([]byte("expires")))
+    attributes.LastUsed = parseTime(bkt.Get([]byte("last_used")))
+    if namespaces := bkt.Get([]byte("namespaces")); len(namespaces) > 0 {
+        attributes.Namespaces = strings.Split(string(namespaces), ",")
+    }
+    attributes.ReadOnly = string(bkt.Get([]byte("read_only"))) == "true"
+    attributes.Command = string(bkt.Get([]byte("command")))
+    return
+}
+
+// formatTime formats a time for storage. Zero times are stored as empty values.
+func formatTime(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime parses a stored time. Empty values are zero times.
+func parseTime(value []byte) time.Time {
+    t, _ := time.Parse(time.RFC3339Nano, string(value))
+    return t
+}
+
 // RemovePublicKey will remove a public key from a user's key ring
 func (b *boltKeyRing) RemovePublicKey(fingerprint string) (err error) {
     b.users.WriteTx(func(bkt *bolt.Bucket) {
@@ -438,13 +948,20 @@ func (b *boltKeyRing) RemovePublicKey(fingerprint string) (err error) {
         }
 
         // Get keys sub-bucket
-        keys, err := user.CreateBucketIfNotExists([]byte("keys"))
-        if err != nil {
+        var keys *bolt.Bucket
+        if keys, err = user.CreateBucketIfNotExists([]byte("keys")); err != nil {
             return
         }
 
         // Delete finger print
-        err = keys.Delete([]byte(fingerprint))
+        if err = keys.Delete([]byte(fingerprint)); err != nil {
+            return
+        }
+
+        // Delete attributes
+        if attributes := user.Bucket([]byte("key_attributes")); attributes != nil && attributes.Bucket([]byte(fingerprint)) != nil {
+            err = attributes.DeleteBucket([]byte(fingerprint))
+        }
         return
     })
     return
@@ -470,7 +987,7 @@ func (b *boltKeyRing) ListPublicKeys() (publicKeys []PublicKey) {
 
         // Public keys are stored as fingerprint : key
         keys.ForEach(func(k []byte, v []byte) error {
-            publicKeys = append(publicKeys, PublicKey{k, v})
+            publicKeys = append(publicKeys, PublicKey{k, v, getKeyAttributes(user, string(k))})
             return nil
         })
         return
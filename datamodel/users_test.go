@@ -4,7 +4,6 @@ import (
     "bytes"
     "crypto/rand"
     "crypto/rsa"
-    "crypto/sha256"
     "crypto/x509"
     "encoding/pem"
     "io/ioutil"
@@ -148,6 +147,120 @@ func (suite *UserTestSuite) TestDeleteUser() {
     })
 }
 
+// TestSuperusers ensures users can be promoted and demoted while keeping the last superuser
+func (suite *UserTestSuite) TestSuperusers() {
+    first, _ := suite.createUser("super.first")
+    suite.False(first.IsAdmin())
+
+    // Promote the first superuser
+    suite.Nil(first.SetAdmin(true))
+    suite.True(first.IsAdmin())
+
+    // The last superuser can't be demoted or deleted
+    suite.Equal(ErrLastSuperuser, first.SetAdmin(false))
+    suite.Equal(ErrLastSuperuser, suite.US.Delete("super.first"))
+    suite.True(first.IsAdmin())
+    suite.True(suite.verifyUserExists("super.first"))
+
+    // Promote a second superuser
+    second, _ := suite.createUser("super.second")
+    suite.Nil(second.SetAdmin(true))
+    suite.Nil(second.SetAdmin(true))
+
+    // Either superuser can now be demoted or deleted
+    suite.Nil(first.SetAdmin(false))
+    suite.False(first.IsAdmin())
+    suite.Nil(first.SetAdmin(false))
+    suite.Equal(ErrLastSuperuser, second.SetAdmin(false))
+
+    suite.Nil(first.SetAdmin(true))
+    suite.deleteUser("super.second")
+    suite.Equal(ErrLastSuperuser, first.SetAdmin(false))
+
+    // Users which are not superusers can always be deleted
+    suite.createUser("super.regular")
+    suite.deleteUser("super.regular")
+}
+
+// TestBootstrap ensures the initial superuser is only created while there is no superuser
+func (suite *UserTestSuite) TestBootstrap() {
+    ks, err := suite.DB.GetOrCreateKeyspace("bootstrap")
+    suite.Nil(err)
+    users := NewBoltUserStore(ks)
+
+    key, _ := generateKey(suite.T(), ssh.KeyAlgoED25519)
+    line := ssh.MarshalAuthorizedKey(key)
+
+    // Accounts other than the bootstrapped one are left alone
+    regular, err := users.Create("bootstrap.regular")
+    suite.Nil(err)
+
+    // Nothing is created without a valid key
+    _, err = users.Bootstrap("bootstrap.admin", nil)
+    suite.Equal(ErrInvalidCertificate, err)
+    _, err = users.Get("bootstrap.admin")
+    suite.Equal(ErrUserDoesNotExist, err)
+
+    // Create the initial superuser
+    admin, err := users.Bootstrap("bootstrap.admin", line)
+    suite.Nil(err)
+    suite.Equal("bootstrap.admin", admin.Username())
+    suite.True(admin.IsAdmin())
+    suite.True(admin.KeyRing().Contains(key.Marshal()))
+    suite.False(regular.IsAdmin())
+
+    // Accounts are never promoted once a superuser exists
+    suite.Nil(regular.SetAdmin(true))
+    suite.Nil(admin.SetAdmin(false))
+    _, err = users.Bootstrap("bootstrap.admin", line)
+    suite.Equal(ErrSuperuserExists, err)
+    suite.False(admin.IsAdmin())
+
+    _, err = users.Bootstrap("bootstrap.other", line)
+    suite.Equal(ErrSuperuserExists, err)
+    _, err = users.Get("bootstrap.other")
+    suite.Equal(ErrUserDoesNotExist, err)
+}
+
+// TestBootstrapLegacyAdmin ensures the admin account of databases created before superusers is promoted
+func (suite *UserTestSuite) TestBootstrapLegacyAdmin() {
+    ks, err := suite.DB.GetOrCreateKeyspace("bootstrap.legacy")
+    suite.Nil(err)
+    users := NewBoltUserStore(ks)
+
+    // Earlier servers created the admin account with the admin key but without the superuser flag
+    key, _ := generateKey(suite.T(), ssh.KeyAlgoED25519)
+    line := ssh.MarshalAuthorizedKey(key)
+    legacy, err := users.Create("admin")
+    suite.Nil(err)
+    fingerprint, err := legacy.KeyRing().AddPublicKey(line)
+    suite.Nil(err)
+    suite.Nil(legacy.UpdatePassword("secret"))
+    suite.False(legacy.IsAdmin())
+
+    // Restarting promotes it and keeps the account as it is
+    admin, err := users.Bootstrap("admin", line)
+    suite.Nil(err)
+    suite.True(admin.IsAdmin())
+    suite.True(admin.KeyRing().Contains(key.Marshal()))
+    keys := admin.KeyRing().ListPublicKeys()
+    if suite.Len(keys, 1) {
+        suite.Equal(fingerprint, keys[0].Fingerprint())
+    }
+    suite.True(admin.ValidatePassword("secret"))
+
+    // Later restarts leave it alone
+    _, err = users.Bootstrap("admin", line)
+    suite.Equal(ErrSuperuserExists, err)
+}
+
+// TestSetAdminInvalidUser ensures users which don't exist can't be promoted
+func (suite *UserTestSuite) TestSetAdminInvalidUser() {
+    user := boltUser{[]byte("super.none"), suite.KS}
+    suite.Equal(ErrUserDoesNotExist, user.SetAdmin(true))
+    suite.False(user.IsAdmin())
+}
+
 func (suite *UserTestSuite) verifyUserExists(name string) (exists bool) {
 
     // Test that the user was created
@@ -275,24 +388,166 @@ func (suite *UserTestSuite) TestUpdatePassword() {
     err = user.UpdatePassword("password")
     suite.Nil(err)
 
-    // Validate salt + salted_password
+    // Validate password hash
     suite.KS.WriteTx(func(bkt *bolt.Bucket) {
         userBucket := bkt.Bucket([]byte(name))
 
-        salt := userBucket.Get([]byte("salt"))
-        suite.NotNil(salt)
-
-        saltedPassword := userBucket.Get([]byte("salted_password"))
-        suite.NotNil(saltedPassword)
+        suite.Nil(userBucket.Get([]byte("salt")))
+        suite.Nil(userBucket.Get([]byte("salted_password")))
 
-        // Salt password
-        hash := sha256.New()
-        hash.Write(salt)
-        hash.Write([]byte("password"))
+        hash := userBucket.Get([]byte("password_hash"))
+        suite.NotNil(hash)
 
-        match := SecureCompare(hash.Sum(nil), saltedPassword)
+        match, rehash := VerifyPassword("password", string(hash))
         suite.True(match)
+        suite.False(rehash)
+    })
+    suite.True(user.ValidatePassword("password"))
+    suite.False(user.ValidatePassword("Password"))
+}
+
+// passwordFields returns the password hash and the legacy salted password of a user
+func (suite *UserTestSuite) passwordFields(name string) (hash, salt, saltedpw []byte) {
+    suite.KS.ReadTx(func(bkt *bolt.Bucket) {
+        userBucket := bkt.Bucket([]byte(name))
+        hash = append([]byte(nil), userBucket.Get([]byte("password_hash"))...)
+        salt = append([]byte(nil), userBucket.Get([]byte("salt"))...)
+        saltedpw = append([]byte(nil), userBucket.Get([]byte("salted_password"))...)
+    })
+    return
+}
+
+// TestValidatePasswordUpgradesLegacyHash ensures salted SHA-256 passwords are rehashed once they have been validated
+func (suite *UserTestSuite) TestValidatePasswordUpgradesLegacyHash() {
+    name := "acme.validate.password.legacy"
+    user, err := suite.US.Create(name)
+    suite.Nil(err)
+
+    // Write legacy salted password
+    salt, saltedpw, err := GenerateSalt([]byte("password"))
+    suite.Nil(err)
+    suite.KS.WriteTx(func(bkt *bolt.Bucket) {
+        userBucket := bkt.Bucket([]byte(name))
+        userBucket.Put([]byte("salt"), salt)
+        userBucket.Put([]byte("salted_password"), saltedpw)
+    })
+
+    // Invalid passwords don't change the legacy hash
+    suite.False(user.ValidatePassword("shaken, not stirred"))
+    hash, storedSalt, storedSaltedpw := suite.passwordFields(name)
+    suite.Nil(hash)
+    suite.Equal(salt, storedSalt)
+    suite.Equal(saltedpw, storedSaltedpw)
+
+    // Valid passwords replace it with the default hasher
+    suite.True(user.ValidatePassword("password"))
+    hash, storedSalt, storedSaltedpw = suite.passwordFields(name)
+    suite.Nil(storedSalt)
+    suite.Nil(storedSaltedpw)
+    suite.True(bytes.HasPrefix(hash, []byte("$pbkdf2-sha256$v=1$i=")))
+
+    match, rehash := VerifyPassword("password", string(hash))
+    suite.True(match)
+    suite.False(rehash)
+
+    // The password remains valid after the upgrade
+    suite.True(user.ValidatePassword("password"))
+    suite.False(user.ValidatePassword("shaken, not stirred"))
+    upgraded, _, _ := suite.passwordFields(name)
+    suite.Equal(hash, upgraded)
+}
+
+// TestValidatePasswordUpgradesOutdatedHash ensures hashes created with other parameters are rehashed
+func (suite *UserTestSuite) TestValidatePasswordUpgradesOutdatedHash() {
+    name := "acme.validate.password.outdated"
+    user, err := suite.US.Create(name)
+    suite.Nil(err)
+
+    // Write hash with fewer iterations
+    outdated, err := NewPBKDF2Hasher(1000).Hash([]byte("password"))
+    suite.Nil(err)
+    suite.KS.WriteTx(func(bkt *bolt.Bucket) {
+        bkt.Bucket([]byte(name)).Put([]byte("password_hash"), []byte(outdated))
+    })
+
+    suite.False(user.ValidatePassword("Password"))
+    hash, _, _ := suite.passwordFields(name)
+    suite.Equal(outdated, string(hash))
+
+    suite.True(user.ValidatePassword("password"))
+    hash, _, _ = suite.passwordFields(name)
+    suite.NotEqual(outdated, string(hash))
+
+    match, rehash := VerifyPassword("password", string(hash))
+    suite.True(match)
+    suite.False(rehash)
+}
+
+// TestValidatePasswordUnknownHasher ensures hashes of unknown hashers are never valid
+func (suite *UserTestSuite) TestValidatePasswordUnknownHasher() {
+    name := "acme.validate.password.unknown"
+    user, err := suite.US.Create(name)
+    suite.Nil(err)
+
+    suite.KS.WriteTx(func(bkt *bolt.Bucket) {
+        bkt.Bucket([]byte(name)).Put([]byte("password_hash"), []byte("$plain$v=1$password"))
     })
+    suite.False(user.ValidatePassword("password"))
+}
+
+func (suite *UserTestSuite) TestTOTPSecretInvalidUser() {
+    user := boltUser{[]byte("blahblahblah"), suite.KS}
+
+    suite.Nil(user.TOTPSecret())
+    suite.Equal(ErrUserDoesNotExist, user.SetTOTPSecret([]byte("secret")))
+}
+
+func (suite *UserTestSuite) TestTOTPSecret() {
+    user, err := suite.createUser("acme.totp")
+    suite.Nil(err)
+    suite.Nil(user.TOTPSecret())
+
+    // Enable second factor
+    secret, err := GenerateTOTPSecret()
+    suite.Nil(err)
+    suite.Nil(user.SetTOTPSecret(secret))
+    suite.Equal(secret, user.TOTPSecret())
+
+    // Disable second factor
+    suite.Nil(user.SetTOTPSecret(nil))
+    suite.Nil(user.TOTPSecret())
+    suite.Nil(user.SetTOTPSecret(nil))
+}
+
+func (suite *UserTestSuite) TestAcceptTOTP() {
+    user, err := suite.createUser("acme.totp.replay")
+    suite.Nil(err)
+    now := time.Unix(1234567890, 0)
+
+    // Users without a second factor accept no code
+    secret, err := GenerateTOTPSecret()
+    suite.Nil(err)
+    suite.False(user.AcceptTOTP(TOTPCode(secret, now), now))
+    suite.Nil(user.SetTOTPSecret(secret))
+
+    // Codes are accepted once
+    suite.True(user.AcceptTOTP(TOTPCode(secret, now), now))
+    suite.False(user.AcceptTOTP(TOTPCode(secret, now), now))
+    suite.False(user.AcceptTOTP(TOTPCode(secret, now), now.Add(TOTPPeriod)))
+
+    // Codes of earlier periods are rejected and those of later ones accepted
+    suite.False(user.AcceptTOTP(TOTPCode(secret, now.Add(-TOTPPeriod)), now))
+    suite.True(user.AcceptTOTP(TOTPCode(secret, now.Add(TOTPPeriod)), now))
+    suite.False(user.AcceptTOTP("", now.Add(2*TOTPPeriod)))
+
+    // A new secret accepts codes of any period
+    secret, err = GenerateTOTPSecret()
+    suite.Nil(err)
+    suite.Nil(user.SetTOTPSecret(secret))
+    suite.True(user.AcceptTOTP(TOTPCode(secret, now), now))
+
+    // Unknown users accept no code
+    suite.False(boltUser{[]byte("blahblahblah"), suite.KS}.AcceptTOTP(TOTPCode(secret, now), now))
 }
 
 func (suite *UserTestSuite) TestUserNamespacesInvalidUser() {
@@ -519,6 +774,55 @@ func (suite *UserTestSuite) TestRemoveRoleMultiple() {
     })
 }
 
+func (suite *UserTestSuite) TestRemoveNamespaceInvalidUser() {
+    user := boltUser{[]byte("blahblahblah"), suite.KS}
+
+    // Remove namespace
+    err := user.RemoveNamespace("acme")
+    suite.Equal(ErrUserDoesNotExist, err)
+}
+
+func (suite *UserTestSuite) TestRemoveNamespace() {
+    name := "acme.user.remove.namespace"
+
+    // Create user
+    user, err := suite.US.Create(name)
+    suite.Nil(err)
+    suite.NotNil(user)
+
+    // Add roles
+    suite.Nil(user.AddRole("acme.namespace", "admin"))
+    suite.Nil(user.AddRole("acme.other", "admin"))
+
+    // Remove namespace
+    err = user.RemoveNamespace("acme.namespace")
+    suite.Nil(err)
+
+    // Validate namespaces
+    suite.Equal([]string{"acme.other"}, user.Namespaces())
+    suite.Nil(user.Roles("acme.namespace"))
+
+    // Removing a namespace the user does not have is not an error
+    suite.Nil(user.RemoveNamespace("acme.namespace"))
+}
+
+func (suite *UserTestSuite) TestStreamUsers() {
+    name := "acme.user.stream"
+
+    // Create user
+    _, err := suite.US.Create(name)
+    suite.Nil(err)
+
+    // Find user
+    var found bool
+    for username := range suite.US.Stream() {
+        if username == name {
+            found = true
+        }
+    }
+    suite.True(found)
+}
+
 func (suite *UserTestSuite) generateCertificate() []byte {
 
     // generate private key
@@ -727,6 +1031,140 @@ func (suite *UserTestSuite) TestRemovePublicKey() {
     })
 }
 
+// TestKeyAttributes ensures keys keep their attributes until they are removed
+func (suite *UserTestSuite) TestKeyAttributes() {
+    name := "acme.user.key.attributes"
+
+    // Create user
+    user, err := suite.US.Create(name)
+    suite.Nil(err)
+    keyRing := user.KeyRing()
+
+    // Encode cert
+    pemFile := new(bytes.Buffer)
+    pem.Encode(pemFile, &pem.Block{Type: "CERTIFICATE", Bytes: suite.generateCertificate()})
+
+    // Add key
+    expires := time.Now().Add(time.Hour).UTC()
+    fp, err := keyRing.AddPublicKeyWithAttributes(pemFile.Bytes(), KeyAttributes{
+        Comment:    "nightly build",
+        Expires:    expires,
+        LastUsed:   time.Now(),
+        Namespaces: []string{"acme.metrics", "acme.logs"},
+        ReadOnly:   true,
+    })
+    suite.Nil(err)
+
+    // Verify attributes
+    attributes, err := keyRing.Attributes(fp)
+    suite.Nil(err)
+    suite.Equal("nightly build", attributes.Comment)
+    suite.WithinDuration(time.Now(), attributes.Created, time.Minute)
+    suite.True(expires.Equal(attributes.Expires))
+    suite.True(attributes.LastUsed.IsZero())
+    suite.Equal([]string{"acme.metrics", "acme.logs"}, attributes.Namespaces)
+    suite.True(attributes.ReadOnly)
+
+    // Record use
+    used := time.Now().Add(time.Minute)
+    suite.Nil(keyRing.Touch(fp, used))
+    attributes, err = keyRing.Attributes(fp)
+    suite.Nil(err)
+    suite.True(used.Equal(attributes.LastUsed))
+    suite.Equal("nightly build", attributes.Comment)
+
+    // Listed keys have attributes
+    keys := keyRing.ListPublicKeys()
+    suite.Equal(1, len(keys))
+    suite.Equal("nightly build", keys[0].Attributes().Comment)
+
+    // Attributes are removed with the key
+    suite.Nil(keyRing.RemovePublicKey(fp))
+    _, err = keyRing.Attributes(fp)
+    suite.Equal(ErrKeyDoesNotExist, err)
+    suite.Equal(ErrKeyDoesNotExist, keyRing.Touch(fp, used))
+}
+
+// TestKeyAttributesWithoutAttributes ensures keys added without attributes are unrestricted
+func (suite *UserTestSuite) TestKeyAttributesWithoutAttributes() {
+    user, err := suite.US.Create("acme.user.key.plain")
+    suite.Nil(err)
+    keyRing := user.KeyRing()
+
+    // Encode cert
+    pemFile := new(bytes.Buffer)
+    pem.Encode(pemFile, &pem.Block{Type: "CERTIFICATE", Bytes: suite.generateCertificate()})
+
+    // Add key
+    fp, err := keyRing.AddPublicKey(pemFile.Bytes())
+    suite.Nil(err)
+
+    attributes, err := keyRing.Attributes(fp)
+    suite.Nil(err)
+    suite.WithinDuration(time.Now(), attributes.Created, time.Minute)
+    suite.True(attributes.Expires.IsZero())
+    suite.False(attributes.Expired(time.Now()))
+    suite.Nil(attributes.Namespaces)
+    suite.False(attributes.ReadOnly)
+
+    // Keys of users which don't exist have no attributes
+    ring := &boltKeyRing{[]byte("acme.user.key.none"), suite.KS}
+    _, err = ring.Attributes(fp)
+    suite.Equal(ErrUserDoesNotExist, err)
+}
+
+// TestAddAuthorizedKey ensures authorized_keys lines are added with their comment and expiry
+func (suite *UserTestSuite) TestAddAuthorizedKey() {
+    user, err := suite.US.Create("acme.user.key.authorized")
+    suite.Nil(err)
+    keyRing := user.KeyRing()
+
+    // Create key
+    key, _ := generateKey(suite.T(), ssh.KeyAlgoED25519)
+    line := bytes.TrimSpace(ssh.MarshalAuthorizedKey(key))
+    line = append([]byte(`no-port-forwarding,expiry-time="20301231Z",command="SHOW NAMESPACES" `), line...)
+    line = append(line, " ci@build"...)
+
+    // Add key
+    fp, err := keyRing.AddPublicKey(line)
+    suite.Nil(err)
+    suite.Equal(auth.CreateFingerprint(key.Marshal()), fp)
+    suite.True(keyRing.Contains(key.Marshal()))
+
+    attributes, err := keyRing.Attributes(fp)
+    suite.Nil(err)
+    suite.Equal("ci@build", attributes.Comment)
+    suite.Equal("SHOW NAMESPACES", attributes.Command)
+    suite.True(time.Date(2030, 12, 31, 0, 0, 0, 0, time.UTC).Equal(attributes.Expires))
+
+    // Attributes override the options of the key
+    expires := time.Now().Add(time.Hour).UTC()
+    fp, err = keyRing.AddPublicKeyWithAttributes(line, KeyAttributes{Comment: "nightly build", Expires: expires})
+    suite.Nil(err)
+
+    attributes, err = keyRing.Attributes(fp)
+    suite.Nil(err)
+    suite.Equal("nightly build", attributes.Comment)
+    suite.True(expires.Equal(attributes.Expires))
+    suite.Equal(1, len(keyRing.ListPublicKeys()))
+}
+
+// TestKeyRestrictions ensures keys expire and are restricted to their namespaces
+func (suite *UserTestSuite) TestKeyRestrictions() {
+    now := time.Now()
+    attributes := KeyAttributes{Expires: now, Namespaces: []string{"acme.metrics"}}
+
+    suite.False(attributes.Expired(now.Add(-time.Second)))
+    suite.True(attributes.Expired(now))
+    suite.True(attributes.Expired(now.Add(time.Second)))
+
+    suite.True(attributes.AllowsNamespace("acme.metrics"))
+    suite.True(attributes.AllowsNamespace("acme.metrics.cpu"))
+    suite.False(attributes.AllowsNamespace("acme"))
+    suite.False(attributes.AllowsNamespace("acme.metricsold"))
+    suite.True(KeyAttributes{}.AllowsNamespace("acme"))
+}
+
 func (suite *UserTestSuite) TestListPublicKeys() {
     name := "acme.user.list.keys"
 
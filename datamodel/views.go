@@ -0,0 +1,322 @@
+package datamodel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/subsilent/kappa/common"
+)
+
+var (
+
+	// ErrViewDoesNotExist is returned if a view does not exist when an operation is attempted to be performed on it
+	ErrViewDoesNotExist = common.NewError(common.ViewDoesNotExist, "view does not exist")
+
+	// ErrViewAlreadyExists is returned when creating a view which already exists
+	ErrViewAlreadyExists = common.NewError(common.ViewAlreadyExists, "view already exists")
+
+	// ErrStaleCheckpoint is returned when rows are applied to a view from an offset other than its checkpoint.
+	// This happens when the view was updated concurrently.
+	ErrStaleCheckpoint = fmt.Errorf("view checkpoint is stale")
+)
+
+// ViewRow is a materialized row of a view
+type ViewRow struct {
+	Key  []byte
+	Data []byte
+}
+
+// View is a materialized query over a log. Rows are stored by key along with a checkpoint, which is the offset of
+// the next source record to be applied.
+type View interface {
+
+	// Name returns the fully qualified view name
+	Name() string
+
+	// Namespace returns the namespace the view belongs to
+	Namespace() string
+
+	// Created returns the time the view was created
+	Created() time.Time
+
+	// Source returns the fully qualified name of the log the view is maintained from
+	Source() string
+
+	// Query returns the statement defining the view
+	Query() string
+
+	// ClusteredBy returns the field rows are keyed by. If empty, rows are keyed by source offset.
+	ClusteredBy() string
+
+	// Checkpoint returns the offset of the next source record to be applied
+	Checkpoint() uint64
+
+	// Apply stores rows and advances the checkpoint from one offset to another in a single transaction.
+	// Rows with an existing key replace the previous row. ErrStaleCheckpoint is returned if the checkpoint
+	// is not at the from offset.
+	Apply(rows []ViewRow, from, to uint64) error
+
+	// Rows calls fn for each row in key order until fn returns false
+	Rows(fn func(row ViewRow) bool) error
+}
+
+// ViewStore contains view definitions and their materialized rows
+type ViewStore interface {
+
+	// Get returns a View by name
+	Get(name string) (View, error)
+
+	// Create inserts a new view into the given namespace
+	Create(namespace, name, source, query, clusteredBy string) (View, error)
+
+	// Delete removes a view and all of its rows
+	Delete(name string) error
+
+	// Stream returns a channel of view names
+	Stream() chan string
+}
+
+// NewBoltViewStore creates a new ViewStore using the given keyspace
+func NewBoltViewStore(ks leaf.Keyspace) ViewStore {
+	return &boltViewStore{ks}
+}
+
+type boltViewStore struct {
+	ks leaf.Keyspace
+}
+
+// Create adds a view to the database
+func (b boltViewStore) Create(namespace, name, source, query, clusteredBy string) (v View, err error) {
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Verify the view does not exist
+		if bkt.Bucket([]byte(name)) != nil {
+			err = ErrViewAlreadyExists
+			return
+		}
+
+		// Create bucket
+		viewBucket, e := bkt.CreateBucket([]byte(name))
+		if e != nil {
+			err = e
+			return
+		}
+
+		// Save view definition
+		definition := map[string]string{
+			"namespace": namespace,
+			"created":   time.Now().UTC().Format(time.RFC3339Nano),
+			"source":    source,
+			"query":     query,
+			"clustered": clusteredBy,
+		}
+		for key, value := range definition {
+			if err = viewBucket.Put([]byte(key), []byte(value)); err != nil {
+				return
+			}
+		}
+		if err = viewBucket.Put([]byte("checkpoint"), encodeOffset(0)); err != nil {
+			return
+		}
+
+		// Create row bucket
+		if _, err = viewBucket.CreateBucket([]byte("rows")); err != nil {
+			return
+		}
+		v = boltView{[]byte(name), b.ks}
+		return
+	})
+	return
+}
+
+// Get returns a View, returning an error if it doesn't exist
+func (b boltViewStore) Get(name string) (v View, err error) {
+	b.ks.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get view bucket
+		if bkt.Bucket([]byte(name)) == nil {
+			err = ErrViewDoesNotExist
+			return
+		}
+		v = boltView{[]byte(name), b.ks}
+		return
+	})
+	return
+}
+
+// Delete removes a view definition and all of its rows
+func (b boltViewStore) Delete(name string) (err error) {
+	b.ks.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Delete bucket
+		if err = bkt.DeleteBucket([]byte(name)); err == bolt.ErrBucketNotFound {
+			err = ErrViewDoesNotExist
+		}
+		return
+	})
+	return
+}
+
+// Stream returns a channel of view names
+func (b boltViewStore) Stream() chan string {
+	out := make(chan string)
+
+	// Read views in background
+	go func(channel chan<- string) {
+		b.ks.ReadTx(func(bkt *bolt.Bucket) {
+			cur := bkt.Cursor()
+
+			// Iterate over keys
+			for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+				channel <- string(k)
+			}
+
+			// Close channel
+			close(channel)
+			return
+		})
+	}(out)
+	return out
+}
+
+// boltView implements the View interface on top of boltdb
+//
+// Each view has a bucket in the keyspace containing the definition, the checkpoint and a sub-bucket of rows.
+type boltView struct {
+	name  []byte
+	views leaf.Keyspace
+}
+
+// get returns a value from the view definition
+func (b boltView) get(key string) (value string) {
+	b.views.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get view bucket
+		view := bkt.Bucket(b.name)
+		if view == nil {
+			return
+		}
+		value = string(view.Get([]byte(key)))
+		return
+	})
+	return
+}
+
+// Name returns the fully qualified view name
+func (b boltView) Name() string {
+	return string(b.name)
+}
+
+// Namespace returns the namespace the view belongs to
+func (b boltView) Namespace() string {
+	return b.get("namespace")
+}
+
+// Created returns the time the view was created
+func (b boltView) Created() time.Time {
+	created, _ := time.Parse(time.RFC3339Nano, b.get("created"))
+	return created
+}
+
+// Source returns the fully qualified name of the log the view is maintained from
+func (b boltView) Source() string {
+	return b.get("source")
+}
+
+// Query returns the statement defining the view
+func (b boltView) Query() string {
+	return b.get("query")
+}
+
+// ClusteredBy returns the field rows are keyed by
+func (b boltView) ClusteredBy() string {
+	return b.get("clustered")
+}
+
+// Checkpoint returns the offset of the next source record to be applied
+func (b boltView) Checkpoint() (offset uint64) {
+	b.views.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get view bucket
+		view := bkt.Bucket(b.name)
+		if view == nil {
+			return
+		}
+		offset = decodeOffset(view.Get([]byte("checkpoint")))
+		return
+	})
+	return
+}
+
+// Apply stores rows and advances the checkpoint in a single transaction
+func (b boltView) Apply(rows []ViewRow, from, to uint64) (err error) {
+	b.views.WriteTx(func(bkt *bolt.Bucket) {
+
+		// Get view bucket
+		view := bkt.Bucket(b.name)
+		if view == nil {
+			err = ErrViewDoesNotExist
+			return
+		}
+
+		// Verify the view has not been updated since the rows were computed
+		if decodeOffset(view.Get([]byte("checkpoint"))) != from {
+			err = ErrStaleCheckpoint
+			return
+		}
+
+		// Store rows
+		rowBucket := view.Bucket([]byte("rows"))
+		for _, row := range rows {
+			if err = rowBucket.Put(row.Key, row.Data); err != nil {
+				return
+			}
+		}
+
+		// Advance checkpoint
+		err = view.Put([]byte("checkpoint"), encodeOffset(to))
+		return
+	})
+	return
+}
+
+// Rows calls fn for each row in key order until fn returns false
+func (b boltView) Rows(fn func(row ViewRow) bool) (err error) {
+	b.views.ReadTx(func(bkt *bolt.Bucket) {
+
+		// Get view bucket
+		view := bkt.Bucket(b.name)
+		if view == nil {
+			err = ErrViewDoesNotExist
+			return
+		}
+
+		// Iterate over rows
+		cur := view.Bucket([]byte("rows")).Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			if !fn(ViewRow{k, v}) {
+				return
+			}
+		}
+		return
+	})
+	return
+}
+
+// encodeOffset returns the big endian encoding of an offset so offsets sort in order
+func encodeOffset(offset uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, offset)
+	return buf
+}
+
+// decodeOffset returns the offset encoded by encodeOffset
+func decodeOffset(buf []byte) uint64 {
+	if len(buf) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
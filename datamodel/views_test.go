@@ -0,0 +1,156 @@
+package datamodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/eliquious/leaf"
+	"github.com/stretchr/testify/suite"
+)
+
+// TestViewTestSuite runs the ViewTestSuite
+func TestViewTestSuite(t *testing.T) {
+	suite.Run(t, new(ViewTestSuite))
+}
+
+// ViewTestSuite tests the view store
+type ViewTestSuite struct {
+	suite.Suite
+	Dir string
+	DB  leaf.KeyValueDatabase
+	VS  ViewStore
+	KS  leaf.Keyspace
+}
+
+// SetupSuite prepares the suite before any tests are ran
+func (suite *ViewTestSuite) SetupSuite() {
+
+	// Create temp directory
+	suite.Dir, _ = ioutil.TempDir("", "datamodel.test")
+
+	// Connect to database
+	db, err := leaf.NewLeaf(path.Join(suite.Dir, "test.db"))
+	if err != nil {
+		suite.T().Log("Error creating database")
+		suite.T().FailNow()
+	}
+	suite.DB = db
+
+	// Create keyspace
+	ks, err := db.GetOrCreateKeyspace(Views)
+	suite.Nil(err)
+	suite.KS = ks
+
+	// Create view store
+	suite.VS = NewBoltViewStore(ks)
+}
+
+// TearDownSuite cleans up suite state after all the tests have completed
+func (suite *ViewTestSuite) TearDownSuite() {
+
+	// Close database
+	suite.DB.Close()
+
+	// Clear test directory
+	os.RemoveAll(suite.Dir)
+}
+
+// TestCreateView ensures a view can be created
+func (suite *ViewTestSuite) TestCreateView() {
+	v, err := suite.VS.Create("acme", "acme.create", "acme.events", "SELECT * FROM acme.events", "id")
+	suite.Nil(err)
+	suite.NotNil(v)
+	suite.Equal("acme.create", v.Name())
+	suite.Equal("acme", v.Namespace())
+	suite.Equal("acme.events", v.Source())
+	suite.Equal("SELECT * FROM acme.events", v.Query())
+	suite.Equal("id", v.ClusteredBy())
+	suite.Equal(uint64(0), v.Checkpoint())
+	suite.WithinDuration(time.Now(), v.Created(), time.Minute)
+
+	// Test that the view was created
+	suite.KS.ReadTx(func(bkt *bolt.Bucket) {
+		suite.NotNil(bkt.Bucket([]byte("acme.create")))
+	})
+
+	// Creating the view again fails
+	v, err = suite.VS.Create("acme", "acme.create", "acme.events", "SELECT * FROM acme.events", "")
+	suite.Equal(ErrViewAlreadyExists, err)
+	suite.Nil(v)
+}
+
+// TestGetView ensures a view can be retrieved
+func (suite *ViewTestSuite) TestGetView() {
+	v, err := suite.VS.Get("acme.none")
+	suite.Equal(ErrViewDoesNotExist, err)
+	suite.Nil(v)
+
+	_, err = suite.VS.Create("acme", "acme.get", "acme.events", "SELECT * FROM acme.events", "")
+	suite.Nil(err)
+
+	v, err = suite.VS.Get("acme.get")
+	suite.Nil(err)
+	suite.Equal("acme.get", v.Name())
+	suite.Equal("", v.ClusteredBy())
+}
+
+// TestApplyRows ensures rows and the checkpoint are updated together
+func (suite *ViewTestSuite) TestApplyRows() {
+	v, err := suite.VS.Create("acme", "acme.apply", "acme.events", "SELECT * FROM acme.events", "id")
+	suite.Nil(err)
+
+	suite.Nil(v.Apply([]ViewRow{{[]byte("1"), []byte("a")}, {[]byte("2"), []byte("b")}}, 0, 5))
+	suite.Equal(uint64(5), v.Checkpoint())
+
+	// Existing keys are replaced
+	suite.Nil(v.Apply([]ViewRow{{[]byte("1"), []byte("c")}}, 5, 6))
+	suite.Equal(uint64(6), v.Checkpoint())
+
+	// Stale checkpoints are rejected
+	suite.Equal(ErrStaleCheckpoint, v.Apply([]ViewRow{{[]byte("3"), []byte("d")}}, 5, 7))
+	suite.Equal(uint64(6), v.Checkpoint())
+
+	var rows []string
+	suite.Nil(v.Rows(func(row ViewRow) bool {
+		rows = append(rows, string(row.Key)+"="+string(row.Data))
+		return true
+	}))
+	suite.Equal([]string{"1=c", "2=b"}, rows)
+
+	// Iteration stops early
+	var count int
+	suite.Nil(v.Rows(func(row ViewRow) bool {
+		count++
+		return false
+	}))
+	suite.Equal(1, count)
+}
+
+// TestDeleteView ensures a view and its rows can be deleted
+func (suite *ViewTestSuite) TestDeleteView() {
+	v, err := suite.VS.Create("acme", "acme.delete", "acme.events", "SELECT * FROM acme.events", "")
+	suite.Nil(err)
+
+	suite.Nil(suite.VS.Delete("acme.delete"))
+	suite.Equal(ErrViewDoesNotExist, suite.VS.Delete("acme.delete"))
+	suite.Equal(ErrViewDoesNotExist, v.Apply(nil, 0, 1))
+}
+
+// TestStreamViews ensures all views are streamed
+func (suite *ViewTestSuite) TestStreamViews() {
+	_, err := suite.VS.Create("acme", "acme.stream", "acme.events", "SELECT * FROM acme.events", "")
+	suite.Nil(err)
+
+	var found bool
+	for name := range suite.VS.Stream() {
+		if name == "acme.stream" {
+			found = true
+		}
+	}
+	suite.True(found)
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Statements are authorized by the namespace they act on. These interfaces are used to find it.
+type (
+
+	// namespaced statements act on a namespace. An empty namespace refers to the session namespace.
+	namespaced interface {
+		Namespace() string
+	}
+
+	// named statements act on an object named relative to the session namespace
+	named interface {
+		Name() string
+	}
+
+	// logged statements act on a log named relative to the session namespace
+	logged interface {
+		Log() string
+	}
+
+	// sourced statements read from a log or view named relative to the session namespace
+	sourced interface {
+		Source() string
+	}
+
+	// account statements act on a user account rather than a namespace
+	account interface {
+		Username() string
+	}
+)
+
+// usePermission is the permission required by USE statements
+var usePermission = skl.UseStatement{}.RequiredPermissions()
+
+// target is the namespace a statement acts on
+type target struct {
+
+	// namespace is the namespace in which the required permission must be granted
+	namespace string
+
+	// system is true for statements acting on the system as a whole, which only superusers may execute
+	system bool
+}
+
+// authorize verifies the session user may execute a statement. Superusers may execute every statement. Other users
+// must have been granted the permission required by the statement through one of their roles for the namespace the
+// statement acts on or one of its parents. Members of a namespace may use it unless one of their roles denies
+// 'use.namespace'. Statements without a required permission are always allowed. The restrictions of the key the
+// user logged in with apply to every user, including superusers.
+//
+// Statements which act on a relative name without a session namespace are left to their handlers which fail to
+// resolve the name. Statements without a namespace, such as SHOW NAMESPACES, only list what the user can see.
+func (e *Executor) authorize(stmt skl.Statement) *common.Error {
+	if err := e.authorizeKey(stmt); err != nil {
+		return err
+	}
+
+	permission := stmt.RequiredPermissions()
+	if permission == "" || e.session.user.IsAdmin() {
+		return nil
+	}
+
+	t, ok := e.target(stmt)
+	if !ok {
+		return nil
+	} else if t.system {
+		return common.NewError(common.Unauthorized, "permission '%s' is reserved for superusers", permission).
+			With("permission", permission)
+	}
+	return e.authorizeNamespace(t.namespace, permission)
+}
+
+// authorizeKey verifies the key of the session allows a statement. Read-only keys only allow statements which don't
+// modify anything and keys restricted to namespaces only allow statements acting on those namespaces or their
+// children.
+func (e *Executor) authorizeKey(stmt skl.Statement) *common.Error {
+	key := e.session.key
+	if key.ReadOnly && !readOnly(stmt) {
+		return common.NewError(common.Unauthorized, "key only allows read-only statements")
+	} else if len(key.Namespaces) == 0 {
+		return nil
+	}
+
+	t, ok := e.target(stmt)
+	if !ok {
+		return nil
+	} else if t.system || !key.AllowsNamespace(t.namespace) {
+		return common.NewError(common.Unauthorized, "key is restricted to namespaces %s",
+			strings.Join(key.Namespaces, ", ")).
+			With("namespaces", key.Namespaces)
+	}
+	return nil
+}
+
+// readOnly determines if a statement only reads data or changes the session
+func readOnly(stmt skl.Statement) bool {
+	switch stmt.(type) {
+	case *skl.UseStatement, *skl.SetOptionStatement, *skl.SelectStatement, *skl.SubscribeStatement,
+		*skl.UnsubscribeStatement, *skl.ShowNamespacesStatement, *skl.ShowUsersStatement, *skl.ShowRolesStatement,
+		*skl.ShowPermissionsStatement, *skl.ShowLogsStatement, *skl.ShowViewsStatement, *skl.ShowTypesStatement,
+		*skl.ShowKeysStatement, *skl.DescribeNamespaceStatement, *skl.DescribeTypeStatement,
+		*skl.DescribeLogStatement, *skl.DescribeViewStatement:
+		return true
+	}
+	return false
+}
+
+// authorizeNamespace verifies the session user has been granted a permission for a namespace. Denials explain which
+// role entry denied the permission, if any.
+func (e *Executor) authorizeNamespace(namespace, permission string) *common.Error {
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		return common.NewError(common.InternalServerError, "could not access namespace data")
+	}
+
+	// Verify namespace existence
+	_, err = namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		return common.NewError(common.NamespaceDoesNotExist, "%s", namespace)
+	} else if err != nil {
+		return common.NewError(common.InternalServerError, "could not access namespace data")
+	}
+
+	// Resolve permission. Members may use their namespaces unless one of their roles denies it, as roles created
+	// before USE required a permission don't grant it.
+	decision := datamodel.ResolvePermission(namespaceStore, e.session.user, namespace, permission)
+	if !decision.Allowed && decision.Entry == "" && permission == usePermission && isMember(e.session.user, namespace) {
+		return nil
+	} else if !decision.Allowed {
+		return common.NewError(common.Unauthorized, "permission '%s' required for namespace '%s': %s",
+			permission, namespace, decision.Reason()).
+			With("permission", permission).
+			With("namespace", namespace).
+			With("reason", decision.Reason())
+	}
+	return nil
+}
+
+// target determines the namespace a statement acts on. It returns false if there is none or it can't be resolved.
+func (e *Executor) target(stmt skl.Statement) (target, bool) {
+	switch s := stmt.(type) {
+
+	// Namespaces are created and dropped by the parent namespace and root namespaces by the system
+	case *skl.CreateNamespaceStatement:
+		return parentTarget(s.Namespace())
+	case *skl.DropNamespaceStatement:
+		return parentTarget(s.Namespace())
+
+	// Every namespace of the user is listed
+	case *skl.ShowNamespacesStatement:
+		return target{}, false
+
+	case namespaced:
+		if namespace := s.Namespace(); namespace != "" {
+			return target{namespace: namespace}, true
+		}
+	case named:
+		return e.objectTarget(s.Name())
+	case logged:
+		return e.objectTarget(s.Log())
+	case sourced:
+		return e.objectTarget(s.Source())
+	case account:
+		return target{system: true}, true
+	}
+
+	// Default to the session namespace
+	return target{namespace: e.session.namespace}, e.session.namespace != ""
+}
+
+// objectTarget returns the namespace of an object name relative to the session namespace
+func (e *Executor) objectTarget(name string) (target, bool) {
+	namespace, _, ok := e.qualifiedName(name)
+	return target{namespace: namespace}, ok
+}
+
+// parentTarget returns the parent of a namespace. Root namespaces have the system as their parent.
+func parentTarget(namespace string) (target, bool) {
+	parent := datamodel.ParentNamespace(namespace)
+	if parent == "" {
+		return target{system: true}, true
+	}
+	return target{namespace: parent}, true
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Ensure statements are authorized by the roles of the user and the restrictions of the key
+func TestAuthorize(t *testing.T) {
+	system, admin := newSystem(t)
+	code, out := execute(t, system, admin, `CREATE NAMESPACE acme; CREATE NAMESPACE acme.billing; CREATE NAMESPACE other;
+		CREATE ROLE writer ON acme; ADD PERMISSIONS *, -create.*, create.log, show.logs TO ROLE writer ON acme;
+		CREATE ROLE auditor ON acme; ADD PERMISSIONS -select.log, -show.logs TO ROLE auditor ON acme;
+		CREATE ROLE clerk ON acme.billing; ADD PERMISSION select.log TO ROLE clerk ON acme.billing;
+		CREATE ROLE guest ON other; ADD PERMISSION -use.namespace TO ROLE guest ON other;
+		CREATE USER bob; ADD ROLE writer TO USER bob ON acme; ADD ROLE auditor TO USER bob ON acme;
+		ADD ROLE clerk TO USER bob ON acme.billing; CREATE USER carol; ADD ROLE clerk TO USER carol ON acme.billing;
+		CREATE USER eve; ADD ROLE guest TO USER eve ON other`)
+	if !assert.Equal(t, common.OK, code, out) {
+		return
+	}
+
+	users, err := system.Users()
+	assert.Nil(t, err)
+	bob, err := users.Get("bob")
+	assert.Nil(t, err)
+	carol, err := users.Get("carol")
+	assert.Nil(t, err)
+	eve, err := users.Get("eve")
+	assert.Nil(t, err)
+
+	var tests = []struct {
+		user      datamodel.User
+		namespace string
+		key       datamodel.KeyAttributes
+		query     string
+		code      common.StatusCode
+		message   string
+	}{
+
+		// Superusers and system statements
+		{user: admin, query: `CREATE NAMESPACE top`, code: common.OK},
+		{user: admin, query: `SELECT * FROM other.events`, code: common.OK},
+		{user: bob, query: `CREATE NAMESPACE top`, code: common.Unauthorized, message: "reserved for superusers"},
+		{user: bob, query: `DROP USER admin`, code: common.Unauthorized, message: "reserved for superusers"},
+
+		// Wildcards, denials and their precedence
+		{user: bob, query: `INSERT INTO acme.events {id: 1}`, code: common.OK},
+		{user: bob, query: `CREATE LOG acme.audit`, code: common.OK},
+		{user: bob, query: `CREATE TYPE acme.Event (id uint64)`, code: common.Unauthorized,
+			message: "denied by '-create.*' of role 'writer'"},
+		{user: bob, query: `SELECT * FROM acme.events`, code: common.Unauthorized, message: "denied by '-select.log' of role 'auditor'"},
+		{user: bob, namespace: "acme", query: `SHOW LOGS`, code: common.Unauthorized,
+			message: "denied by '-show.logs' of role 'auditor'"},
+
+		// Roles of child namespaces override those of their parents, which are inherited otherwise
+		{user: bob, query: `SELECT * FROM acme.billing.invoices`, code: common.OK},
+		{user: bob, query: `INSERT INTO acme.billing.invoices {id: 1}`, code: common.OK},
+		{user: bob, query: `SELECT * FROM other.events`, code: common.Unauthorized, message: "no role grants 'select.log'"},
+		{user: bob, query: `SELECT * FROM missing.events`, code: common.NamespaceDoesNotExist},
+
+		// Members may use their namespaces and those of their children unless a role denies it
+		{user: bob, query: `USE acme`, code: common.OK},
+		{user: carol, query: `USE acme.billing`, code: common.OK},
+		{user: carol, query: `USE acme`, code: common.Unauthorized, message: "no role grants 'use.namespace'"},
+		{user: bob, query: `USE other`, code: common.Unauthorized, message: "no role grants 'use.namespace'"},
+		{user: eve, query: `USE other`, code: common.Unauthorized, message: "denied by '-use.namespace' of role 'guest'"},
+		{user: eve, query: `USE acme`, code: common.Unauthorized, message: "no role grants 'use.namespace'"},
+
+		// Read-only keys
+		{user: bob, key: datamodel.KeyAttributes{ReadOnly: true}, query: `INSERT INTO acme.events {id: 1}`,
+			code: common.Unauthorized, message: "read-only"},
+		{user: bob, key: datamodel.KeyAttributes{ReadOnly: true}, query: `SELECT * FROM acme.billing.invoices`, code: common.OK},
+		{user: admin, key: datamodel.KeyAttributes{ReadOnly: true}, query: `CREATE NAMESPACE top`,
+			code: common.Unauthorized, message: "read-only"},
+		{user: admin, key: datamodel.KeyAttributes{ReadOnly: true}, query: `SET FORMAT json`, code: common.OK},
+
+		// Keys restricted to namespaces allow their children
+		{user: admin, key: datamodel.KeyAttributes{Namespaces: []string{"acme"}}, query: `SELECT * FROM acme.billing.invoices`,
+			code: common.OK},
+		{user: admin, key: datamodel.KeyAttributes{Namespaces: []string{"acme"}}, query: `SELECT * FROM other.events`,
+			code: common.Unauthorized, message: "restricted to namespaces acme"},
+		{user: admin, key: datamodel.KeyAttributes{Namespaces: []string{"acme"}}, query: `CREATE NAMESPACE top`,
+			code: common.Unauthorized, message: "restricted to namespaces acme"},
+		{user: admin, key: datamodel.KeyAttributes{Namespaces: []string{"acme"}}, query: `DROP USER bob`,
+			code: common.Unauthorized, message: "restricted to namespaces acme"},
+		{user: bob, key: datamodel.KeyAttributes{Namespaces: []string{"acme.billing"}}, query: `INSERT INTO acme.events {id: 1}`,
+			code: common.Unauthorized, message: "restricted to namespaces acme.billing"},
+
+		// Statements without a namespace are left to their handlers
+		{user: bob, query: `SELECT * FROM events`, code: common.OK},
+		{user: bob, query: `SHOW NAMESPACES`, code: common.OK},
+		{user: bob, key: datamodel.KeyAttributes{Namespaces: []string{"acme"}}, query: `SELECT * FROM events`, code: common.OK},
+		{user: bob, namespace: "acme", query: `SELECT * FROM events`, code: common.Unauthorized, message: "-select.log"},
+	}
+
+	for i, tt := range tests {
+		query, err := skl.ParseQuery(tt.query)
+		if !assert.Nil(t, err, "%d. %s", i, tt.query) {
+			continue
+		}
+
+		e := NewExecutor(NewSession(tt.namespace, tt.user).Restrict(tt.key), testTerminal{}, system)
+		code := common.OK
+		if err := e.authorize(query.Statements[0].Statement); err != nil {
+			code = err.Code
+			assert.Contains(t, err.Message, tt.message, "%d. %s", i, tt.query)
+		}
+		assert.Equal(t, tt.code, code, "%d. %s", i, tt.query)
+	}
+}
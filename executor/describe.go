@@ -0,0 +1,329 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// NamespaceDescription is the result of DESCRIBE NAMESPACE
+type NamespaceDescription struct {
+	Name     string            `json:"name"`
+	Users    []string          `json:"users"`
+	Roles    []RoleDescription `json:"roles"`
+	Children []string          `json:"children"`
+}
+
+// RoleDescription is a role and the permissions granted to it
+type RoleDescription struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// TypeDescription is the result of DESCRIBE TYPE
+type TypeDescription struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Fields    []datamodel.Field `json:"fields"`
+}
+
+// LogDescription is the result of DESCRIBE LOG
+type LogDescription struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Type         string    `json:"type"`
+	Created      time.Time `json:"created"`
+	OldestOffset uint64    `json:"oldest_offset"`
+	NextOffset   uint64    `json:"next_offset"`
+	Segments     int       `json:"segments"`
+	Size         int64     `json:"size"`
+}
+
+// ViewDescription is the result of DESCRIBE VIEW. Lag is the number of source records not yet applied to the view.
+type ViewDescription struct {
+	Name        string    `json:"name"`
+	Namespace   string    `json:"namespace"`
+	Created     time.Time `json:"created"`
+	Source      string    `json:"source"`
+	Query       string    `json:"query"`
+	ClusteredBy string    `json:"clustered_by"`
+	Checkpoint  uint64    `json:"checkpoint"`
+	Lag         uint64    `json:"lag"`
+}
+
+// Users other than superusers must belong to the namespace being described.
+func (e *Executor) handleDescribeNamespace(w *common.ResponseWriter, stmt skl.Statement) {
+
+	describeStatement, ok := stmt.(*skl.DescribeNamespaceStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DescribeNamespaceStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	namespace := describeStatement.Namespace()
+	ns, ok := e.getVisibleNamespace(w, namespace)
+	if !ok {
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	desc := NamespaceDescription{Name: namespace, Users: ns.Users(), Children: namespaceStore.Children(namespace)}
+	sort.Strings(desc.Users)
+
+	roles := ns.Roles()
+	sort.Strings(roles)
+	for _, role := range roles {
+		permissions := ns.Permissions(role)
+		sort.Strings(permissions)
+		desc.Roles = append(desc.Roles, RoleDescription{role, permissions})
+	}
+
+	roleSet := common.ResultSet{Columns: []string{"role", "permissions"}}
+	for _, role := range desc.Roles {
+		roleSet.Rows = append(roleSet.Rows, []interface{}{role.Name, strings.Join(role.Permissions, ", ")})
+	}
+
+	writeDescription(w, desc,
+		properties([]string{"Name"}, desc.Name),
+		list("user", desc.Users),
+		roleSet,
+		list("child", desc.Children),
+	)
+}
+
+// Users other than superusers must belong to the namespace of the type.
+func (e *Executor) handleDescribeType(w *common.ResponseWriter, stmt skl.Statement) {
+
+	describeStatement, ok := stmt.(*skl.DescribeTypeStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DescribeTypeStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve type name
+	_, name, ok := e.qualifiedName(describeStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the type name '%s'", name)
+		return
+	}
+
+	// Get type store
+	typeStore, err := e.system.Types()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return
+	}
+
+	// Verify type existence
+	schema, err := typeStore.Get(name)
+	if err == datamodel.ErrTypeDoesNotExist {
+		w.Fail(common.TypeDoesNotExist, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return
+	}
+
+	// Verify visibility
+	if _, ok := e.getVisibleNamespace(w, schema.Namespace()); !ok {
+		return
+	}
+
+	desc := TypeDescription{Name: schema.Name(), Namespace: schema.Namespace(), Fields: schema.Fields()}
+
+	fieldSet := common.ResultSet{Columns: []string{"field", "type", "required"}}
+	for _, field := range desc.Fields {
+		fieldSet.Rows = append(fieldSet.Rows, []interface{}{field.Name, string(field.Type), field.Required})
+	}
+
+	writeDescription(w, desc,
+		properties([]string{"Name", "Namespace"}, desc.Name, desc.Namespace),
+		fieldSet,
+	)
+}
+
+// Users other than superusers must belong to the namespace of the log.
+func (e *Executor) handleDescribeLog(w *common.ResponseWriter, stmt skl.Statement) {
+
+	describeStatement, ok := stmt.(*skl.DescribeLogStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DescribeLogStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve log name
+	_, name, ok := e.qualifiedName(describeStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the log name '%s'", name)
+		return
+	}
+
+	// Get log store
+	logStore, err := e.system.Logs()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return
+	}
+
+	// Verify log existence
+	log, err := logStore.Get(name)
+	if err == datamodel.ErrLogDoesNotExist {
+		w.Fail(common.LogDoesNotExist, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return
+	}
+
+	// Verify visibility
+	if _, ok := e.getVisibleNamespace(w, log.Namespace()); !ok {
+		return
+	}
+
+	// Open log segments
+	records, err := log.Open()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not open log '%s'", name)
+		return
+	}
+
+	desc := LogDescription{
+		Name:         log.Name(),
+		Namespace:    log.Namespace(),
+		Type:         log.Type(),
+		Created:      log.Created(),
+		OldestOffset: records.OldestOffset(),
+		NextOffset:   records.NextOffset(),
+		Segments:     records.Segments(),
+		Size:         records.Size(),
+	}
+
+	writeDescription(w, desc, properties(
+		[]string{"Name", "Namespace", "Type", "Created", "Oldest offset", "Next offset", "Segments", "Size"},
+		desc.Name, desc.Namespace, desc.Type, desc.Created, desc.OldestOffset, desc.NextOffset, desc.Segments, desc.Size,
+	))
+}
+
+// Users other than superusers must belong to the namespace of the view.
+func (e *Executor) handleDescribeView(w *common.ResponseWriter, stmt skl.Statement) {
+
+	describeStatement, ok := stmt.(*skl.DescribeViewStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DescribeViewStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve view name
+	_, name, ok := e.qualifiedName(describeStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the view name '%s'", name)
+		return
+	}
+
+	// Get view store
+	viewStore, err := e.system.Views()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access view data")
+		return
+	}
+
+	// Verify view existence
+	view, err := viewStore.Get(name)
+	if err == datamodel.ErrViewDoesNotExist {
+		w.Fail(common.ViewDoesNotExist, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access view data")
+		return
+	}
+
+	// Verify visibility
+	if _, ok := e.getVisibleNamespace(w, view.Namespace()); !ok {
+		return
+	}
+
+	// Get source log
+	logStore, err := e.system.Logs()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return
+	}
+	log, err := logStore.Get(view.Source())
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access source log of view '%s'", name)
+		return
+	}
+	records, err := log.Open()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not open log '%s'", log.Name())
+		return
+	}
+
+	desc := ViewDescription{
+		Name:        view.Name(),
+		Namespace:   view.Namespace(),
+		Created:     view.Created(),
+		Source:      view.Source(),
+		Query:       view.Query(),
+		ClusteredBy: view.ClusteredBy(),
+		Checkpoint:  view.Checkpoint(),
+	}
+	if next := records.NextOffset(); next > desc.Checkpoint {
+		desc.Lag = next - desc.Checkpoint
+	}
+
+	writeDescription(w, desc, properties(
+		[]string{"Name", "Namespace", "Created", "Source", "Query", "Clustered by", "Checkpoint", "Lag"},
+		desc.Name, desc.Namespace, desc.Created, desc.Source, desc.Query, desc.ClusteredBy, desc.Checkpoint, desc.Lag,
+	))
+}
+
+// writeDescription writes a description as a single JSON object in the JSON format. Other formats write the
+// result sets describing it in order.
+func writeDescription(w *common.ResponseWriter, desc interface{}, sets ...common.ResultSet) {
+	if w.Format == "json" {
+		data, err := json.Marshal(desc)
+		if err != nil {
+			w.Fail(common.InternalServerError, "could not encode description")
+			return
+		}
+		w.Write(append(data, '\n'))
+	} else {
+		for _, set := range sets {
+			w.WriteResults(set)
+		}
+	}
+
+	w.Success(common.OK, "")
+}
+
+// properties returns a result set of named values, one row per property
+func properties(names []string, values ...interface{}) common.ResultSet {
+	rs := common.ResultSet{Columns: []string{"property", "value"}}
+	for i, name := range names {
+		rs.Rows = append(rs.Rows, []interface{}{name, values[i]})
+	}
+	return rs
+}
+
+// list returns a result set with a single column
+func list(column string, values []string) common.ResultSet {
+	rs := common.ResultSet{Columns: []string{column}}
+	for _, value := range values {
+		rs.Rows = append(rs.Rows, []interface{}{value})
+	}
+	return rs
+}
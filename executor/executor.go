@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/subsilent/kappa/common"
 	"github.com/subsilent/kappa/datamodel"
@@ -11,17 +12,32 @@ import (
 )
 
 func NewSession(ns string, user datamodel.User) Session {
-	return Session{ns, user}
+	return Session{namespace: ns, user: user}
 }
 
 func NewExecutor(session Session, term common.Terminal, sys datamodel.System) *Executor {
-	return &Executor{session, term, sys}
+	return &Executor{session: session, terminal: term, system: sys, subscriptions: make(map[string]*subscription)}
 }
 
 // Session provides session and connection related information
 type Session struct {
 	namespace string
 	user      datamodel.User
+
+	// Continue executing a query after a statement fails
+	continueOnError bool
+
+	// Output format selected with SET FORMAT. The format of the response writer is used if empty.
+	format string
+
+	// Attributes of the key the user logged in with, which may restrict the session
+	key datamodel.KeyAttributes
+}
+
+// Restrict limits a session to the namespaces and statements allowed by the key the user logged in with
+func (s Session) Restrict(key datamodel.KeyAttributes) Session {
+	s.key = key
+	return s
 }
 
 // Executor executes successfully parsed queries
@@ -29,6 +45,41 @@ type Executor struct {
 	session  Session
 	terminal common.Terminal
 	system   datamodel.System
+
+	// Active subscriptions by log name
+	mutex         sync.Mutex
+	subscriptions map[string]*subscription
+}
+
+// ExecuteQuery executes the statements of a query in order. Once a statement fails the remaining statements are
+// skipped unless the session is set to continue on errors. It returns the number of failed statements.
+// Failures of queries with several statements include the position of the failed statement.
+func (e *Executor) ExecuteQuery(w *common.ResponseWriter, query *skl.Query) (failed int) {
+	defer w.SetPosition(nil)
+	for i, stmt := range query.Statements {
+		if len(query.Statements) > 1 {
+			w.SetPosition(&common.Position{Line: stmt.Pos.Line + 1, Char: stmt.Pos.Char + 1})
+		}
+
+		failures := w.Failures()
+		e.Execute(w, stmt.Statement)
+		if w.Failures() == failures {
+			continue
+		}
+
+		failed++
+		if remaining := len(query.Statements) - i - 1; remaining > 0 && !e.session.continueOnError {
+
+			// Machine readable formats only contain result sets and status codes
+			if !w.Structured() {
+				w.Write(w.Colors.LightGrey)
+				fmt.Fprintf(w, " %d statements skipped after failure\r\n", remaining)
+				w.Write(w.Colors.Reset)
+			}
+			return
+		}
+	}
+	return
 }
 
 // Execute processes each statement
@@ -40,16 +91,97 @@ func (e *Executor) Execute(w *common.ResponseWriter, stmt skl.Statement) {
 		return
 	}
 
+	// Apply the session output format
+	if e.session.format != "" {
+		w.Format = e.session.format
+	}
+
+	// Verify the session user may execute the statement
+	if err := e.authorize(stmt); err != nil {
+		w.Error(err)
+		return
+	}
+
 	switch stmt.NodeType() {
 	case skl.UseNamespaceType:
 		e.handleUseStatement(w, stmt)
 	case skl.CreateNamespaceType:
 		e.handleCreateNamespace(w, stmt)
+	case skl.DropNamespaceType:
+		e.handleDropNamespace(w, stmt)
 	case skl.ShowNamespaceType:
 		e.handleShowNamespace(w, stmt)
+	case skl.CreateLogType:
+		e.handleCreateLog(w, stmt)
+	case skl.InsertType:
+		e.handleInsert(w, stmt)
+	case skl.CreateTypeType:
+		e.handleCreateType(w, stmt)
+	case skl.SelectType:
+		e.handleSelect(w, stmt)
+	case skl.SubscribeType:
+		e.handleSubscribe(w, stmt)
+	case skl.UnsubscribeType:
+		e.handleUnsubscribe(w, stmt)
+	case skl.CreateViewType:
+		e.handleCreateView(w, stmt)
+	case skl.CreateUserType:
+		e.handleCreateUser(w, stmt)
+	case skl.DropUserType:
+		e.handleDropUser(w, stmt)
+	case skl.SetPasswordType:
+		e.handleSetPassword(w, stmt)
+	case skl.AddKeyType:
+		e.handleAddKey(w, stmt)
+	case skl.RemoveKeyType:
+		e.handleRemoveKey(w, stmt)
+	case skl.ShowKeysType:
+		e.handleShowKeys(w, stmt)
+	case skl.AddTOTPType:
+		e.handleAddTOTP(w, stmt)
+	case skl.RemoveTOTPType:
+		e.handleRemoveTOTP(w, stmt)
+	case skl.CreateRoleType:
+		e.handleCreateRole(w, stmt)
+	case skl.AddPermissionsType:
+		e.handleAddPermissions(w, stmt)
+	case skl.RemovePermissionsType:
+		e.handleRemovePermissions(w, stmt)
+	case skl.AddRoleType:
+		e.handleAddRole(w, stmt)
+	case skl.RemoveRoleType:
+		e.handleRemoveRole(w, stmt)
+	case skl.ShowUsersType:
+		e.handleShowUsers(w, stmt)
+	case skl.ShowRolesType:
+		e.handleShowRoles(w, stmt)
+	case skl.ShowPermissionsType:
+		e.handleShowPermissions(w, stmt)
+	case skl.ShowLogsType:
+		e.handleShowLogs(w, stmt)
+	case skl.ShowViewsType:
+		e.handleShowViews(w, stmt)
+	case skl.ShowTypesType:
+		e.handleShowTypes(w, stmt)
+	case skl.DescribeNamespaceType:
+		e.handleDescribeNamespace(w, stmt)
+	case skl.DescribeTypeType:
+		e.handleDescribeType(w, stmt)
+	case skl.DescribeLogType:
+		e.handleDescribeLog(w, stmt)
+	case skl.DescribeViewType:
+		e.handleDescribeView(w, stmt)
+	case skl.SetOptionType:
+		e.handleSetOption(w, stmt)
+	case skl.PromoteUserType:
+		e.handlePromoteUser(w, stmt)
+	case skl.DemoteUserType:
+		e.handleDemoteUser(w, stmt)
 	}
 }
 
+// Users must be members of the namespace or have the 'use.namespace' permission for it, which is verified by the
+// authorizer.
 func (e *Executor) handleUseStatement(w *common.ResponseWriter, stmt skl.Statement) {
 	use, ok := stmt.(*skl.UseStatement)
 	if !ok {
@@ -57,9 +189,6 @@ func (e *Executor) handleUseStatement(w *common.ResponseWriter, stmt skl.Stateme
 		return
 	}
 
-	// Get user from session
-	user := e.session.user
-
 	// Get namespace store
 	namespaceStore, err := e.system.Namespaces()
 	if err != nil {
@@ -73,41 +202,22 @@ func (e *Executor) handleUseStatement(w *common.ResponseWriter, stmt skl.Stateme
 	// Verify namespace existence
 	_, err = namespaceStore.Get(name)
 	if err == datamodel.ErrNamespaceDoesNotExist {
-		w.Fail(common.NamespaceDoesNotExist, name)
+		w.Fail(common.NamespaceDoesNotExist, "%s", name)
 		return
 	} else if err != nil {
 		w.Fail(common.InternalServerError, "could not access namespace data")
 		return
 	}
 
-	// If the user is an admin, grant access
-	if user.IsAdmin() {
-		e.session.namespace = name
-		e.terminal.SetPrompt(fmt.Sprintf("kappa: %s> ", name))
-		w.Success(common.OK, "")
-		return
-	}
-
-	// Verify user has access to the namespace or is admin
-	// 		If user has access, update session namespace and terminal
-	// 		Otherwise, return access denied error
-	for _, namespace := range user.Namespaces() {
-		if namespace == name {
-			e.session.namespace = name
-			e.terminal.SetPrompt(fmt.Sprintf("kappa: %s> ", name))
-			w.Success(common.OK, "")
-			return
-		}
-	}
-
-	// Otherwise, the user is not authorized
-	w.Fail(common.Unauthorized, "")
+	e.session.namespace = name
+	e.terminal.SetPrompt(fmt.Sprintf("kappa: %s> ", name))
+	w.Success(common.OK, "")
 }
 
-// Only the admin can create root namespaces.
-// Admin can also create sub-namespaces for any existing namespace.
-// If the user is not the admin, they must have the 'create.namespace'
-//  permission for the parent namespace.
+// Only superusers can create root namespaces.
+// Superusers can also create sub-namespaces for any existing namespace.
+// Other users must have the 'create.namespace'
+//  permission for the parent namespace, which is verified by the authorizer.
 // Root namespaces don't have any periods.
 func (e *Executor) handleCreateNamespace(w *common.ResponseWriter, stmt skl.Statement) {
 
@@ -124,15 +234,12 @@ func (e *Executor) handleCreateNamespace(w *common.ResponseWriter, stmt skl.Stat
 		return
 	}
 
-	// Get session user
-	user := e.session.user
-
 	// Get namespace
 	namespace := createStatement.Namespace()
 
 	// If err == nil, the namespace already existed
 	if e.namespaceAlreadyExists(namespace, namespaceStore) {
-		w.Success(common.NamespaceAlreadyExists, namespace)
+		w.Success(common.NamespaceAlreadyExists, "%s", namespace)
 		return
 	}
 
@@ -142,58 +249,15 @@ func (e *Executor) handleCreateNamespace(w *common.ResponseWriter, stmt skl.Stat
 		return
 	}
 
-	// Admin user is granted access by default
-	access := user.IsAdmin()
-
-	// Get parent namespace
-	var parent datamodel.Namespace
-	index := strings.LastIndex(namespace, ".")
-	parentNamespace := namespace[:index]
-
-	// If the user is not an admin check their permissions for the parent namespace
-	if !access {
-
-		// Get user roles for parent namespace
-		roles := user.Roles(parentNamespace)
-
-		// Determine if parent namespace exists
-		ns, err := namespaceStore.Get(parentNamespace)
-		if err == datamodel.ErrNamespaceDoesNotExist {
-			w.Fail(common.NamespaceDoesNotExist, parentNamespace)
-			return
-		} else if err != nil {
-			w.Fail(common.InternalServerError, "")
-			return
-		}
-
-		// Memoize parent namespace
-		parent = ns
-
-		// Scan roles for permissions
-		for _, role := range roles {
-			if ns.HasPermission(role, createStatement.RequiredPermissions()) {
-				access = true
-			}
-		}
-
-		// Return error if not authorized
-		if !access {
-			w.Fail(common.Unauthorized, "cannot create namespace '%s'", namespace)
-			return
-		}
-	}
-
-	// If we've gotten this far, the user has permission to create the namespace
-
 	// Get parent namespace
-	if parent == nil {
-
-		// Verify namespace existance
-		parent, err = namespaceStore.Get(parentNamespace)
-		if err != nil {
-			w.Fail(common.InternalServerError, "parent namespace does not exist")
-			return
-		}
+	parentNamespace := namespace[:strings.LastIndex(namespace, ".")]
+	parent, err := namespaceStore.Get(parentNamespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", parentNamespace)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
 	}
 
 	// Create child namespace
@@ -213,9 +277,8 @@ func (e *Executor) handleShowNamespace(w *common.ResponseWriter, stmt skl.Statem
 		return
 	}
 
-	// Get session user
-	user := e.session.user
-	if user.IsAdmin() {
+	// Superusers see every namespace unless their key is restricted
+	if e.unrestricted() {
 
 		// Get namespace store
 		namespaceStore, err := e.system.Namespaces()
@@ -224,24 +287,51 @@ func (e *Executor) handleShowNamespace(w *common.ResponseWriter, stmt skl.Statem
 			return
 		}
 
-		// Stream namespaces
-		w.Write(w.Colors.LightYellow)
-		namespaces := namespaceStore.Stream()
-		for name := range namespaces {
-			w.Write([]byte(" " + name + "\r\n"))
+		// Collect namespaces
+		var names []string
+		for name := range namespaceStore.Stream() {
+			names = append(names, name)
 		}
-		w.Write(w.Colors.Reset)
-	} else {
+		writeNames(w, names)
+	} else if namespaces, ok := e.memberNamespaces(w); ok {
+		writeNames(w, namespaces)
+	}
+}
 
-		// List namespaces
-		w.Write(w.Colors.Yellow)
-		for _, name := range user.Namespaces() {
-			w.Write([]byte(" " + name + "\r\n"))
-		}
-		w.Write(w.Colors.Reset)
+// Session options only affect the current session and can be set by any user.
+func (e *Executor) handleSetOption(w *common.ResponseWriter, stmt skl.Statement) {
+	setStatement, ok := stmt.(*skl.SetOptionStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *SetOptionStatement, got %s instead", reflect.TypeOf(stmt))
+		return
 	}
 
-	w.Success(common.OK, "")
+	switch setStatement.Option() {
+	case "on_error":
+		e.session.continueOnError = setStatement.Value() == "continue"
+	case "format":
+		e.session.format = setStatement.Value()
+		w.Format = e.session.format
+	default:
+		w.Fail(common.InvalidStatementType, "unknown session option '%s'", setStatement.Option())
+		return
+	}
+
+	w.Success(common.OK, "%s set to %s", setStatement.Option(), setStatement.Value())
+}
+
+// qualifiedName resolves a possibly relative name against the session namespace.
+// Names containing a period are already qualified. It returns the namespace and the qualified name.
+func (e *Executor) qualifiedName(name string) (namespace string, qualified string, ok bool) {
+	if index := strings.LastIndex(name, "."); index > 0 {
+		return name[:index], name, true
+	}
+
+	// Relative names require a namespace to be selected
+	if e.session.namespace == "" {
+		return "", name, false
+	}
+	return e.session.namespace, e.session.namespace + "." + name, true
 }
 
 // namespaceAlreadyExists determines if a namespace already exists...
@@ -250,7 +340,8 @@ func (e *Executor) namespaceAlreadyExists(namespace string, store datamodel.Name
 	return err == nil
 }
 
-// If the namespace being created is a root namespace, only the admin account can create it
+// If the namespace being created is a root namespace, only superusers can create it.
+// The authorizer treats root namespaces as part of the system which only superusers can modify.
 func (e *Executor) handleCreateRootNamespace(w *common.ResponseWriter, stmt *skl.CreateNamespaceStatement, store datamodel.NamespaceStore) {
 
 	// Get namespace
@@ -261,29 +352,19 @@ func (e *Executor) handleCreateRootNamespace(w *common.ResponseWriter, stmt *skl
 
 	// If err == nil, the namespace already exists
 	if err == nil {
-		w.Success(common.NamespaceAlreadyExists, name)
+		w.Success(common.NamespaceAlreadyExists, "%s", name)
 		return
 	}
 
-	// Get session user
-	user := e.session.user
-	if user.IsAdmin() {
-
-		// Create new namespace
-		_, err := store.Create(name)
-
-		// If err !+ nil, namespace could not be created
-		if err != nil {
-			w.Fail(common.CreateNamespaceError, "could not create namespace '%s'", name)
-			return
-		}
+	// Create new namespace
+	_, err = store.Create(name)
 
-		// No error == success
-		w.Success(common.OK, "namespace created")
+	// If err !+ nil, namespace could not be created
+	if err != nil {
+		w.Fail(common.CreateNamespaceError, "could not create namespace '%s'", name)
 		return
 	}
 
-	// Otherwise fail creation
-	w.Fail(common.Unauthorized, "root namespaces can only be created by the admin account")
-	return
+	// No error == success
+	w.Success(common.OK, "namespace created")
 }
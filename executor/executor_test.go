@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+	"github.com/subsilent/kappa/storage"
+)
+
+// testTerminal discards the output of subscriptions
+type testTerminal struct{}
+
+func (testTerminal) GetPrompt() string           { return "" }
+func (testTerminal) ResetPrompt()                {}
+func (testTerminal) SetPrompt(p string)          {}
+func (testTerminal) Write(p []byte) (int, error) { return len(p), nil }
+
+// newSystem creates a system with a superuser named admin
+func newSystem(t *testing.T) (datamodel.System, datamodel.User) {
+	dir, err := ioutil.TempDir("", "executor.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	system, err := datamodel.NewSystem(path.Join(dir, "test.db"), storage.NewEngine(path.Join(dir, "logs"), storage.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(system.Close)
+
+	users, err := system.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := users.Create("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = admin.SetAdmin(true); err != nil {
+		t.Fatal(err)
+	}
+	return system, admin
+}
+
+// execute runs a query as a user and returns the status of the last statement and the output
+func execute(t *testing.T, system datamodel.System, user datamodel.User, query string) (common.StatusCode, string) {
+	parsed, err := skl.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+
+	var buf bytes.Buffer
+	w := &common.ResponseWriter{Writer: &buf}
+	executor := NewExecutor(NewSession("", user), testTerminal{}, system)
+	defer executor.Close()
+	executor.ExecuteQuery(w, parsed)
+	return w.Status(), buf.String()
+}
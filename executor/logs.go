@@ -0,0 +1,220 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Superusers can create logs in any namespace.
+// Other users must have the 'create.log' permission for the namespace the log is created in.
+// Relative log names are created in the session namespace.
+// Typed logs require the type to exist. Relative type names are resolved against the session namespace.
+func (e *Executor) handleCreateLog(w *common.ResponseWriter, stmt skl.Statement) {
+
+	createStatement, ok := stmt.(*skl.CreateLogStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *CreateLogStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve log name
+	namespace, name, ok := e.qualifiedName(createStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the log name '%s'", name)
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Verify namespace existence
+	_, err = namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Resolve type name
+	var typeName string
+	if createStatement.TypeName() != "" {
+		_, typeName, ok = e.qualifiedName(createStatement.TypeName())
+		if !ok {
+			w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the type name '%s'", typeName)
+			return
+		}
+
+		// Get type store
+		typeStore, err := e.system.Types()
+		if err != nil {
+			w.Fail(common.InternalServerError, "could not access type data")
+			return
+		}
+
+		// Verify type existence
+		if _, err = typeStore.Get(typeName); err == datamodel.ErrTypeDoesNotExist {
+			w.Fail(common.TypeDoesNotExist, "%s", typeName)
+			return
+		} else if err != nil {
+			w.Fail(common.InternalServerError, "could not access type data")
+			return
+		}
+	}
+
+	// Get log store
+	logStore, err := e.system.Logs()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return
+	}
+
+	// Create log
+	_, err = logStore.Create(namespace, name, typeName)
+	if err == datamodel.ErrLogAlreadyExists {
+		w.Success(common.LogAlreadyExists, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.CreateLogError, "cannot create log '%s'", name)
+		return
+	}
+
+	w.Success(common.OK, "log created")
+}
+
+// Users must have the 'insert.log' permission for the namespace of the log.
+// Each record is validated before any records are appended. The assigned offsets are returned.
+// Records inserted into typed logs are validated against the type and stored with coerced values.
+// Views maintained from the log are updated once the records are appended.
+func (e *Executor) handleInsert(w *common.ResponseWriter, stmt skl.Statement) {
+
+	insertStatement, ok := stmt.(*skl.InsertStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *InsertStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get log
+	log, ok := e.getLog(w, insertStatement.Log())
+	if !ok {
+		return
+	}
+
+	// Get log type
+	schema, ok := e.getLogType(w, log)
+	if !ok {
+		return
+	}
+
+	// Validate and encode records
+	var data [][]byte
+	for i, record := range insertStatement.Records() {
+		if err := validateRecord(record); err != nil {
+			w.Fail(common.InvalidRecord, "record %d: %s", i+1, err)
+			return
+		}
+
+		values := record.Map()
+		if schema != nil {
+			var err error
+			if values, err = schema.Validate(values); err != nil {
+				w.Fail(common.InvalidRecord, "record %d: %s", i+1, err)
+				return
+			}
+		}
+
+		encoded, err := encodeRecord(values)
+		if err != nil {
+			w.Fail(common.InvalidRecord, "record %d: %s", i+1, err)
+			return
+		}
+		data = append(data, encoded)
+	}
+
+	// Open log segments
+	records, err := log.Open()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not open log '%s'", log.Name())
+		return
+	}
+
+	// Append records
+	offsets, err := records.Append(data...)
+	if err != nil {
+		w.Fail(common.InsertError, "could not append to log '%s'", log.Name())
+		return
+	}
+
+	// Update views of the log
+	e.refreshViews(log.Name())
+
+	if len(offsets) == 1 {
+		w.Success(common.OK, "offset %d", offsets[0])
+		return
+	}
+	w.Success(common.OK, "offsets %d-%d", offsets[0], offsets[len(offsets)-1])
+}
+
+// getLog resolves a log name against the session and returns the log. Failures are written to the response.
+func (e *Executor) getLog(w *common.ResponseWriter, name string) (datamodel.Log, bool) {
+
+	// Resolve log name
+	_, name, ok := e.qualifiedName(name)
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the log name '%s'", name)
+		return nil, false
+	}
+
+	// Get log store
+	logStore, err := e.system.Logs()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return nil, false
+	}
+
+	// Verify log existence
+	log, err := logStore.Get(name)
+	if err == datamodel.ErrLogDoesNotExist {
+		w.Fail(common.LogDoesNotExist, "%s", name)
+		return nil, false
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return nil, false
+	}
+
+	return log, true
+}
+
+// getLogType returns the type of a log or nil if the log is untyped. Failures are written to the response.
+func (e *Executor) getLogType(w *common.ResponseWriter, log datamodel.Log) (datamodel.Type, bool) {
+	typeName := log.Type()
+	if typeName == "" {
+		return nil, true
+	}
+
+	// Get type store
+	typeStore, err := e.system.Types()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return nil, false
+	}
+
+	// Get type
+	schema, err := typeStore.Get(typeName)
+	if err == datamodel.ErrTypeDoesNotExist {
+		w.Fail(common.TypeDoesNotExist, "%s", typeName)
+		return nil, false
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return nil, false
+	}
+	return schema, true
+}
@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Only superusers can drop root namespaces.
+// Superusers can also drop any sub-namespace. Other users must have the 'drop.namespace'
+// permission for the parent namespace, which is verified by the authorizer.
+// Namespaces with child namespaces can only be dropped with CASCADE, which drops the children as well.
+// Namespaces are not dropped while views or logs in other namespaces depend on their logs or types.
+// The logs, views and types of dropped namespaces are removed and the namespaces are removed from every user.
+func (e *Executor) handleDropNamespace(w *common.ResponseWriter, stmt skl.Statement) {
+
+	dropStatement, ok := stmt.(*skl.DropNamespaceStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DropNamespaceStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Verify namespace existence
+	namespace := dropStatement.Namespace()
+	if _, err = namespaceStore.Get(namespace); err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Find child namespaces
+	children := namespaceStore.Children(namespace)
+	if len(children) > 0 && !dropStatement.Cascade() {
+		w.Fail(common.NamespaceHasChildren, "namespace '%s' has %d child namespaces, use CASCADE to drop them", namespace, len(children))
+		return
+	}
+
+	// Drop the deepest namespaces first so a failure never leaves a child without its parent
+	dropped := append(children, namespace)
+	sort.Sort(sort.Reverse(sort.StringSlice(dropped)))
+
+	// Refuse to leave views and logs of other namespaces without their source log or type
+	dependents, err := e.namespaceDependents(dropped)
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	} else if len(dependents) > 0 {
+		w.Error(common.NewError(common.NamespaceHasDependents,
+			"namespace '%s' is used by %s in other namespaces", namespace, strings.Join(dependents, ", ")).
+			With("dependents", dependents))
+		return
+	}
+
+	// Remove namespace contents
+	if err = e.dropNamespaceContents(dropped); err != nil {
+		w.Fail(common.DropNamespaceError, "could not drop contents of namespace '%s'", namespace)
+		return
+	}
+
+	// Get user store
+	userStore, err := e.system.Users()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return
+	}
+
+	// Collect usernames first as removing namespaces writes to the store being streamed
+	var usernames []string
+	for username := range userStore.Stream() {
+		usernames = append(usernames, username)
+	}
+
+	// Remove namespaces from users
+	for _, username := range usernames {
+		user, err := userStore.Get(username)
+		if err != nil {
+			continue
+		}
+
+		for _, name := range dropped {
+			if err = user.RemoveNamespace(name); err != nil {
+				w.Fail(common.DropNamespaceError, "could not remove namespace '%s' from user '%s'", name, username)
+				return
+			}
+		}
+	}
+
+	// Drop namespaces
+	for _, name := range dropped {
+		if err = namespaceStore.Delete(name); err != nil {
+			w.Fail(common.DropNamespaceError, "could not drop namespace '%s'", name)
+			return
+		}
+	}
+
+	w.Success(common.OK, "%d namespaces dropped", len(dropped))
+}
+
+// namespaceDependents returns the fully qualified names of the views and logs outside of the given namespaces which
+// depend on the logs or types belonging to any of them. Views depend on their source log and logs on their type.
+func (e *Executor) namespaceDependents(namespaces []string) ([]string, error) {
+
+	// Find dropped types
+	typeStore, err := e.system.Types()
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]bool)
+	for name := range typeStore.Stream() {
+		if schema, err := typeStore.Get(name); err == nil && contains(namespaces, schema.Namespace()) {
+			types[name] = true
+		}
+	}
+
+	// Find dropped logs and the logs of other namespaces using dropped types
+	logStore, err := e.system.Logs()
+	if err != nil {
+		return nil, err
+	}
+	var dependents []string
+	logs := make(map[string]bool)
+	for name := range logStore.Stream() {
+		log, err := logStore.Get(name)
+		if err != nil {
+			continue
+		} else if contains(namespaces, log.Namespace()) {
+			logs[name] = true
+		} else if types[log.Type()] {
+			dependents = append(dependents, name)
+		}
+	}
+
+	// Find views of other namespaces maintained from dropped logs
+	viewStore, err := e.system.Views()
+	if err != nil {
+		return nil, err
+	}
+	for name := range viewStore.Stream() {
+		if view, err := viewStore.Get(name); err == nil && !contains(namespaces, view.Namespace()) && logs[view.Source()] {
+			dependents = append(dependents, name)
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// dropNamespaceContents removes the views, logs and types belonging to any of the given namespaces.
+// Views are removed before logs so they are never refreshed from a dropped log.
+func (e *Executor) dropNamespaceContents(namespaces []string) error {
+
+	// Drop views
+	viewStore, err := e.system.Views()
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range viewStore.Stream() {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if view, err := viewStore.Get(name); err == nil && contains(namespaces, view.Namespace()) {
+			if err = viewStore.Delete(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drop logs
+	logStore, err := e.system.Logs()
+	if err != nil {
+		return err
+	}
+	names = nil
+	for name := range logStore.Stream() {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if log, err := logStore.Get(name); err == nil && contains(namespaces, log.Namespace()) {
+
+			// Stop streaming the log
+			e.mutex.Lock()
+			sub, exists := e.subscriptions[name]
+			delete(e.subscriptions, name)
+			e.mutex.Unlock()
+			if exists {
+				sub.cancel()
+			}
+
+			if err = logStore.Delete(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drop types
+	typeStore, err := e.system.Types()
+	if err != nil {
+		return err
+	}
+	names = nil
+	for name := range typeStore.Stream() {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if schema, err := typeStore.Get(name); err == nil && contains(namespaces, schema.Namespace()) {
+			if err = typeStore.Delete(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
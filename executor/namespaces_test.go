@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+)
+
+// Ensure namespaces are not dropped while views of other namespaces are maintained from their logs
+func TestDropNamespaceWithDependentViews(t *testing.T) {
+	system, admin := newSystem(t)
+	code, out := execute(t, system, admin, `CREATE NAMESPACE acme; CREATE NAMESPACE acme.billing; CREATE NAMESPACE reports;
+		CREATE LOG acme.billing.invoices; CREATE VIEW reports.invoices AS SELECT * FROM acme.billing.invoices`)
+	assert.Equal(t, common.OK, code, out)
+
+	// Dropping the namespace of the log or a parent namespace fails
+	for _, query := range []string{`DROP NAMESPACE acme.billing`, `DROP NAMESPACE acme CASCADE`} {
+		code, out = execute(t, system, admin, query)
+		assert.Equal(t, common.NamespaceHasDependents, code, query)
+		assert.Contains(t, out, "reports.invoices", query)
+	}
+
+	// Nothing is dropped
+	namespaces, err := system.Namespaces()
+	assert.Nil(t, err)
+	_, err = namespaces.Get("acme.billing")
+	assert.Nil(t, err)
+	logs, err := system.Logs()
+	assert.Nil(t, err)
+	_, err = logs.Get("acme.billing.invoices")
+	assert.Nil(t, err)
+
+	// Namespaces can be dropped once the view is dropped with its namespace
+	code, out = execute(t, system, admin, `DROP NAMESPACE reports; DROP NAMESPACE acme CASCADE`)
+	assert.Equal(t, common.OK, code, out)
+	views, err := system.Views()
+	assert.Nil(t, err)
+	_, err = views.Get("reports.invoices")
+	assert.Equal(t, datamodel.ErrViewDoesNotExist, err)
+}
+
+// Ensure namespaces are not dropped while logs of other namespaces use their types
+func TestDropNamespaceWithDependentLogs(t *testing.T) {
+	system, admin := newSystem(t)
+	code, out := execute(t, system, admin, `CREATE NAMESPACE acme; CREATE NAMESPACE acme.schemas; CREATE NAMESPACE shop;
+		CREATE TYPE acme.schemas.Event (id uint64 REQUIRED); CREATE LOG shop.events USING TYPE acme.schemas.Event;
+		CREATE LOG acme.events USING TYPE acme.schemas.Event`)
+	assert.Equal(t, common.OK, code, out)
+
+	var tests = []struct {
+		query      string
+		dependents string
+	}{
+		{query: `DROP NAMESPACE acme.schemas`, dependents: "acme.events, shop.events"},
+
+		// Logs of the dropped namespaces aren't dependents
+		{query: `DROP NAMESPACE acme CASCADE`, dependents: "used by shop.events in"},
+	}
+
+	for _, tt := range tests {
+		code, out = execute(t, system, admin, tt.query)
+		assert.Equal(t, common.NamespaceHasDependents, code, tt.query)
+		assert.Contains(t, out, tt.dependents, tt.query)
+	}
+
+	// Nothing is dropped
+	types, err := system.Types()
+	assert.Nil(t, err)
+	_, err = types.Get("acme.schemas.Event")
+	assert.Nil(t, err)
+
+	// Namespaces can be dropped once no other namespace uses their types
+	code, out = execute(t, system, admin, `DROP NAMESPACE shop; DROP NAMESPACE acme CASCADE`)
+	assert.Equal(t, common.OK, code, out)
+	_, err = types.Get("acme.schemas.Event")
+	assert.Equal(t, datamodel.ErrTypeDoesNotExist, err)
+}
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+	"github.com/subsilent/kappa/storage"
+)
+
+const (
+
+	// offsetField is the metadata field containing the offset of a record
+	offsetField = "_offset"
+
+	// timestampField is the metadata field containing the time a record was appended
+	timestampField = "_timestamp"
+)
+
+// validateRecord verifies a record literal can be stored in a log.
+// Field names starting with an underscore are reserved for record metadata.
+func validateRecord(record skl.RecordLiteral) error {
+	if len(record) == 0 {
+		return fmt.Errorf("records require at least one field")
+	}
+
+	for _, field := range record {
+		if strings.HasPrefix(field.Name, "_") {
+			return fmt.Errorf("field '%s' is reserved", field.Name)
+		}
+	}
+	return nil
+}
+
+// encodeRecord serializes record fields for storage in a log
+func encodeRecord(record map[string]interface{}) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+// decodeRecord deserializes a stored record. Numbers are decoded as int64, uint64 or float64 values.
+// Records of typed logs are coerced to the field types of the schema.
+func decodeRecord(data []byte, schema datamodel.Type) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	// Convert numbers
+	for name, value := range record {
+		if n, ok := value.(json.Number); ok {
+			record[name] = decodeNumber(n)
+		}
+	}
+
+	if schema != nil {
+		return schema.Validate(record)
+	}
+	return record, nil
+}
+
+// decodeNumber converts a JSON number into an int64, a uint64 if it is too large for an int64, or a float64
+func decodeNumber(n json.Number) interface{} {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
+
+// withMetadata adds the offset and timestamp of a stored record to its fields
+func withMetadata(record map[string]interface{}, rec storage.Record) map[string]interface{} {
+	record[offsetField] = rec.Offset
+	record[timestampField] = rec.Timestamp.UTC()
+	return record
+}
+
+// project returns the selected fields of a record in order. If no fields are selected, the metadata fields are
+// followed by the schema fields in declaration order or by the remaining fields sorted by name for untyped logs.
+func project(record map[string]interface{}, fields []string, schema datamodel.Type) skl.RecordLiteral {
+	if len(fields) == 0 {
+		fields = []string{offsetField, timestampField}
+		if schema != nil {
+			for _, field := range schema.Fields() {
+				fields = append(fields, field.Name)
+			}
+		} else {
+			var names []string
+			for name := range record {
+				if name != offsetField && name != timestampField {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			fields = append(fields, names...)
+		}
+	}
+
+	row := make(skl.RecordLiteral, 0, len(fields))
+	for _, name := range fields {
+		row = append(row, skl.Field{Name: name, Value: record[name]})
+	}
+	return row
+}
+
+// columns returns the columns of the rows projected from records with the selected fields. Untyped logs have a
+// column for every field, so without selected fields the columns are only known once every row has been seen. The
+// other fields of such rows are added with addColumns.
+func columns(fields []string, schema datamodel.Type) []string {
+	var names []string
+	for _, field := range project(map[string]interface{}{}, fields, schema) {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+// addColumns adds the fields found in the rows of an untyped log which aren't columns yet, sorted by name
+func addColumns(columns []string, found map[string]bool) []string {
+	for _, name := range columns {
+		delete(found, name)
+	}
+
+	var extra []string
+	for name := range found {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
+// values returns the values of the fields of a projected row in order
+func values(row skl.RecordLiteral) []interface{} {
+	values := make([]interface{}, len(row))
+	for i, field := range row {
+		values[i] = field.Value
+	}
+	return values
+}
@@ -0,0 +1,262 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Superusers can create roles in any namespace.
+// Other users must have the 'create.role' permission for the namespace.
+func (e *Executor) handleCreateRole(w *common.ResponseWriter, stmt skl.Statement) {
+
+	createStatement, ok := stmt.(*skl.CreateRoleStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *CreateRoleStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	ns, ok := e.getRoleNamespace(w, createStatement.Namespace())
+	if !ok {
+		return
+	}
+
+	// Verify the role does not exist
+	role := createStatement.Role()
+	if hasRole(ns, role) {
+		w.Success(common.RoleAlreadyExists, "%s", role)
+		return
+	}
+
+	// Create role
+	if err := ns.AddRole(role); err != nil {
+		w.Fail(common.UpdateRoleError, "cannot create role '%s'", role)
+		return
+	}
+
+	w.Success(common.OK, "role created")
+}
+
+// Superusers can grant permissions to any role.
+// Other users must have the 'update.role' permission for the namespace of the role.
+// Permissions the role already has are ignored.
+func (e *Executor) handleAddPermissions(w *common.ResponseWriter, stmt skl.Statement) {
+
+	addStatement, ok := stmt.(*skl.AddPermissionsStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *AddPermissionsStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	ns, ok := e.getRoleNamespace(w, addStatement.Namespace())
+	if !ok {
+		return
+	}
+
+	// Verify role existence
+	role := addStatement.Role()
+	if !hasRole(ns, role) {
+		w.Fail(common.RoleDoesNotExist, "%s", role)
+		return
+	}
+
+	// Determine new permissions
+	var permissions []string
+	for _, permission := range addStatement.Permissions() {
+		if !contains(ns.Permissions(role), permission) && !contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	// Grant permissions
+	if len(permissions) > 0 {
+		if err := ns.GrantPermissions(role, permissions...); err != nil {
+			w.Fail(common.UpdateRoleError, "could not add permissions to role '%s'", role)
+			return
+		}
+	}
+
+	w.Success(common.OK, "%d permissions added", len(permissions))
+}
+
+// Superusers can revoke permissions from any role.
+// Other users must have the 'update.role' permission for the namespace of the role.
+func (e *Executor) handleRemovePermissions(w *common.ResponseWriter, stmt skl.Statement) {
+
+	removeStatement, ok := stmt.(*skl.RemovePermissionsStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *RemovePermissionsStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	ns, ok := e.getRoleNamespace(w, removeStatement.Namespace())
+	if !ok {
+		return
+	}
+
+	// Verify role existence
+	role := removeStatement.Role()
+	if !hasRole(ns, role) {
+		w.Fail(common.RoleDoesNotExist, "%s", role)
+		return
+	}
+
+	// Revoke permissions
+	var removed int
+	for _, permission := range removeStatement.Permissions() {
+		if !contains(ns.Permissions(role), permission) {
+			continue
+		}
+		if err := ns.RevokePermission(role, permission); err != nil {
+			w.Fail(common.UpdateRoleError, "could not remove permission '%s' from role '%s'", permission, role)
+			return
+		}
+		removed++
+	}
+
+	w.Success(common.OK, "%d permissions removed", removed)
+}
+
+// Superusers can grant any role to a user.
+// Other users must have the 'grant.role' permission for the namespace of the role.
+// The user is registered with the namespace as well as being given the role.
+func (e *Executor) handleAddRole(w *common.ResponseWriter, stmt skl.Statement) {
+
+	addStatement, ok := stmt.(*skl.AddRoleStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *AddRoleStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	namespace := addStatement.Namespace()
+	ns, ok := e.getRoleNamespace(w, namespace)
+	if !ok {
+		return
+	}
+
+	// Verify role existence
+	role := addStatement.Role()
+	if !hasRole(ns, role) {
+		w.Fail(common.RoleDoesNotExist, "%s", role)
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, addStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Grant role
+	if !contains(user.Roles(namespace), role) {
+		if err := user.AddRole(namespace, role); err != nil {
+			w.Fail(common.UpdateRoleError, "could not add role '%s' to user '%s'", role, user.Username())
+			return
+		}
+	}
+
+	// Register user with namespace
+	if !ns.HasAccess(user.Username()) {
+		if err := ns.AddUser(user.Username()); err != nil {
+			w.Fail(common.UpdateRoleError, "could not add user '%s' to namespace '%s'", user.Username(), namespace)
+			return
+		}
+	}
+
+	w.Success(common.OK, "role added")
+}
+
+// Superusers can revoke any role from a user.
+// Other users must have the 'grant.role' permission for the namespace of the role.
+// Once a user has no roles left for the namespace, the user is unregistered from it.
+func (e *Executor) handleRemoveRole(w *common.ResponseWriter, stmt skl.Statement) {
+
+	removeStatement, ok := stmt.(*skl.RemoveRoleStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *RemoveRoleStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	namespace := removeStatement.Namespace()
+	ns, ok := e.getRoleNamespace(w, namespace)
+	if !ok {
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, removeStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Verify the user has the role
+	role := removeStatement.Role()
+	if !contains(user.Roles(namespace), role) {
+		w.Fail(common.RoleDoesNotExist, "user '%s' does not have role '%s'", user.Username(), role)
+		return
+	}
+
+	// Revoke role
+	if err := user.RemoveRole(namespace, role); err != nil {
+		w.Fail(common.UpdateRoleError, "could not remove role '%s' from user '%s'", role, user.Username())
+		return
+	}
+
+	// Unregister user once no roles remain
+	if len(user.Roles(namespace)) == 0 {
+		if err := user.RemoveNamespace(namespace); err != nil {
+			w.Fail(common.UpdateRoleError, "could not remove namespace '%s' from user '%s'", namespace, user.Username())
+			return
+		}
+		if err := ns.RemoveUser(user.Username()); err != nil {
+			w.Fail(common.UpdateRoleError, "could not remove user '%s' from namespace '%s'", user.Username(), namespace)
+			return
+		}
+	}
+
+	w.Success(common.OK, "role removed")
+}
+
+// getRoleNamespace returns the namespace of a role. Failures are written to the response.
+func (e *Executor) getRoleNamespace(w *common.ResponseWriter, namespace string) (datamodel.Namespace, bool) {
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return nil, false
+	}
+
+	// Verify namespace existence
+	ns, err := namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return nil, false
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return nil, false
+	}
+	return ns, true
+}
+
+// hasRole determines if a role exists in the namespace
+func hasRole(ns datamodel.Namespace, role string) bool {
+	return contains(ns.Roles(), role)
+}
+
+// contains determines if a list contains a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
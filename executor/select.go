@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+	"github.com/subsilent/kappa/storage"
+)
+
+// Records are scanned from the start of the log and matching rows are written as they are found. Untyped logs are
+// scanned twice if no fields are selected, first to find the columns of the matching rows.
+// Every record has the metadata fields '_offset' and '_timestamp' which can be selected and filtered on.
+func (e *Executor) handleSelect(w *common.ResponseWriter, stmt skl.Statement) {
+
+	selectStatement, ok := stmt.(*skl.SelectStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *SelectStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Select from a view if one exists with the source name
+	if view, ok := e.getView(selectStatement.Source()); ok {
+		e.selectView(w, selectStatement, view)
+		return
+	}
+
+	// Get log
+	log, ok := e.getLog(w, selectStatement.Source())
+	if !ok {
+		return
+	}
+
+	// Get log type
+	schema, ok := e.getLogType(w, log)
+	if !ok {
+		return
+	}
+
+	// Open log segments
+	records, err := log.Open()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not open log '%s'", log.Name())
+		return
+	}
+
+	// Records appended while the statement runs are left out so every scan sees the same records
+	scan := logScanner(records, schema, records.NextOffset())
+	rows, decodeErr, err := writeRows(w, selectStatement, selectStatement.Fields(), schema, scan)
+	if decodeErr != nil {
+		w.Fail(common.InternalServerError, "could not decode record in log '%s'", log.Name())
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not read log '%s'", log.Name())
+		return
+	}
+
+	w.Success(common.OK, "%d rows", rows)
+}
+
+// recordScanner calls fn with the decoded records of a log or view until fn returns false
+type recordScanner func(fn func(record map[string]interface{}) bool) (decodeErr, err error)
+
+// logScanner returns a scanner of the records of a log before the end offset. Records have their metadata fields.
+func logScanner(records storage.Log, schema datamodel.Type, end uint64) recordScanner {
+	return func(fn func(record map[string]interface{}) bool) (decodeErr, err error) {
+		err = records.Scan(records.OldestOffset(), func(rec storage.Record) bool {
+			if rec.Offset >= end {
+				return false
+			}
+
+			record, err := decodeRecord(rec.Data, schema)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			return fn(withMetadata(record, rec))
+		})
+		return
+	}
+}
+
+// writeRows writes the records matching a SELECT statement as rows while they are scanned. Without selected fields
+// rows of untyped records have a column for every field found, so their records are scanned twice.
+func writeRows(w *common.ResponseWriter, stmt *skl.SelectStatement, fields []string, schema datamodel.Type,
+	scan recordScanner) (rows uint64, decodeErr, err error) {
+
+	// Find the columns of untyped records
+	names := columns(fields, schema)
+	if schema == nil && len(fields) == 0 {
+		found := make(map[string]bool)
+		_, decodeErr, err = selectRecords(stmt, scan, func(record map[string]interface{}) {
+			for name := range record {
+				found[name] = true
+			}
+		})
+		if decodeErr != nil || err != nil {
+			return
+		}
+		names = addColumns(names, found)
+	}
+
+	// Write rows
+	w.WriteHeader(names)
+	return selectRecords(stmt, scan, func(record map[string]interface{}) {
+		w.WriteRow(names, values(project(record, names, schema)))
+	})
+}
+
+// selectRecords calls fn with the scanned records matching the condition of a SELECT statement. The first matching
+// records are skipped by the offset of the statement and the scan stops at its limit. The number of records passed
+// to fn is returned.
+func selectRecords(stmt *skl.SelectStatement, scan recordScanner, fn func(record map[string]interface{})) (
+	rows uint64, decodeErr, err error) {
+
+	condition := stmt.Condition()
+	limit, offset := stmt.Limit(), stmt.Offset()
+
+	var matched uint64
+	decodeErr, err = scan(func(record map[string]interface{}) bool {
+
+		// Filter records
+		if condition != nil && !skl.EvalBool(condition, record) {
+			return true
+		}
+
+		// Skip the first matching rows
+		matched++
+		if matched <= offset {
+			return true
+		}
+
+		fn(record)
+		rows++
+		return limit == 0 || rows < limit
+	})
+	return
+}
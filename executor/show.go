@@ -0,0 +1,341 @@
+package executor
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Lists users and whether they are superusers. With a session namespace the users registered with it are listed.
+// Otherwise superusers see every user and other users see the users of the namespaces they belong to.
+func (e *Executor) handleShowUsers(w *common.ResponseWriter, stmt skl.Statement) {
+
+	if _, ok := stmt.(*skl.ShowUsersStatement); !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowUsersStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Get user store
+	userStore, err := e.system.Users()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return
+	}
+
+	var usernames []string
+	if e.session.namespace == "" && e.unrestricted() {
+		for username := range userStore.Stream() {
+			usernames = append(usernames, username)
+		}
+	} else {
+
+		// Determine visible namespaces
+		namespaces, ok := e.visibleNamespaces(w)
+		if !ok {
+			return
+		}
+
+		// Collect users of each namespace
+		if e.session.namespace == "" {
+			usernames = append(usernames, e.session.user.Username())
+		}
+		for _, namespace := range namespaces {
+			ns, err := namespaceStore.Get(namespace)
+			if err != nil {
+				continue
+			}
+			for _, username := range ns.Users() {
+				if !contains(usernames, username) {
+					usernames = append(usernames, username)
+				}
+			}
+		}
+		sort.Strings(usernames)
+	}
+
+	// Mark superusers
+	rs := common.ResultSet{Columns: []string{"name", "superuser"}}
+	for _, username := range usernames {
+		if user, err := userStore.Get(username); err == nil {
+			rs.Rows = append(rs.Rows, []interface{}{username, user.IsAdmin()})
+		}
+	}
+	w.WriteResults(rs)
+	w.Success(common.OK, "")
+}
+
+// Lists the roles of a namespace. The namespace defaults to the session namespace.
+// Users other than superusers must belong to the namespace.
+func (e *Executor) handleShowRoles(w *common.ResponseWriter, stmt skl.Statement) {
+
+	showStatement, ok := stmt.(*skl.ShowRolesStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowRolesStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve namespace
+	namespace := showStatement.Namespace()
+	if namespace == "" {
+		if namespace = e.session.namespace; namespace == "" {
+			w.Fail(common.NoNamespaceSelected, "use a namespace or name one with ON")
+			return
+		}
+	}
+
+	// Get namespace
+	ns, ok := e.getVisibleNamespace(w, namespace)
+	if !ok {
+		return
+	}
+
+	roles := ns.Roles()
+	sort.Strings(roles)
+	writeNames(w, roles)
+}
+
+// Lists the permissions of a role. Users other than superusers must belong to the namespace of the role.
+func (e *Executor) handleShowPermissions(w *common.ResponseWriter, stmt skl.Statement) {
+
+	showStatement, ok := stmt.(*skl.ShowPermissionsStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowPermissionsStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get namespace
+	ns, ok := e.getVisibleNamespace(w, showStatement.Namespace())
+	if !ok {
+		return
+	}
+
+	// Verify role existence
+	role := showStatement.Role()
+	if !hasRole(ns, role) {
+		w.Fail(common.RoleDoesNotExist, "%s", role)
+		return
+	}
+
+	permissions := ns.Permissions(role)
+	sort.Strings(permissions)
+	writeNames(w, permissions)
+}
+
+// Lists logs. With a session namespace only the logs of that namespace are listed. Otherwise superusers see every
+// log and other users see the logs of the namespaces they belong to.
+func (e *Executor) handleShowLogs(w *common.ResponseWriter, stmt skl.Statement) {
+
+	if _, ok := stmt.(*skl.ShowLogsStatement); !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowLogsStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get log store
+	logStore, err := e.system.Logs()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access log data")
+		return
+	}
+
+	e.showNames(w, logStore.Stream(), func(name string) (string, bool) {
+		log, err := logStore.Get(name)
+		if err != nil {
+			return "", false
+		}
+		return log.Namespace(), true
+	})
+}
+
+// Lists views using the same visibility rules as SHOW LOGS.
+func (e *Executor) handleShowViews(w *common.ResponseWriter, stmt skl.Statement) {
+
+	if _, ok := stmt.(*skl.ShowViewsStatement); !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowViewsStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get view store
+	viewStore, err := e.system.Views()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access view data")
+		return
+	}
+
+	e.showNames(w, viewStore.Stream(), func(name string) (string, bool) {
+		view, err := viewStore.Get(name)
+		if err != nil {
+			return "", false
+		}
+		return view.Namespace(), true
+	})
+}
+
+// Lists types using the same visibility rules as SHOW LOGS.
+func (e *Executor) handleShowTypes(w *common.ResponseWriter, stmt skl.Statement) {
+
+	if _, ok := stmt.(*skl.ShowTypesStatement); !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowTypesStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get type store
+	typeStore, err := e.system.Types()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return
+	}
+
+	e.showNames(w, typeStore.Stream(), func(name string) (string, bool) {
+		schema, err := typeStore.Get(name)
+		if err != nil {
+			return "", false
+		}
+		return schema.Namespace(), true
+	})
+}
+
+// showNames writes the streamed names of objects in the visible namespaces. The namespace function returns the
+// namespace of an object or false if it no longer exists.
+func (e *Executor) showNames(w *common.ResponseWriter, stream chan string, namespace func(name string) (string, bool)) {
+
+	// Superusers see every object without a session namespace
+	all := e.session.namespace == "" && e.unrestricted()
+
+	var namespaces []string
+	if !all {
+		var ok bool
+		if namespaces, ok = e.visibleNamespaces(w); !ok {
+
+			// Drain the stream so the store is released
+			for range stream {
+			}
+			return
+		}
+	}
+
+	// Collect names first as looking up objects reads from the store being streamed
+	var names []string
+	for name := range stream {
+		names = append(names, name)
+	}
+
+	var visible []string
+	for _, name := range names {
+		if ns, ok := namespace(name); ok && (all || contains(namespaces, ns)) {
+			visible = append(visible, name)
+		}
+	}
+	writeNames(w, visible)
+}
+
+// visibleNamespaces returns the session namespace if one is selected, otherwise the namespaces the session user
+// belongs to. Users other than superusers must belong to the session namespace. Failures are written to the response.
+func (e *Executor) visibleNamespaces(w *common.ResponseWriter) ([]string, bool) {
+	if e.session.namespace == "" {
+		return e.memberNamespaces(w)
+	}
+
+	if _, ok := e.getVisibleNamespace(w, e.session.namespace); !ok {
+		return nil, false
+	}
+	return []string{e.session.namespace}, true
+}
+
+// getVisibleNamespace returns a namespace if the session user may see it. Superusers see every namespace and
+// other users see the namespaces they belong to and their children. Failures are written to the response.
+func (e *Executor) getVisibleNamespace(w *common.ResponseWriter, namespace string) (datamodel.Namespace, bool) {
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return nil, false
+	}
+
+	// Verify namespace existence
+	ns, err := namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return nil, false
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return nil, false
+	}
+
+	// Verify membership
+	user := e.session.user
+	if !user.IsAdmin() && !isMember(user, namespace) || !e.session.key.AllowsNamespace(namespace) {
+		w.Fail(common.Unauthorized, "cannot access namespace '%s'", namespace)
+		return nil, false
+	}
+	return ns, true
+}
+
+// unrestricted determines if the session user sees every namespace, which superusers do unless their key is
+// restricted to some of them
+func (e *Executor) unrestricted() bool {
+	return e.session.user.IsAdmin() && len(e.session.key.Namespaces) == 0
+}
+
+// memberNamespaces returns the namespaces the session user belongs to and their children, which inherit the roles of
+// the user. Superusers with a restricted key belong to the existing namespaces of the key. Namespaces not allowed by
+// the key are left out. Failures are written to the response.
+func (e *Executor) memberNamespaces(w *common.ResponseWriter) ([]string, bool) {
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return nil, false
+	}
+
+	// Determine namespaces of the user
+	user := e.session.user
+	roots := user.Namespaces()
+	if user.IsAdmin() {
+		roots = nil
+		for _, namespace := range e.session.key.Namespaces {
+			if _, err := namespaceStore.Get(namespace); err == nil {
+				roots = append(roots, namespace)
+			}
+		}
+	}
+
+	var namespaces []string
+	for _, namespace := range roots {
+		for _, name := range append([]string{namespace}, namespaceStore.Children(namespace)...) {
+			if e.session.key.AllowsNamespace(name) && !contains(namespaces, name) {
+				namespaces = append(namespaces, name)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, true
+}
+
+// isMember determines if a user belongs to a namespace or one of its parents
+func isMember(user datamodel.User, namespace string) bool {
+	namespaces := user.Namespaces()
+	for ; namespace != ""; namespace = datamodel.ParentNamespace(namespace) {
+		if contains(namespaces, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNames writes a list of names as a result set with a single "name" column
+func writeNames(w *common.ResponseWriter, names []string) {
+	w.WriteResults(list("name", names))
+	w.Success(common.OK, "")
+}
@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+	"github.com/subsilent/kappa/storage"
+)
+
+// subscription streams records appended to a log to the session terminal
+type subscription struct {
+	log       string
+	records   storage.Log
+	schema    datamodel.Type
+	condition skl.Expr
+	offset    uint64
+
+	// stop is closed to end the subscription, done is closed once the subscription has ended
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Users must have the 'subscribe.log' permission for the namespace of the log.
+// Matching records are written to the terminal in the background so the session can keep executing statements.
+// Without an offset only records appended after subscribing are streamed.
+func (e *Executor) handleSubscribe(w *common.ResponseWriter, stmt skl.Statement) {
+
+	subscribeStatement, ok := stmt.(*skl.SubscribeStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *SubscribeStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get log
+	log, ok := e.getLog(w, subscribeStatement.Log())
+	if !ok {
+		return
+	}
+
+	// Get log type
+	schema, ok := e.getLogType(w, log)
+	if !ok {
+		return
+	}
+
+	// Open log segments
+	records, err := log.Open()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not open log '%s'", log.Name())
+		return
+	}
+
+	// Start at the end of the log unless an offset was given
+	offset, ok := subscribeStatement.Offset()
+	if !ok {
+		offset = records.NextOffset()
+	}
+
+	sub := &subscription{
+		log:       log.Name(),
+		records:   records,
+		schema:    schema,
+		condition: subscribeStatement.Condition(),
+		offset:    offset,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	// Only one subscription per log
+	e.mutex.Lock()
+	if _, exists := e.subscriptions[sub.log]; exists {
+		e.mutex.Unlock()
+		w.Success(common.AlreadySubscribed, "%s", sub.log)
+		return
+	}
+	e.subscriptions[sub.log] = sub
+	e.mutex.Unlock()
+
+	go e.stream(sub, w.Colors, w.Format)
+	w.Success(common.OK, "subscribed to '%s' at offset %d", sub.log, offset)
+}
+
+// Stops streaming a log. Without a log name every subscription is stopped.
+func (e *Executor) handleUnsubscribe(w *common.ResponseWriter, stmt skl.Statement) {
+
+	unsubscribeStatement, ok := stmt.(*skl.UnsubscribeStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *UnsubscribeStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Stop all subscriptions
+	if unsubscribeStatement.Log() == "" {
+		w.Success(common.OK, "%d subscriptions stopped", e.StopSubscriptions())
+		return
+	}
+
+	// Resolve log name
+	_, name, ok := e.qualifiedName(unsubscribeStatement.Log())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the log name '%s'", name)
+		return
+	}
+
+	e.mutex.Lock()
+	sub, exists := e.subscriptions[name]
+	delete(e.subscriptions, name)
+	e.mutex.Unlock()
+
+	if !exists {
+		w.Fail(common.NotSubscribed, "%s", name)
+		return
+	}
+	sub.cancel()
+
+	w.Success(common.OK, "unsubscribed from '%s'", name)
+}
+
+// StopSubscriptions ends every active subscription and returns how many were stopped.
+// No more records are written once it returns.
+func (e *Executor) StopSubscriptions() int {
+	e.mutex.Lock()
+	subscriptions := e.subscriptions
+	e.subscriptions = make(map[string]*subscription)
+	e.mutex.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.cancel()
+	}
+	return len(subscriptions)
+}
+
+// Close releases the resources held by the executor
+func (e *Executor) Close() {
+	e.StopSubscriptions()
+}
+
+// cancel stops the subscription and waits for it to end
+func (s *subscription) cancel() {
+	close(s.stop)
+	<-s.done
+}
+
+// stream writes matching records to the terminal until the subscription is stopped or the log is closed
+func (e *Executor) stream(sub *subscription, colors common.ColorCodes, format string) {
+	defer close(sub.done)
+	w := &common.ResponseWriter{Colors: colors, Writer: e.terminal, Format: format}
+
+	next := sub.offset
+	for {
+
+		// Get the notification channel before scanning so appends during the scan wake the next iteration
+		notify := sub.records.Notify()
+
+		var decodeErr error
+		err := sub.records.Scan(next, func(rec storage.Record) bool {
+			select {
+			case <-sub.stop:
+				return false
+			default:
+			}
+
+			record, err := decodeRecord(rec.Data, sub.schema)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			next = rec.Offset + 1
+
+			// Filter records
+			record = withMetadata(record, rec)
+			if sub.condition != nil && !skl.EvalBool(sub.condition, record) {
+				return true
+			}
+
+			// Write the row
+			row := project(record, nil, sub.schema)
+			columns, values := make([]string, len(row)), make([]interface{}, len(row))
+			for i, field := range row {
+				columns[i], values[i] = field.Name, field.Value
+			}
+			w.WriteRow(columns, values)
+			return true
+		})
+
+		if decodeErr != nil {
+			e.endSubscription(sub)
+			w.Fail(common.InternalServerError, "could not decode record in log '%s', subscription ended", sub.log)
+			return
+		} else if err != nil {
+			e.endSubscription(sub)
+			w.Fail(common.InternalServerError, "could not read log '%s', subscription ended", sub.log)
+			return
+		}
+
+		// Wait for more records
+		select {
+		case <-notify:
+		case <-sub.stop:
+			return
+		}
+	}
+}
+
+// endSubscription removes a subscription which ended on its own
+func (e *Executor) endSubscription(sub *subscription) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.subscriptions[sub.log] == sub {
+		delete(e.subscriptions, sub.log)
+	}
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Superusers can create types in any namespace.
+// Other users must have the 'create.type' permission for the namespace the type is created in.
+// Relative type names are created in the session namespace.
+func (e *Executor) handleCreateType(w *common.ResponseWriter, stmt skl.Statement) {
+
+	createStatement, ok := stmt.(*skl.CreateTypeStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *CreateTypeStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve type name
+	namespace, name, ok := e.qualifiedName(createStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the type name '%s'", name)
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Verify namespace existence
+	_, err = namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Get type store
+	typeStore, err := e.system.Types()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access type data")
+		return
+	}
+
+	// Convert field definitions
+	var fields []datamodel.Field
+	for _, field := range createStatement.Fields() {
+		fields = append(fields, datamodel.Field{
+			Name:     field.Name,
+			Type:     datamodel.FieldType(field.Type),
+			Required: field.Required,
+		})
+	}
+
+	// Create type
+	_, err = typeStore.Create(namespace, name, fields)
+	if err == datamodel.ErrTypeAlreadyExists {
+		w.Success(common.TypeAlreadyExists, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.CreateTypeError, "cannot create type '%s'", name)
+		return
+	}
+
+	w.Success(common.OK, "type created")
+}
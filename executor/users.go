@@ -0,0 +1,390 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+)
+
+// Only superusers can create users.
+func (e *Executor) handleCreateUser(w *common.ResponseWriter, stmt skl.Statement) {
+
+	createStatement, ok := stmt.(*skl.CreateUserStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *CreateUserStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user store
+	userStore, err := e.system.Users()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return
+	}
+
+	// Verify the user does not exist
+	username := createStatement.Username()
+	if _, err = userStore.Get(username); err == nil {
+		w.Success(common.UserAlreadyExists, "%s", username)
+		return
+	}
+
+	// Create user
+	if _, err = userStore.Create(username); err != nil {
+		w.Fail(common.CreateUserError, "cannot create user '%s'", username)
+		return
+	}
+
+	w.Success(common.OK, "user created")
+}
+
+// Only superusers can drop users. The last superuser can't be dropped.
+// The user is unregistered from every namespace it has roles in.
+func (e *Executor) handleDropUser(w *common.ResponseWriter, stmt skl.Statement) {
+
+	dropStatement, ok := stmt.(*skl.DropUserStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DropUserStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, dropStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Get user store
+	userStore, err := e.system.Users()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return
+	}
+
+	// Drop user, which keeps the last superuser. The namespaces are read first as they are stored with the user.
+	namespaces := user.Namespaces()
+	if err = userStore.Delete(user.Username()); err == datamodel.ErrLastSuperuser {
+		w.Error(err)
+		return
+	} else if err != nil {
+		w.Fail(common.DropUserError, "cannot drop user '%s'", user.Username())
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Unregister user from namespaces
+	for _, namespace := range namespaces {
+		ns, err := namespaceStore.Get(namespace)
+		if err != nil {
+			continue
+		}
+		if err = ns.RemoveUser(user.Username()); err != nil {
+			w.Fail(common.DropUserError, "could not remove user '%s' from namespace '%s'", user.Username(), namespace)
+			return
+		}
+	}
+
+	w.Success(common.OK, "user dropped")
+}
+
+// Only superusers can set passwords.
+func (e *Executor) handleSetPassword(w *common.ResponseWriter, stmt skl.Statement) {
+
+	setStatement, ok := stmt.(*skl.SetPasswordStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *SetPasswordStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, setStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Update password
+	if err := user.UpdatePassword(setStatement.Password()); err != nil {
+		w.Fail(common.UpdateUserError, "could not set password for user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "password updated")
+}
+
+// Only superusers can add keys to users. Keys are PEM encoded X.509 certificates, authorized_keys lines or OpenSSH
+// public keys. The fingerprint of the key is returned.
+func (e *Executor) handleAddKey(w *common.ResponseWriter, stmt skl.Statement) {
+
+	addStatement, ok := stmt.(*skl.AddKeyStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *AddKeyStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, addStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Determine key attributes
+	options := addStatement.Options()
+	attributes := datamodel.KeyAttributes{
+		Comment:    options.Comment,
+		Expires:    options.Expires,
+		Namespaces: options.Namespaces,
+		ReadOnly:   options.ReadOnly,
+	}
+	if options.ValidFor > 0 {
+		attributes.Expires = time.Now().Add(options.ValidFor)
+	}
+
+	// Add key
+	fingerprint, err := user.KeyRing().AddPublicKeyWithAttributes([]byte(addStatement.Key()), attributes)
+	if e, ok := err.(*common.Error); ok && e.Code == common.InvalidPublicKey {
+		w.Error(err)
+		return
+	} else if err != nil {
+		w.Fail(common.UpdateUserError, "could not add key to user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "key %s added", fingerprint)
+}
+
+// Only superusers can remove keys from users.
+func (e *Executor) handleRemoveKey(w *common.ResponseWriter, stmt skl.Statement) {
+
+	removeStatement, ok := stmt.(*skl.RemoveKeyStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *RemoveKeyStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, removeStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Verify the key exists
+	keyRing := user.KeyRing()
+	fingerprint := removeStatement.Fingerprint()
+	var exists bool
+	for _, key := range keyRing.ListPublicKeys() {
+		if key.Fingerprint() == fingerprint {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		w.Fail(common.KeyDoesNotExist, "%s", fingerprint)
+		return
+	}
+
+	// Remove key
+	if err := keyRing.RemovePublicKey(fingerprint); err != nil {
+		w.Fail(common.UpdateUserError, "could not remove key from user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "key removed")
+}
+
+// Only superusers can list the keys of users.
+func (e *Executor) handleShowKeys(w *common.ResponseWriter, stmt skl.Statement) {
+
+	showStatement, ok := stmt.(*skl.ShowKeysStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *ShowKeysStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, showStatement.Username())
+	if !ok {
+		return
+	}
+
+	// List keys and their attributes
+	rs := common.ResultSet{
+		Columns: []string{"fingerprint", "comment", "created", "expires", "last_used", "namespaces", "read_only",
+			"command"},
+	}
+	for _, key := range user.KeyRing().ListPublicKeys() {
+		attributes := key.Attributes()
+		rs.Rows = append(rs.Rows, []interface{}{
+			key.Fingerprint(),
+			attributes.Comment,
+			optionalTime(attributes.Created),
+			optionalTime(attributes.Expires),
+			optionalTime(attributes.LastUsed),
+			strings.Join(attributes.Namespaces, ", "),
+			attributes.ReadOnly,
+			optionalString(attributes.Command),
+		})
+	}
+	w.WriteResults(rs)
+	w.Success(common.OK, "")
+}
+
+// optionalTime returns nil for the zero time so unset times are written as missing values
+func optionalTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// optionalString returns nil for empty strings so they are shown as missing values
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// Only superusers can add a TOTP second factor to users. The secret is returned once so it can be added to an
+// authenticator app and replaces any previous secret.
+func (e *Executor) handleAddTOTP(w *common.ResponseWriter, stmt skl.Statement) {
+
+	addStatement, ok := stmt.(*skl.AddTOTPStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *AddTOTPStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, addStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Generate secret
+	secret, err := datamodel.GenerateTOTPSecret()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not generate secret")
+		return
+	}
+
+	// Add second factor
+	if err := user.SetTOTPSecret(secret); err != nil {
+		w.Fail(common.UpdateUserError, "could not add second factor to user '%s'", user.Username())
+		return
+	}
+
+	w.WriteResults(common.ResultSet{
+		Columns: []string{"secret", "uri"},
+		Rows: [][]interface{}{
+			{datamodel.EncodeTOTPSecret(secret), datamodel.TOTPURI(secret, "kappa", user.Username())},
+		},
+	})
+	w.Success(common.OK, "second factor added")
+}
+
+// Only superusers can remove the TOTP second factor of users.
+func (e *Executor) handleRemoveTOTP(w *common.ResponseWriter, stmt skl.Statement) {
+
+	removeStatement, ok := stmt.(*skl.RemoveTOTPStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *RemoveTOTPStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, removeStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Remove second factor
+	if err := user.SetTOTPSecret(nil); err != nil {
+		w.Fail(common.UpdateUserError, "could not remove second factor from user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "second factor removed")
+}
+
+// Only superusers can promote users to superusers.
+func (e *Executor) handlePromoteUser(w *common.ResponseWriter, stmt skl.Statement) {
+
+	promoteStatement, ok := stmt.(*skl.PromoteUserStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *PromoteUserStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, promoteStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Promote user
+	if err := user.SetAdmin(true); err != nil {
+		w.Fail(common.UpdateUserError, "could not promote user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "user promoted")
+}
+
+// Only superusers can demote superusers, including themselves. The last superuser can't be demoted.
+func (e *Executor) handleDemoteUser(w *common.ResponseWriter, stmt skl.Statement) {
+
+	demoteStatement, ok := stmt.(*skl.DemoteUserStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *DemoteUserStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Get user
+	user, ok := e.getUser(w, demoteStatement.Username())
+	if !ok {
+		return
+	}
+
+	// Demote user
+	if err := user.SetAdmin(false); err == datamodel.ErrLastSuperuser {
+		w.Error(err)
+		return
+	} else if err != nil {
+		w.Fail(common.UpdateUserError, "could not demote user '%s'", user.Username())
+		return
+	}
+
+	w.Success(common.OK, "user demoted")
+}
+
+// getUser returns a user by name. Failures are written to the response.
+func (e *Executor) getUser(w *common.ResponseWriter, username string) (datamodel.User, bool) {
+
+	// Get user store
+	userStore, err := e.system.Users()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return nil, false
+	}
+
+	// Verify user existence
+	user, err := userStore.Get(username)
+	if err == datamodel.ErrUserDoesNotExist {
+		w.Fail(common.UserDoesNotExist, "%s", username)
+		return nil, false
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access user data")
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+)
+
+// Ensure the last superuser is kept untouched when dropping it fails
+func TestDropLastSuperuser(t *testing.T) {
+	system, admin := newSystem(t)
+	code, out := execute(t, system, admin, `CREATE NAMESPACE acme; CREATE ROLE analyst ON acme; ADD ROLE analyst TO USER admin ON acme`)
+	assert.Equal(t, common.OK, code, out)
+
+	code, out = execute(t, system, admin, `DROP USER admin`)
+	assert.Equal(t, common.LastSuperuser, code, out)
+	assert.True(t, admin.IsAdmin())
+	namespaces, err := system.Namespaces()
+	assert.Nil(t, err)
+	acme, err := namespaces.Get("acme")
+	assert.Nil(t, err)
+	assert.True(t, acme.HasAccess("admin"))
+
+	// Superusers can be dropped while another one exists
+	users, err := system.Users()
+	assert.Nil(t, err)
+	root, err := users.Create("root")
+	assert.Nil(t, err)
+	assert.Nil(t, root.SetAdmin(true))
+
+	code, out = execute(t, system, root, `DROP USER admin`)
+	assert.Equal(t, common.OK, code, out)
+	_, err = users.Get("admin")
+	assert.Equal(t, datamodel.ErrUserDoesNotExist, err)
+	assert.False(t, acme.HasAccess("admin"))
+}
@@ -0,0 +1,347 @@
+package executor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/skl"
+	"github.com/subsilent/kappa/storage"
+)
+
+// refreshBatchSize is the maximum number of source records applied to a view in a single transaction
+const refreshBatchSize = 1000
+
+// Superusers can create views in any namespace.
+// Other users must have the 'create.view' permission for the namespace the view is created in and the
+// 'select.log' permission for the namespace of the source log. Relative names are resolved against the session
+// namespace. The view is materialized from the start of the log before the statement completes.
+func (e *Executor) handleCreateView(w *common.ResponseWriter, stmt skl.Statement) {
+
+	createStatement, ok := stmt.(*skl.CreateViewStatement)
+	if !ok {
+		w.Fail(common.InvalidStatementType, "expected *CreateViewStatement, got %s instead", reflect.TypeOf(stmt))
+		return
+	}
+
+	// Resolve view name
+	namespace, name, ok := e.qualifiedName(createStatement.Name())
+	if !ok {
+		w.Fail(common.NoNamespaceSelected, "use a namespace or qualify the view name '%s'", name)
+		return
+	}
+
+	// Get namespace store
+	namespaceStore, err := e.system.Namespaces()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Verify namespace existence
+	_, err = namespaceStore.Get(namespace)
+	if err == datamodel.ErrNamespaceDoesNotExist {
+		w.Fail(common.NamespaceDoesNotExist, "%s", namespace)
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not access namespace data")
+		return
+	}
+
+	// Verify the source can be selected from
+	query := createStatement.Query()
+	if err := e.authorize(query); err != nil {
+		w.Error(err)
+		return
+	}
+
+	// Get source log
+	log, ok := e.getLog(w, query.Source())
+	if !ok {
+		return
+	}
+
+	// Get log type
+	schema, ok := e.getLogType(w, log)
+	if !ok {
+		return
+	}
+
+	// Verify the fields of typed logs
+	if schema != nil {
+		fields := append([]string{createStatement.ClusteredBy()}, query.Fields()...)
+		for _, field := range fields {
+			if field != "" && field != offsetField && field != timestampField && !declares(schema, field) {
+				w.Fail(common.CreateViewError, "type '%s' has no field '%s'", schema.Name(), field)
+				return
+			}
+		}
+	}
+
+	// Get view store
+	viewStore, err := e.system.Views()
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not access view data")
+		return
+	}
+
+	// Create view. The source is stored qualified so the view doesn't depend on the session namespace.
+	view, err := viewStore.Create(namespace, name, log.Name(), query.String(), createStatement.ClusteredBy())
+	if err == datamodel.ErrViewAlreadyExists {
+		w.Success(common.ViewAlreadyExists, "%s", name)
+		return
+	} else if err != nil {
+		w.Fail(common.CreateViewError, "cannot create view '%s'", name)
+		return
+	}
+
+	// Materialize existing records
+	if err = e.refreshView(view); err != nil {
+		w.Fail(common.InternalServerError, "could not materialize view '%s'", name)
+		return
+	}
+
+	w.Success(common.OK, "view created")
+}
+
+// selectView writes the rows of a view matching a SELECT statement. The view is brought up to date with its
+// source log first. Users must have the 'select.log' permission for the namespace of the view.
+func (e *Executor) selectView(w *common.ResponseWriter, stmt *skl.SelectStatement, view datamodel.View) {
+
+	// Bring the view up to date
+	if err := e.refreshView(view); err != nil {
+		w.Fail(common.InternalServerError, "could not refresh view '%s'", view.Name())
+		return
+	}
+
+	// Determine the default columns
+	definition, schema, err := e.viewDefinition(view)
+	if err != nil {
+		w.Fail(common.InternalServerError, "could not read definition of view '%s'", view.Name())
+		return
+	}
+	fields := stmt.Fields()
+	if len(fields) == 0 {
+		fields = definition.Fields()
+	}
+
+	// Scan rows
+	rows, decodeErr, err := writeRows(w, stmt, fields, schema, viewScanner(view))
+	if decodeErr != nil {
+		w.Fail(common.InternalServerError, "could not decode row in view '%s'", view.Name())
+		return
+	} else if err != nil {
+		w.Fail(common.InternalServerError, "could not read view '%s'", view.Name())
+		return
+	}
+
+	w.Success(common.OK, "%d rows", rows)
+}
+
+// viewScanner returns a scanner of the rows of a view
+func viewScanner(view datamodel.View) recordScanner {
+	return func(fn func(record map[string]interface{}) bool) (decodeErr, err error) {
+		err = view.Rows(func(row datamodel.ViewRow) bool {
+			record, err := decodeRecord(row.Data, nil)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			return fn(record)
+		})
+		return
+	}
+}
+
+// getView resolves a name against the session and returns the view if it exists
+func (e *Executor) getView(name string) (datamodel.View, bool) {
+	_, name, ok := e.qualifiedName(name)
+	if !ok {
+		return nil, false
+	}
+
+	viewStore, err := e.system.Views()
+	if err != nil {
+		return nil, false
+	}
+
+	view, err := viewStore.Get(name)
+	return view, err == nil
+}
+
+// refreshViews brings every view maintained from a log up to date. Errors are ignored as views are refreshed
+// again before they are read.
+func (e *Executor) refreshViews(log string) {
+	viewStore, err := e.system.Views()
+	if err != nil {
+		return
+	}
+
+	// Collect names first as refreshing a view writes to the store being streamed
+	var names []string
+	for name := range viewStore.Stream() {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		view, err := viewStore.Get(name)
+		if err != nil || view.Source() != log {
+			continue
+		}
+		e.refreshView(view)
+	}
+}
+
+// refreshView applies the source records after the checkpoint of a view in batches. Records matching the view
+// condition are projected and stored under their offset or, for clustered views, under the value of the cluster
+// field so the latest record for each value replaces the previous row. Records without a cluster value are skipped.
+func (e *Executor) refreshView(view datamodel.View) error {
+	definition, schema, err := e.viewDefinition(view)
+	if err != nil {
+		return err
+	}
+
+	// Get source log
+	logStore, err := e.system.Logs()
+	if err != nil {
+		return err
+	}
+	log, err := logStore.Get(view.Source())
+	if err != nil {
+		return err
+	}
+
+	// Open log segments
+	records, err := log.Open()
+	if err != nil {
+		return err
+	}
+
+	condition, cluster := definition.Condition(), view.ClusteredBy()
+	for {
+		checkpoint := view.Checkpoint()
+		from := checkpoint
+		if oldest := records.OldestOffset(); from < oldest {
+			from = oldest
+		}
+		if from >= records.NextOffset() {
+			return nil
+		}
+
+		// Compute a batch of rows
+		var rows []datamodel.ViewRow
+		var scanned int
+		next := from
+		err = records.Scan(from, func(rec storage.Record) bool {
+			record, e := decodeRecord(rec.Data, schema)
+			if e != nil {
+				err = e
+				return false
+			}
+			record = withMetadata(record, rec)
+			next, scanned = rec.Offset+1, scanned+1
+
+			row, ok, e := viewRow(record, rec.Offset, definition.Fields(), schema, condition, cluster)
+			if e != nil {
+				err = e
+				return false
+			} else if ok {
+				rows = append(rows, row)
+			}
+			return scanned < refreshBatchSize
+		})
+		if err != nil {
+			return err
+		} else if next == from {
+			return nil
+		}
+
+		// Apply batch. A stale checkpoint means the view was refreshed concurrently.
+		if err = view.Apply(rows, checkpoint, next); err == datamodel.ErrStaleCheckpoint {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// viewRow converts a source record into a view row. It returns false if the record does not belong in the view.
+func viewRow(record map[string]interface{}, offset uint64, fields []string, schema datamodel.Type, condition skl.Expr, cluster string) (datamodel.ViewRow, bool, error) {
+
+	// Filter records
+	if condition != nil && !skl.EvalBool(condition, record) {
+		return datamodel.ViewRow{}, false, nil
+	}
+
+	// Determine row key
+	var key []byte
+	if cluster == "" {
+		key = make([]byte, 8)
+		binary.BigEndian.PutUint64(key, offset)
+	} else {
+		value := record[cluster]
+		if value == nil {
+			return datamodel.ViewRow{}, false, nil
+		}
+
+		var err error
+		if key, err = json.Marshal(value); err != nil {
+			return datamodel.ViewRow{}, false, err
+		}
+	}
+
+	data, err := encodeRecord(project(record, fields, schema).Map())
+	if err != nil {
+		return datamodel.ViewRow{}, false, err
+	}
+	return datamodel.ViewRow{Key: key, Data: data}, true, nil
+}
+
+// viewDefinition parses the stored query of a view and returns it with the type of the source log.
+// Only the fields and condition of the query are used as the source is stored with the view.
+// The type is nil if the source log is untyped.
+func (e *Executor) viewDefinition(view datamodel.View) (*skl.SelectStatement, datamodel.Type, error) {
+	stmt, err := skl.NewParser(strings.NewReader(view.Query())).ParseStatement()
+	if err != nil {
+		return nil, nil, err
+	}
+	definition, ok := stmt.(*skl.SelectStatement)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected *SelectStatement, got %s instead", reflect.TypeOf(stmt))
+	}
+
+	// Get source log
+	logStore, err := e.system.Logs()
+	if err != nil {
+		return nil, nil, err
+	}
+	log, err := logStore.Get(view.Source())
+	if err != nil || log.Type() == "" {
+		return definition, nil, err
+	}
+
+	// Get log type
+	typeStore, err := e.system.Types()
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err := typeStore.Get(log.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+	return definition, schema, nil
+}
+
+// declares determines if a type has a field with the given name
+func declares(schema datamodel.Type, name string) bool {
+	for _, field := range schema.Fields() {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
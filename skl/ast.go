@@ -2,17 +2,56 @@ package skl
 
 import (
 	"bytes"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/eliquious/lexer"
 )
 
 // NodeType identifies various AST nodes
 type NodeType int
 
 const (
-	UseNamespaceType    NodeType = iota
-	CreateNamespaceType NodeType = iota
-	DropNamespaceType   NodeType = iota
-	ShowNamespaceType   NodeType = iota
+	UseNamespaceType      NodeType = iota
+	CreateNamespaceType   NodeType = iota
+	DropNamespaceType     NodeType = iota
+	ShowNamespaceType     NodeType = iota
+	CreateLogType         NodeType = iota
+	InsertType            NodeType = iota
+	CreateTypeType        NodeType = iota
+	SelectType            NodeType = iota
+	ExpressionType        NodeType = iota
+	SubscribeType         NodeType = iota
+	UnsubscribeType       NodeType = iota
+	CreateViewType        NodeType = iota
+	CreateUserType        NodeType = iota
+	DropUserType          NodeType = iota
+	SetPasswordType       NodeType = iota
+	AddKeyType            NodeType = iota
+	RemoveKeyType         NodeType = iota
+	CreateRoleType        NodeType = iota
+	AddPermissionsType    NodeType = iota
+	RemovePermissionsType NodeType = iota
+	AddRoleType           NodeType = iota
+	RemoveRoleType        NodeType = iota
+	ShowUsersType         NodeType = iota
+	ShowRolesType         NodeType = iota
+	ShowPermissionsType   NodeType = iota
+	ShowLogsType          NodeType = iota
+	ShowViewsType         NodeType = iota
+	ShowTypesType         NodeType = iota
+	DescribeNamespaceType NodeType = iota
+	DescribeTypeType      NodeType = iota
+	DescribeLogType       NodeType = iota
+	DescribeViewType      NodeType = iota
+	SetOptionType         NodeType = iota
+	PromoteUserType       NodeType = iota
+	DemoteUserType        NodeType = iota
+	ShowKeysType          NodeType = iota
+	AddTOTPType           NodeType = iota
+	RemoveTOTPType        NodeType = iota
 )
 
 // Node is an interface for AST nodes
@@ -24,6 +63,18 @@ type Node interface {
 // ExprType identifies various expressions
 type ExprType int
 
+const (
+	BinaryExprType       ExprType = iota
+	ParenExprType        ExprType = iota
+	VarRefType           ExprType = iota
+	StringLiteralType    ExprType = iota
+	NumberLiteralType    ExprType = iota
+	BooleanLiteralType   ExprType = iota
+	RegexLiteralType     ExprType = iota
+	UnaryExprType        ExprType = iota
+	TimestampLiteralType ExprType = iota
+)
+
 // Expr represents AST expressions
 type Expr interface {
 	Node
@@ -36,6 +87,26 @@ type Statement interface {
 	RequiredPermissions() string
 }
 
+// ParsedStatement is a statement along with the position it starts at in a query
+type ParsedStatement struct {
+	Statement
+	Pos lexer.Pos
+}
+
+// Query represents a list of statements separated by semicolons
+type Query struct {
+	Statements []ParsedStatement
+}
+
+// String returns a string representation
+func (q Query) String() string {
+	var stmts []string
+	for _, stmt := range q.Statements {
+		stmts = append(stmts, stmt.String())
+	}
+	return strings.Join(stmts, ";\n")
+}
+
 // UseStatement represents the USE statement
 type UseStatement struct {
 	name string
@@ -58,7 +129,7 @@ func (s UseStatement) String() string {
 func (s UseStatement) NodeType() NodeType { return UseNamespaceType }
 
 // RequiredPermissions returns the required permissions in order to use this command
-func (s UseStatement) RequiredPermissions() string { return "" }
+func (s UseStatement) RequiredPermissions() string { return "use.namespace" }
 
 // CreateNamespaceStatement represents the CREATE NAMESPACE statement
 type CreateNamespaceStatement struct {
@@ -91,7 +162,8 @@ func (s CreateNamespaceStatement) RequiredPermissions() string { return "create.
 
 // DropNamespaceStatement represents the DROP NAMESPACE statement
 type DropNamespaceStatement struct {
-	name string
+	name    string
+	cascade bool
 }
 
 // Namespace returns the namespace being requested
@@ -104,11 +176,19 @@ func (s DropNamespaceStatement) IsRootNamespace() bool {
 	return !strings.Contains(s.name, ".")
 }
 
+// Cascade determines if child namespaces should be dropped as well
+func (s DropNamespaceStatement) Cascade() bool {
+	return s.cascade
+}
+
 // String returns a string representation
 func (s DropNamespaceStatement) String() string {
 	var buf bytes.Buffer
 	buf.WriteString("DROP NAMESPACE ")
 	buf.WriteString(s.name)
+	if s.cascade {
+		buf.WriteString(" CASCADE")
+	}
 	return buf.String()
 }
 
@@ -134,3 +214,1192 @@ func (s ShowNamespacesStatement) NodeType() NodeType { return ShowNamespaceType
 
 // RequiredPermissions returns the required permissions in order to use this command
 func (s ShowNamespacesStatement) RequiredPermissions() string { return "show.namespaces" }
+
+// ShowUsersStatement represents the SHOW USERS statement
+type ShowUsersStatement struct {
+}
+
+// String returns a string representation
+func (s ShowUsersStatement) String() string {
+	return "SHOW USERS"
+}
+
+// NodeType returns an NodeType id
+func (s ShowUsersStatement) NodeType() NodeType { return ShowUsersType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowUsersStatement) RequiredPermissions() string { return "show.users" }
+
+// ShowRolesStatement represents the SHOW ROLES statement
+type ShowRolesStatement struct {
+	namespace string
+}
+
+// Namespace returns the namespace whose roles are listed. If empty, the session namespace is used.
+func (s ShowRolesStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s ShowRolesStatement) String() string {
+	if s.namespace == "" {
+		return "SHOW ROLES"
+	}
+	return "SHOW ROLES ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s ShowRolesStatement) NodeType() NodeType { return ShowRolesType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowRolesStatement) RequiredPermissions() string { return "show.roles" }
+
+// ShowPermissionsStatement represents the SHOW PERMISSIONS statement
+type ShowPermissionsStatement struct {
+	role      string
+	namespace string
+}
+
+// Role returns the role whose permissions are listed
+func (s ShowPermissionsStatement) Role() string {
+	return s.role
+}
+
+// Namespace returns the namespace of the role
+func (s ShowPermissionsStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s ShowPermissionsStatement) String() string {
+	return "SHOW PERMISSIONS FOR ROLE " + QuoteIdent(s.role) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s ShowPermissionsStatement) NodeType() NodeType { return ShowPermissionsType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowPermissionsStatement) RequiredPermissions() string { return "show.roles" }
+
+// ShowLogsStatement represents the SHOW LOGS statement
+type ShowLogsStatement struct {
+}
+
+// String returns a string representation
+func (s ShowLogsStatement) String() string {
+	return "SHOW LOGS"
+}
+
+// NodeType returns an NodeType id
+func (s ShowLogsStatement) NodeType() NodeType { return ShowLogsType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowLogsStatement) RequiredPermissions() string { return "show.logs" }
+
+// ShowViewsStatement represents the SHOW VIEWS statement
+type ShowViewsStatement struct {
+}
+
+// String returns a string representation
+func (s ShowViewsStatement) String() string {
+	return "SHOW VIEWS"
+}
+
+// NodeType returns an NodeType id
+func (s ShowViewsStatement) NodeType() NodeType { return ShowViewsType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowViewsStatement) RequiredPermissions() string { return "show.views" }
+
+// ShowTypesStatement represents the SHOW TYPES statement
+type ShowTypesStatement struct {
+}
+
+// String returns a string representation
+func (s ShowTypesStatement) String() string {
+	return "SHOW TYPES"
+}
+
+// NodeType returns an NodeType id
+func (s ShowTypesStatement) NodeType() NodeType { return ShowTypesType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowTypesStatement) RequiredPermissions() string { return "show.types" }
+
+// DescribeNamespaceStatement represents the DESCRIBE NAMESPACE statement
+type DescribeNamespaceStatement struct {
+	name string
+}
+
+// Namespace returns the namespace to be described
+func (s DescribeNamespaceStatement) Namespace() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DescribeNamespaceStatement) String() string {
+	return "DESCRIBE NAMESPACE " + s.name
+}
+
+// NodeType returns an NodeType id
+func (s DescribeNamespaceStatement) NodeType() NodeType { return DescribeNamespaceType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DescribeNamespaceStatement) RequiredPermissions() string { return "describe.namespace" }
+
+// DescribeTypeStatement represents the DESCRIBE TYPE statement
+type DescribeTypeStatement struct {
+	name string
+}
+
+// Name returns the name of the type to be described. The name may be relative to the session namespace.
+func (s DescribeTypeStatement) Name() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DescribeTypeStatement) String() string {
+	return "DESCRIBE TYPE " + s.name
+}
+
+// NodeType returns an NodeType id
+func (s DescribeTypeStatement) NodeType() NodeType { return DescribeTypeType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DescribeTypeStatement) RequiredPermissions() string { return "describe.type" }
+
+// DescribeLogStatement represents the DESCRIBE LOG statement
+type DescribeLogStatement struct {
+	name string
+}
+
+// Name returns the name of the log to be described. The name may be relative to the session namespace.
+func (s DescribeLogStatement) Name() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DescribeLogStatement) String() string {
+	return "DESCRIBE LOG " + s.name
+}
+
+// NodeType returns an NodeType id
+func (s DescribeLogStatement) NodeType() NodeType { return DescribeLogType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DescribeLogStatement) RequiredPermissions() string { return "describe.log" }
+
+// DescribeViewStatement represents the DESCRIBE VIEW statement
+type DescribeViewStatement struct {
+	name string
+}
+
+// Name returns the name of the view to be described. The name may be relative to the session namespace.
+func (s DescribeViewStatement) Name() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DescribeViewStatement) String() string {
+	return "DESCRIBE VIEW " + s.name
+}
+
+// NodeType returns an NodeType id
+func (s DescribeViewStatement) NodeType() NodeType { return DescribeViewType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DescribeViewStatement) RequiredPermissions() string { return "describe.view" }
+
+// CreateLogStatement represents the CREATE LOG statement
+type CreateLogStatement struct {
+	name     string
+	typeName string
+}
+
+// Name returns the name of the log to be created. The name may be relative to the session namespace.
+func (s CreateLogStatement) Name() string {
+	return s.name
+}
+
+// TypeName returns the name of the type records are validated against. It is empty for untyped logs.
+func (s CreateLogStatement) TypeName() string {
+	return s.typeName
+}
+
+// String returns a string representation
+func (s CreateLogStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("CREATE LOG ")
+	buf.WriteString(s.name)
+	if s.typeName != "" {
+		buf.WriteString(" USING TYPE ")
+		buf.WriteString(s.typeName)
+	}
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s CreateLogStatement) NodeType() NodeType { return CreateLogType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s CreateLogStatement) RequiredPermissions() string { return "create.log" }
+
+// FieldDefinition describes a field of a type. The type name is one of the built-in types such as uint64 or timestamp.
+type FieldDefinition struct {
+	Name     string
+	Type     string
+	Required bool
+}
+
+// String returns a string representation
+func (f FieldDefinition) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(f.Name)
+	buf.WriteString(" ")
+	buf.WriteString(f.Type)
+	if f.Required {
+		buf.WriteString(" REQUIRED")
+	} else {
+		buf.WriteString(" OPTIONAL")
+	}
+	return buf.String()
+}
+
+// CreateTypeStatement represents the CREATE TYPE statement
+type CreateTypeStatement struct {
+	name   string
+	fields []FieldDefinition
+}
+
+// Name returns the name of the type to be created. The name may be relative to the session namespace.
+func (s CreateTypeStatement) Name() string {
+	return s.name
+}
+
+// Fields returns the field definitions in the order they were declared
+func (s CreateTypeStatement) Fields() []FieldDefinition {
+	return s.fields
+}
+
+// String returns a string representation
+func (s CreateTypeStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("CREATE TYPE ")
+	buf.WriteString(s.name)
+	buf.WriteString(" (")
+	for i, f := range s.fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(f.String())
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s CreateTypeStatement) NodeType() NodeType { return CreateTypeType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s CreateTypeStatement) RequiredPermissions() string { return "create.type" }
+
+// Field is a named value in a record literal. Values are strings, int64, uint64, float64 or bool.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// RecordLiteral represents a record to be inserted into a log. Fields are kept in the order they were written.
+type RecordLiteral []Field
+
+// Map returns the record fields keyed by name
+func (r RecordLiteral) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(r))
+	for _, f := range r {
+		m[f.Name] = f.Value
+	}
+	return m
+}
+
+// String returns a string representation
+func (r RecordLiteral) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(f.Name))
+		buf.WriteString(": ")
+		buf.WriteString(formatValue(f.Value))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// InsertStatement represents the INSERT INTO statement
+type InsertStatement struct {
+	log     string
+	records []RecordLiteral
+}
+
+// Log returns the name of the log. The name may be relative to the session namespace.
+func (s InsertStatement) Log() string {
+	return s.log
+}
+
+// Records returns the records to be appended to the log
+func (s InsertStatement) Records() []RecordLiteral {
+	return s.records
+}
+
+// String returns a string representation
+func (s InsertStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(s.log)
+	for i, r := range s.records {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(" ")
+		buf.WriteString(r.String())
+	}
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s InsertStatement) NodeType() NodeType { return InsertType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s InsertStatement) RequiredPermissions() string { return "insert.log" }
+
+// SelectStatement represents the SELECT statement
+type SelectStatement struct {
+	fields    []string
+	source    string
+	condition Expr
+	limit     uint64
+	offset    uint64
+}
+
+// Fields returns the selected field names. An empty list selects all fields.
+func (s SelectStatement) Fields() []string {
+	return s.fields
+}
+
+// Source returns the name of the log or view being queried. The name may be relative to the session namespace.
+func (s SelectStatement) Source() string {
+	return s.source
+}
+
+// Condition returns the WHERE expression or nil if every record matches
+func (s SelectStatement) Condition() Expr {
+	return s.condition
+}
+
+// Limit returns the maximum number of rows to return. Zero means no limit.
+func (s SelectStatement) Limit() uint64 {
+	return s.limit
+}
+
+// Offset returns the number of matching rows to skip
+func (s SelectStatement) Offset() uint64 {
+	return s.offset
+}
+
+// String returns a string representation
+func (s SelectStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("SELECT ")
+	if len(s.fields) == 0 {
+		buf.WriteString("*")
+	} else {
+		buf.WriteString(strings.Join(s.fields, ", "))
+	}
+	buf.WriteString(" FROM ")
+	buf.WriteString(s.source)
+	if s.condition != nil {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(s.condition.String())
+	}
+	if s.limit > 0 {
+		buf.WriteString(" LIMIT ")
+		buf.WriteString(strconv.FormatUint(s.limit, 10))
+	}
+	if s.offset > 0 {
+		buf.WriteString(" OFFSET ")
+		buf.WriteString(strconv.FormatUint(s.offset, 10))
+	}
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s SelectStatement) NodeType() NodeType { return SelectType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s SelectStatement) RequiredPermissions() string { return "select.log" }
+
+// CreateViewStatement represents the CREATE VIEW statement
+type CreateViewStatement struct {
+	name        string
+	query       *SelectStatement
+	clusteredBy string
+}
+
+// Name returns the name of the view to be created. The name may be relative to the session namespace.
+func (s CreateViewStatement) Name() string {
+	return s.name
+}
+
+// Query returns the SELECT statement the view materializes
+func (s CreateViewStatement) Query() *SelectStatement {
+	return s.query
+}
+
+// ClusteredBy returns the field rows are keyed by. If empty, there is a row for every matching record.
+func (s CreateViewStatement) ClusteredBy() string {
+	return s.clusteredBy
+}
+
+// String returns a string representation
+func (s CreateViewStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("CREATE VIEW ")
+	buf.WriteString(s.name)
+	buf.WriteString(" AS ")
+	buf.WriteString(s.query.String())
+	if s.clusteredBy != "" {
+		buf.WriteString(" CLUSTERED BY ")
+		buf.WriteString(QuoteIdent(s.clusteredBy))
+	}
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s CreateViewStatement) NodeType() NodeType { return CreateViewType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s CreateViewStatement) RequiredPermissions() string { return "create.view" }
+
+// SubscribeStatement represents the SUBSCRIBE statement
+type SubscribeStatement struct {
+	log       string
+	offset    uint64
+	hasOffset bool
+	condition Expr
+}
+
+// Log returns the name of the log. The name may be relative to the session namespace.
+func (s SubscribeStatement) Log() string {
+	return s.log
+}
+
+// Offset returns the offset to start streaming from. The second value is false if no offset was given, in which
+// case only records appended after subscribing are streamed.
+func (s SubscribeStatement) Offset() (uint64, bool) {
+	return s.offset, s.hasOffset
+}
+
+// Condition returns the WHERE expression or nil if every record matches
+func (s SubscribeStatement) Condition() Expr {
+	return s.condition
+}
+
+// String returns a string representation
+func (s SubscribeStatement) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("SUBSCRIBE ")
+	buf.WriteString(s.log)
+	if s.hasOffset {
+		buf.WriteString(" FROM OFFSET ")
+		buf.WriteString(strconv.FormatUint(s.offset, 10))
+	}
+	if s.condition != nil {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(s.condition.String())
+	}
+	return buf.String()
+}
+
+// NodeType returns an NodeType id
+func (s SubscribeStatement) NodeType() NodeType { return SubscribeType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s SubscribeStatement) RequiredPermissions() string { return "subscribe.log" }
+
+// UnsubscribeStatement represents the UNSUBSCRIBE statement
+type UnsubscribeStatement struct {
+	log string
+}
+
+// Log returns the name of the log to stop streaming. An empty name stops every subscription.
+func (s UnsubscribeStatement) Log() string {
+	return s.log
+}
+
+// String returns a string representation
+func (s UnsubscribeStatement) String() string {
+	if s.log == "" {
+		return "UNSUBSCRIBE"
+	}
+	return "UNSUBSCRIBE " + s.log
+}
+
+// NodeType returns an NodeType id
+func (s UnsubscribeStatement) NodeType() NodeType { return UnsubscribeType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s UnsubscribeStatement) RequiredPermissions() string { return "" }
+
+// CreateUserStatement represents the CREATE USER statement
+type CreateUserStatement struct {
+	name string
+}
+
+// Username returns the name of the user to be created
+func (s CreateUserStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s CreateUserStatement) String() string {
+	return "CREATE USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s CreateUserStatement) NodeType() NodeType { return CreateUserType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s CreateUserStatement) RequiredPermissions() string { return "create.user" }
+
+// DropUserStatement represents the DROP USER statement
+type DropUserStatement struct {
+	name string
+}
+
+// Username returns the name of the user to be dropped
+func (s DropUserStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DropUserStatement) String() string {
+	return "DROP USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s DropUserStatement) NodeType() NodeType { return DropUserType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DropUserStatement) RequiredPermissions() string { return "drop.user" }
+
+// PromoteUserStatement represents the PROMOTE USER statement
+type PromoteUserStatement struct {
+	name string
+}
+
+// Username returns the name of the user to be promoted to a superuser
+func (s PromoteUserStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s PromoteUserStatement) String() string {
+	return "PROMOTE USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s PromoteUserStatement) NodeType() NodeType { return PromoteUserType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s PromoteUserStatement) RequiredPermissions() string { return "update.user" }
+
+// DemoteUserStatement represents the DEMOTE USER statement
+type DemoteUserStatement struct {
+	name string
+}
+
+// Username returns the name of the superuser to be demoted
+func (s DemoteUserStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s DemoteUserStatement) String() string {
+	return "DEMOTE USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s DemoteUserStatement) NodeType() NodeType { return DemoteUserType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s DemoteUserStatement) RequiredPermissions() string { return "update.user" }
+
+// SetPasswordStatement represents the SET PASSWORD FOR statement
+type SetPasswordStatement struct {
+	name     string
+	password string
+}
+
+// Username returns the name of the user whose password is being set
+func (s SetPasswordStatement) Username() string {
+	return s.name
+}
+
+// Password returns the new password
+func (s SetPasswordStatement) Password() string {
+	return s.password
+}
+
+// String returns a string representation. The password is masked so statements can be logged.
+func (s SetPasswordStatement) String() string {
+	return "SET PASSWORD FOR " + QuoteIdent(s.name) + " = '********'"
+}
+
+// NodeType returns an NodeType id
+func (s SetPasswordStatement) NodeType() NodeType { return SetPasswordType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s SetPasswordStatement) RequiredPermissions() string { return "update.user" }
+
+// SetOptionStatement represents the SET statement for session options such as SET ON_ERROR CONTINUE
+type SetOptionStatement struct {
+	option string
+	value  string
+}
+
+// Option returns the lower case name of the session option
+func (s SetOptionStatement) Option() string {
+	return s.option
+}
+
+// Value returns the lower case value of the session option
+func (s SetOptionStatement) Value() string {
+	return s.value
+}
+
+// String returns a string representation
+func (s SetOptionStatement) String() string {
+	return "SET " + strings.ToUpper(s.option) + " " + strings.ToUpper(s.value)
+}
+
+// NodeType returns an NodeType id
+func (s SetOptionStatement) NodeType() NodeType { return SetOptionType }
+
+// RequiredPermissions returns the required permissions in order to use this command. Session options only
+// affect the current session so no permissions are required.
+func (s SetOptionStatement) RequiredPermissions() string { return "" }
+
+// KeyOptions are the attributes of a key added with ADD KEY ... WITH. Keys expire either at a time or after a
+// duration from when they are added.
+type KeyOptions struct {
+	Comment    string
+	Expires    time.Time
+	ValidFor   time.Duration
+	Namespaces []string
+	ReadOnly   bool
+}
+
+// String returns the options as a WITH clause or an empty string if no options are set
+func (o KeyOptions) String() string {
+	var options []string
+	if o.Comment != "" {
+		options = append(options, "comment = "+QuoteString(o.Comment))
+	}
+	if !o.Expires.IsZero() {
+		options = append(options, "expires = "+QuoteString(o.Expires.Format(time.RFC3339Nano)))
+	} else if o.ValidFor != 0 {
+		options = append(options, "expires = "+QuoteString(o.ValidFor.String()))
+	}
+	if len(o.Namespaces) > 0 {
+		options = append(options, "namespaces = ("+strings.Join(o.Namespaces, ", ")+")")
+	}
+	if o.ReadOnly {
+		options = append(options, "read_only = true")
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return " WITH " + strings.Join(options, ", ")
+}
+
+// AddKeyStatement represents the ADD KEY statement
+type AddKeyStatement struct {
+	name    string
+	key     string
+	options KeyOptions
+}
+
+// Username returns the name of the user the key is added to
+func (s AddKeyStatement) Username() string {
+	return s.name
+}
+
+// Key returns the public key, either a PEM encoded certificate or an OpenSSH public key
+func (s AddKeyStatement) Key() string {
+	return s.key
+}
+
+// Options returns the attributes of the key
+func (s AddKeyStatement) Options() KeyOptions {
+	return s.options
+}
+
+// String returns a string representation
+func (s AddKeyStatement) String() string {
+	return "ADD KEY " + QuoteString(s.key) + " TO USER " + QuoteIdent(s.name) + s.options.String()
+}
+
+// NodeType returns an NodeType id
+func (s AddKeyStatement) NodeType() NodeType { return AddKeyType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s AddKeyStatement) RequiredPermissions() string { return "update.user" }
+
+// RemoveKeyStatement represents the REMOVE KEY statement
+type RemoveKeyStatement struct {
+	name        string
+	fingerprint string
+}
+
+// Username returns the name of the user the key is removed from
+func (s RemoveKeyStatement) Username() string {
+	return s.name
+}
+
+// Fingerprint returns the fingerprint of the key to be removed
+func (s RemoveKeyStatement) Fingerprint() string {
+	return s.fingerprint
+}
+
+// String returns a string representation
+func (s RemoveKeyStatement) String() string {
+	return "REMOVE KEY " + QuoteString(s.fingerprint) + " FROM USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s RemoveKeyStatement) NodeType() NodeType { return RemoveKeyType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s RemoveKeyStatement) RequiredPermissions() string { return "update.user" }
+
+// AddTOTPStatement represents the ADD TOTP statement
+type AddTOTPStatement struct {
+	name string
+}
+
+// Username returns the name of the user the second factor is added to
+func (s AddTOTPStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s AddTOTPStatement) String() string {
+	return "ADD TOTP TO USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s AddTOTPStatement) NodeType() NodeType { return AddTOTPType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s AddTOTPStatement) RequiredPermissions() string { return "update.user" }
+
+// RemoveTOTPStatement represents the REMOVE TOTP statement
+type RemoveTOTPStatement struct {
+	name string
+}
+
+// Username returns the name of the user the second factor is removed from
+func (s RemoveTOTPStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s RemoveTOTPStatement) String() string {
+	return "REMOVE TOTP FROM USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s RemoveTOTPStatement) NodeType() NodeType { return RemoveTOTPType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s RemoveTOTPStatement) RequiredPermissions() string { return "update.user" }
+
+// ShowKeysStatement represents the SHOW KEYS statement
+type ShowKeysStatement struct {
+	name string
+}
+
+// Username returns the name of the user whose keys are listed
+func (s ShowKeysStatement) Username() string {
+	return s.name
+}
+
+// String returns a string representation
+func (s ShowKeysStatement) String() string {
+	return "SHOW KEYS FOR USER " + QuoteIdent(s.name)
+}
+
+// NodeType returns an NodeType id
+func (s ShowKeysStatement) NodeType() NodeType { return ShowKeysType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s ShowKeysStatement) RequiredPermissions() string { return "show.keys" }
+
+// CreateRoleStatement represents the CREATE ROLE statement
+type CreateRoleStatement struct {
+	role      string
+	namespace string
+}
+
+// Role returns the name of the role to be created
+func (s CreateRoleStatement) Role() string {
+	return s.role
+}
+
+// Namespace returns the namespace the role is created in
+func (s CreateRoleStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s CreateRoleStatement) String() string {
+	return "CREATE ROLE " + QuoteIdent(s.role) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s CreateRoleStatement) NodeType() NodeType { return CreateRoleType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s CreateRoleStatement) RequiredPermissions() string { return "create.role" }
+
+// AddPermissionsStatement represents the ADD PERMISSIONS statement
+type AddPermissionsStatement struct {
+	permissions []string
+	role        string
+	namespace   string
+}
+
+// Permissions returns the permissions to be granted
+func (s AddPermissionsStatement) Permissions() []string {
+	return s.permissions
+}
+
+// Role returns the role the permissions are granted to
+func (s AddPermissionsStatement) Role() string {
+	return s.role
+}
+
+// Namespace returns the namespace of the role
+func (s AddPermissionsStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s AddPermissionsStatement) String() string {
+	return "ADD PERMISSIONS " + strings.Join(s.permissions, ", ") + " TO ROLE " + QuoteIdent(s.role) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s AddPermissionsStatement) NodeType() NodeType { return AddPermissionsType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s AddPermissionsStatement) RequiredPermissions() string { return "update.role" }
+
+// RemovePermissionsStatement represents the REMOVE PERMISSION statement
+type RemovePermissionsStatement struct {
+	permissions []string
+	role        string
+	namespace   string
+}
+
+// Permissions returns the permissions to be revoked
+func (s RemovePermissionsStatement) Permissions() []string {
+	return s.permissions
+}
+
+// Role returns the role the permissions are revoked from
+func (s RemovePermissionsStatement) Role() string {
+	return s.role
+}
+
+// Namespace returns the namespace of the role
+func (s RemovePermissionsStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s RemovePermissionsStatement) String() string {
+	return "REMOVE PERMISSIONS " + strings.Join(s.permissions, ", ") + " FROM ROLE " + QuoteIdent(s.role) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s RemovePermissionsStatement) NodeType() NodeType { return RemovePermissionsType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s RemovePermissionsStatement) RequiredPermissions() string { return "update.role" }
+
+// AddRoleStatement represents the ADD ROLE statement
+type AddRoleStatement struct {
+	role      string
+	user      string
+	namespace string
+}
+
+// Role returns the role to be granted
+func (s AddRoleStatement) Role() string {
+	return s.role
+}
+
+// Username returns the user the role is granted to
+func (s AddRoleStatement) Username() string {
+	return s.user
+}
+
+// Namespace returns the namespace of the role
+func (s AddRoleStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s AddRoleStatement) String() string {
+	return "ADD ROLE " + QuoteIdent(s.role) + " TO USER " + QuoteIdent(s.user) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s AddRoleStatement) NodeType() NodeType { return AddRoleType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s AddRoleStatement) RequiredPermissions() string { return "grant.role" }
+
+// RemoveRoleStatement represents the REMOVE ROLE statement
+type RemoveRoleStatement struct {
+	role      string
+	user      string
+	namespace string
+}
+
+// Role returns the role to be revoked
+func (s RemoveRoleStatement) Role() string {
+	return s.role
+}
+
+// Username returns the user the role is revoked from
+func (s RemoveRoleStatement) Username() string {
+	return s.user
+}
+
+// Namespace returns the namespace of the role
+func (s RemoveRoleStatement) Namespace() string {
+	return s.namespace
+}
+
+// String returns a string representation
+func (s RemoveRoleStatement) String() string {
+	return "REMOVE ROLE " + QuoteIdent(s.role) + " FROM USER " + QuoteIdent(s.user) + " ON " + s.namespace
+}
+
+// NodeType returns an NodeType id
+func (s RemoveRoleStatement) NodeType() NodeType { return RemoveRoleType }
+
+// RequiredPermissions returns the required permissions in order to use this command
+func (s RemoveRoleStatement) RequiredPermissions() string { return "grant.role" }
+
+// BinaryExpr represents an operation between two expressions
+type BinaryExpr struct {
+	Op  lexer.Token
+	LHS Expr
+	RHS Expr
+}
+
+// String returns a string representation
+func (e BinaryExpr) String() string {
+	return e.LHS.String() + " " + e.Op.String() + " " + e.RHS.String()
+}
+
+// NodeType returns an NodeType id
+func (e BinaryExpr) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e BinaryExpr) ExprType() ExprType { return BinaryExprType }
+
+// UnaryExpr represents an operation on a single expression. The operator is either MINUS or NOT.
+type UnaryExpr struct {
+	Op   lexer.Token
+	Expr Expr
+}
+
+// String returns a string representation
+func (e UnaryExpr) String() string {
+	if e.Op == NOT {
+		return "NOT " + e.Expr.String()
+	}
+	return e.Op.String() + e.Expr.String()
+}
+
+// NodeType returns an NodeType id
+func (e UnaryExpr) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e UnaryExpr) ExprType() ExprType { return UnaryExprType }
+
+// ParenExpr represents a parenthesized expression
+type ParenExpr struct {
+	Expr Expr
+}
+
+// String returns a string representation
+func (e ParenExpr) String() string {
+	return "(" + e.Expr.String() + ")"
+}
+
+// NodeType returns an NodeType id
+func (e ParenExpr) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e ParenExpr) ExprType() ExprType { return ParenExprType }
+
+// VarRef represents a reference to a record field
+type VarRef struct {
+	Val string
+}
+
+// String returns a string representation
+func (e VarRef) String() string {
+	return QuoteIdent(e.Val)
+}
+
+// NodeType returns an NodeType id
+func (e VarRef) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e VarRef) ExprType() ExprType { return VarRefType }
+
+// StringLiteral represents a string literal
+type StringLiteral struct {
+	Val string
+}
+
+// String returns a string representation
+func (e StringLiteral) String() string {
+	return QuoteString(e.Val)
+}
+
+// NodeType returns an NodeType id
+func (e StringLiteral) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e StringLiteral) ExprType() ExprType { return StringLiteralType }
+
+// NumberLiteral represents a numeric literal. The value is an int64, uint64 or float64.
+type NumberLiteral struct {
+	Val interface{}
+}
+
+// String returns a string representation
+func (e NumberLiteral) String() string {
+	return formatValue(e.Val)
+}
+
+// NodeType returns an NodeType id
+func (e NumberLiteral) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e NumberLiteral) ExprType() ExprType { return NumberLiteralType }
+
+// BooleanLiteral represents a boolean literal
+type BooleanLiteral struct {
+	Val bool
+}
+
+// String returns a string representation
+func (e BooleanLiteral) String() string {
+	return strconv.FormatBool(e.Val)
+}
+
+// NodeType returns an NodeType id
+func (e BooleanLiteral) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e BooleanLiteral) ExprType() ExprType { return BooleanLiteralType }
+
+// TimestampLiteral represents a timestamp literal such as TIMESTAMP '2015-06-01 12:30:00'
+type TimestampLiteral struct {
+	Val time.Time
+}
+
+// String returns a string representation
+func (e TimestampLiteral) String() string {
+	return "TIMESTAMP " + QuoteString(e.Val.UTC().Format(time.RFC3339Nano))
+}
+
+// NodeType returns an NodeType id
+func (e TimestampLiteral) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e TimestampLiteral) ExprType() ExprType { return TimestampLiteralType }
+
+// RegexLiteral represents a regular expression
+type RegexLiteral struct {
+	Val *regexp.Regexp
+}
+
+// String returns a string representation
+func (e RegexLiteral) String() string {
+	return "/" + strings.Replace(e.Val.String(), "/", `\/`, -1) + "/"
+}
+
+// NodeType returns an NodeType id
+func (e RegexLiteral) NodeType() NodeType { return ExpressionType }
+
+// ExprType returns an ExprType id
+func (e RegexLiteral) ExprType() ExprType { return RegexLiteralType }
+
+// QuoteIdent returns an identifier, quoting it if it is not a bare word or collides with a keyword
+func QuoteIdent(s string) string {
+	bare := s != ""
+	for i, ch := range s {
+		if !(ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 0 && ch >= '0' && ch <= '9')) {
+			bare = false
+			break
+		}
+	}
+	if bare && lexer.Lookup(s) == lexer.IDENT {
+		return s
+	}
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// QuoteString returns a single quoted string with quotes, backslashes and new lines escaped
+func QuoteString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return `'` + s + `'`
+}
+
+// formatValue returns the literal representation of a field value
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return QuoteString(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return QuoteString(v.Format(time.RFC3339Nano))
+	case nil:
+		return "null"
+	}
+	return ""
+}
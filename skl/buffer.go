@@ -0,0 +1,61 @@
+package skl
+
+import "strings"
+
+// StatementBuffer assembles statements from lines of input. The buffered statements are complete once a line ends
+// with a semicolon outside of a quoted string or identifier, or once a blank line is written.
+type StatementBuffer struct {
+	lines []string
+}
+
+// Write appends a line to the buffer and returns true if the buffered statements are complete.
+// Blank lines are not buffered.
+func (b *StatementBuffer) Write(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return len(b.lines) > 0
+	}
+
+	b.lines = append(b.lines, line)
+	return terminated(line)
+}
+
+// Empty returns true if no lines have been buffered
+func (b *StatementBuffer) Empty() bool {
+	return len(b.lines) == 0
+}
+
+// String returns the buffered lines joined by newlines so parse errors report the original line numbers
+func (b *StatementBuffer) String() string {
+	return strings.Join(b.lines, "\n")
+}
+
+// Reset discards the buffered lines
+func (b *StatementBuffer) Reset() {
+	b.lines = nil
+}
+
+// terminated determines if a line ends with a semicolon outside of quotes. Quoted strings and identifiers can't
+// span lines so each line is scanned on its own.
+func terminated(line string) bool {
+	var quote rune
+	var escaped, semicolon bool
+	for _, ch := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if ch == '\\' {
+				escaped = true
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote, semicolon = ch, false
+		case ch == ';':
+			semicolon = true
+		case ch != ' ' && ch != '\t' && ch != '\r':
+			semicolon = false
+		}
+	}
+	return quote == 0 && semicolon
+}
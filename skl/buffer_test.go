@@ -0,0 +1,46 @@
+package skl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure statements are only complete once terminated by a semicolon outside quotes or a blank line
+func TestStatementBuffer(t *testing.T) {
+	var tests = []struct {
+		lines    []string
+		complete []bool
+		s        string
+	}{
+		{lines: []string{"USE acme;"}, complete: []bool{true}, s: "USE acme;"},
+		{lines: []string{"USE acme ;  "}, complete: []bool{true}, s: "USE acme ;  "},
+		{lines: []string{"", "  "}, complete: []bool{false, false}, s: ""},
+		{
+			lines:    []string{"SELECT *", "FROM events", "WHERE id = 1;"},
+			complete: []bool{false, false, true},
+			s:        "SELECT *\nFROM events\nWHERE id = 1;",
+		},
+		{lines: []string{"SHOW LOGS", ""}, complete: []bool{false, true}, s: "SHOW LOGS"},
+		{lines: []string{"USE acme; SHOW", "LOGS;"}, complete: []bool{false, true}, s: "USE acme; SHOW\nLOGS;"},
+		{
+			lines:    []string{"SET PASSWORD FOR bob = 'a;b'", ";"},
+			complete: []bool{false, true},
+			s:        "SET PASSWORD FOR bob = 'a;b'\n;",
+		},
+		{lines: []string{`INSERT INTO events {"a;": 'it\'s;'}`}, complete: []bool{false}, s: `INSERT INTO events {"a;": 'it\'s;'}`},
+		{lines: []string{`INSERT INTO events {"a;": 'it\'s;'};`}, complete: []bool{true}, s: `INSERT INTO events {"a;": 'it\'s;'};`},
+	}
+
+	for i, tt := range tests {
+		var b StatementBuffer
+		for j, line := range tt.lines {
+			assert.Equal(t, tt.complete[j], b.Write(line), "%d. line %d: %q", i, j, line)
+		}
+		assert.Equal(t, tt.s, b.String(), "%d", i)
+		assert.Equal(t, tt.s == "", b.Empty(), "%d", i)
+
+		b.Reset()
+		assert.True(t, b.Empty(), "%d", i)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/eliquious/lexer"
+	"github.com/subsilent/kappa/common"
 )
 
 // ParseError represents an error that occurred during parsing.
@@ -22,8 +23,23 @@ func newParseError(found string, expected []string, pos lexer.Pos) *ParseError {
 
 // Error returns the string representation of the error.
 func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at line %d, char %d", e.message(), e.Pos.Line+1, e.Pos.Char+1)
+}
+
+// StatusError converts the error into a SyntaxError with the position of the error. Errors about unexpected
+// tokens have the found and expected tokens as details.
+func (e *ParseError) StatusError() *common.Error {
+	err := common.NewError(common.SyntaxError, "%s", e.message()).At(common.Position{Line: e.Pos.Line + 1, Char: e.Pos.Char + 1})
+	if e.Message == "" {
+		err = err.With("found", e.Found).With("expected", e.Expected)
+	}
+	return err
+}
+
+// message returns the message of the error without the position
+func (e *ParseError) message() string {
 	if e.Message != "" {
-		return fmt.Sprintf("%s at line %d, char %d", e.Message, e.Pos.Line+1, e.Pos.Char+1)
+		return e.Message
 	}
-	return fmt.Sprintf("found %s, expected %s at line %d, char %d", e.Found, strings.Join(e.Expected, ", "), e.Pos.Line+1, e.Pos.Char+1)
+	return fmt.Sprintf("found %s, expected %s", e.Found, strings.Join(e.Expected, ", "))
 }
@@ -0,0 +1,23 @@
+package skl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/common"
+)
+
+// Ensure parse errors convert into syntax errors with positions starting at 1
+func TestParseErrorStatus(t *testing.T) {
+	_, err := ParseQuery("USE acme;\nSHOW bob")
+	if !assert.Error(t, err) {
+		return
+	}
+
+	e := common.AsError(err)
+	assert.Equal(t, common.SyntaxError, e.Code)
+	assert.Equal(t, common.ParseCategory, e.Category)
+	assert.Equal(t, &common.Position{Line: 2, Char: 6}, e.Position)
+	assert.Equal(t, "bob", e.Details["found"])
+	assert.Equal(t, err.Error(), e.Error())
+}
@@ -0,0 +1,321 @@
+package skl
+
+import (
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/eliquious/lexer"
+)
+
+// Eval evaluates an expression against a record. Numbers in the record must be int64, uint64 or float64 values and
+// timestamps must be time.Time values, which is how records of typed logs are decoded.
+//
+// Nil represents a null or unknown value. Fields missing from the record are null, as are the results of operations
+// on null operands or on operands of incompatible types. AND, OR and NOT use three-valued logic so that
+// false AND null is false and true OR null is true.
+func Eval(expr Expr, record map[string]interface{}) interface{} {
+	switch expr := expr.(type) {
+	case *BinaryExpr:
+		return evalBinaryExpr(expr, record)
+	case *UnaryExpr:
+		return evalUnaryExpr(expr, record)
+	case *ParenExpr:
+		return Eval(expr.Expr, record)
+	case *VarRef:
+		return record[expr.Val]
+	case *StringLiteral:
+		return expr.Val
+	case *NumberLiteral:
+		return expr.Val
+	case *BooleanLiteral:
+		return expr.Val
+	case *TimestampLiteral:
+		return expr.Val
+	case *RegexLiteral:
+		return expr.Val
+	}
+	return nil
+}
+
+// EvalBool evaluates an expression against a record and returns true only if the result is the boolean true.
+// Null results are treated as false.
+func EvalBool(expr Expr, record map[string]interface{}) bool {
+	b, _ := Eval(expr, record).(bool)
+	return b
+}
+
+// evalBinaryExpr evaluates both sides of a binary expression and applies the operator
+func evalBinaryExpr(expr *BinaryExpr, record map[string]interface{}) interface{} {
+	lhs, rhs := Eval(expr.LHS, record), Eval(expr.RHS, record)
+	switch expr.Op {
+	case lexer.AND:
+		l, lok := lhs.(bool)
+		r, rok := rhs.(bool)
+		if (lok && !l) || (rok && !r) {
+			return false
+		} else if lok && rok {
+			return true
+		}
+		return nil
+	case lexer.OR:
+		l, lok := lhs.(bool)
+		r, rok := rhs.(bool)
+		if (lok && l) || (rok && r) {
+			return true
+		} else if lok && rok {
+			return false
+		}
+		return nil
+	}
+
+	// Every other operator is null if either side is null
+	if lhs == nil || rhs == nil {
+		return nil
+	}
+
+	switch expr.Op {
+	case lexer.EQREGEX, lexer.NEQREGEX:
+		s, ok := lhs.(string)
+		re, isRegex := rhs.(*regexp.Regexp)
+		if !ok || !isRegex {
+			return nil
+		}
+		return re.MatchString(s) == (expr.Op == lexer.EQREGEX)
+	case lexer.PLUS, lexer.MINUS, lexer.MUL, lexer.DIV:
+		return arithmetic(expr.Op, lhs, rhs)
+	}
+
+	// Remaining operators are comparisons. Booleans are not ordered.
+	if _, ok := lhs.(bool); ok && expr.Op != lexer.EQ && expr.Op != lexer.NEQ {
+		return nil
+	}
+	cmp, ok := compare(coerce(lhs, rhs), coerce(rhs, lhs))
+	if !ok {
+		return nil
+	}
+	switch expr.Op {
+	case lexer.EQ:
+		return cmp == 0
+	case lexer.NEQ:
+		return cmp != 0
+	case lexer.LT:
+		return cmp < 0
+	case lexer.LTE:
+		return cmp <= 0
+	case lexer.GT:
+		return cmp > 0
+	case lexer.GTE:
+		return cmp >= 0
+	}
+	return nil
+}
+
+// evalUnaryExpr evaluates negation and logical NOT
+func evalUnaryExpr(expr *UnaryExpr, record map[string]interface{}) interface{} {
+	value := Eval(expr.Expr, record)
+	if expr.Op == NOT {
+		if b, ok := value.(bool); ok {
+			return !b
+		}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if v != math.MinInt64 {
+			return -v
+		}
+	case uint64:
+		if v <= math.MaxInt64+1 {
+			return int64(-v)
+		}
+	case float64:
+		return -v
+	}
+	return nil
+}
+
+// coerce converts a value so it can be compared to another value. Strings compared to timestamps are parsed as
+// timestamps. Other values are returned unchanged.
+func coerce(value, other interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		if _, ok := other.(time.Time); ok {
+			if ts, err := parseTimestamp(s); err == nil {
+				return ts
+			}
+		}
+	}
+	return value
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater than b.
+// Unequal booleans return 1. It returns false if the values can't be compared.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1, true
+			case a.After(b):
+				return 1, true
+			}
+			return 0, true
+		}
+	case int64, uint64, float64:
+		return compareNumbers(a, b)
+	}
+	return 0, false
+}
+
+// compareNumbers compares two numeric values without losing precision when both are integers
+func compareNumbers(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return compareInt64(a, b), true
+		case uint64:
+			if a < 0 {
+				return -1, true
+			}
+			return compareUint64(uint64(a), b), true
+		}
+	case uint64:
+		switch b := b.(type) {
+		case uint64:
+			return compareUint64(a, b), true
+		case int64:
+			if b < 0 {
+				return 1, true
+			}
+			return compareUint64(a, uint64(b)), true
+		}
+	}
+
+	// Fall back to floating point
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toFloat(b)
+	if !ok || math.IsNaN(x) || math.IsNaN(y) {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// arithmetic applies an arithmetic operator to two numbers. Integer operands produce an int64 if they fit,
+// otherwise the operation is performed on float64 values. Division by zero evaluates to nil.
+func arithmetic(op lexer.Token, a, b interface{}) interface{} {
+	x, xok := toInt(a)
+	y, yok := toInt(b)
+	if xok && yok {
+		switch op {
+		case lexer.PLUS:
+			return x + y
+		case lexer.MINUS:
+			return x - y
+		case lexer.MUL:
+			return x * y
+		case lexer.DIV:
+			if y == 0 {
+				return nil
+			}
+			return x / y
+		}
+	}
+
+	f, ok := toFloat(a)
+	if !ok {
+		return nil
+	}
+	g, ok := toFloat(b)
+	if !ok {
+		return nil
+	}
+	switch op {
+	case lexer.PLUS:
+		return f + g
+	case lexer.MINUS:
+		return f - g
+	case lexer.MUL:
+		return f * g
+	case lexer.DIV:
+		if g == 0 {
+			return nil
+		}
+		return f / g
+	}
+	return nil
+}
+
+// toInt converts an integer value to an int64 if it fits
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts a numeric value to a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
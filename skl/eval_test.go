@@ -0,0 +1,132 @@
+package skl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// TestEvalTestSuite runs the EvalTestSuite
+func TestEvalTestSuite(t *testing.T) {
+	suite.Run(t, new(EvalTestSuite))
+}
+
+// EvalTestSuite tests expression evaluation
+type EvalTestSuite struct {
+	suite.Suite
+}
+
+// EvalCase is an expression and its expected result
+type EvalCase struct {
+	s      string
+	result interface{}
+}
+
+func (suite *EvalTestSuite) validate(record map[string]interface{}, tests []EvalCase) {
+	for i, tt := range tests {
+		expr, err := NewParser(strings.NewReader(tt.s)).ParseExpr()
+		if err != nil {
+			suite.T().Errorf("%d. %q: unexpected error: %s", i, tt.s, err)
+			continue
+		}
+
+		if result := Eval(expr, record); result != tt.result {
+			suite.T().Errorf("%d. %q: result mismatch:\n  exp=%#v\n  got=%#v\n\n", i, tt.s, tt.result, result)
+		}
+	}
+}
+
+// Ensure comparisons and boolean operators are evaluated
+func (suite *EvalTestSuite) TestComparisons() {
+	record := map[string]interface{}{
+		"id":     uint64(10),
+		"delta":  int64(-5),
+		"score":  float64(2.5),
+		"name":   "login",
+		"active": true,
+	}
+
+	suite.validate(record, []EvalCase{
+		{`id = 10`, true},
+		{`id != 10`, false},
+		{`id > 9.5`, true},
+		{`delta < id`, true},
+		{`delta >= -5`, true},
+		{`score <= 2`, false},
+		{`name = 'login'`, true},
+		{`name < 'logout'`, true},
+		{`name =~ /^log/`, true},
+		{`name !~ /^log/`, false},
+		{`active = true`, true},
+		{`active > false`, nil},
+		{`id = 10 AND name = 'login'`, true},
+		{`id = 11 OR name = 'login'`, true},
+		{`id = 11 OR (name = 'login' AND active = false)`, false},
+		{`missing = 1`, nil},
+		{`name = 1`, nil},
+		{`NOT active`, false},
+		{`NOT id = 11`, true},
+		{`NOT name`, nil},
+	})
+}
+
+// Ensure nulls propagate using three-valued logic
+func (suite *EvalTestSuite) TestNulls() {
+	record := map[string]interface{}{
+		"id": int64(1),
+	}
+
+	suite.validate(record, []EvalCase{
+		{`missing`, nil},
+		{`missing = 1 AND id = 1`, nil},
+		{`missing = 1 AND id = 2`, false},
+		{`missing = 1 OR id = 1`, true},
+		{`missing = 1 OR id = 2`, nil},
+		{`NOT missing = 1`, nil},
+		{`-missing`, nil},
+		{`missing =~ /x/`, nil},
+	})
+}
+
+// Ensure timestamps are compared with timestamp literals and strings
+func (suite *EvalTestSuite) TestTimestamps() {
+	record := map[string]interface{}{
+		"at": time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	suite.validate(record, []EvalCase{
+		{`at = TIMESTAMP '2015-06-01T12:30:00Z'`, true},
+		{`at > TIMESTAMP '2015-06-01'`, true},
+		{`at < TIMESTAMP '2015-06-01 12:00:00'`, false},
+		{`at >= '2015-06-01'`, true},
+		{`'2015-07-01' > at`, true},
+		{`at = 'noon'`, nil},
+		{`at = 1`, nil},
+	})
+}
+
+// Ensure arithmetic operators are evaluated
+func (suite *EvalTestSuite) TestArithmetic() {
+	record := map[string]interface{}{
+		"a": int64(7),
+		"b": uint64(2),
+		"c": float64(0.5),
+	}
+
+	suite.validate(record, []EvalCase{
+		{`a + b`, int64(9)},
+		{`a - b * 2`, int64(3)},
+		{`(a - b) * 2`, int64(10)},
+		{`a / b`, int64(3)},
+		{`a * c`, float64(3.5)},
+		{`a / 0`, nil},
+		{`a-1 = 6`, true},
+		{`a + missing`, nil},
+		{`-a`, int64(-7)},
+		{`-b * 2`, int64(-4)},
+		{`-(a + b)`, int64(-9)},
+		{`-c`, float64(-0.5)},
+	})
+}
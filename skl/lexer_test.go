@@ -34,16 +34,21 @@ func TestScanner_Scan(t *testing.T) {
 
 		// Keywords
 		{s: `ADD`, tok: ADD},
+		{s: `AS`, tok: AS},
 		{s: `BY`, tok: BY},
+		{s: `CASCADE`, tok: CASCADE},
 		{s: `CLUSTERED`, tok: CLUSTERED},
 		{s: `CREATE`, tok: CREATE},
 		{s: `DESCRIBE`, tok: DESCRIBE},
 		{s: `FOR`, tok: FOR},
 		{s: `FROM`, tok: FROM},
 		{s: `INSERT`, tok: INSERT},
+		{s: `INTO`, tok: INTO},
+		{s: `KEY`, tok: KEY},
 		{s: `LIMIT`, tok: LIMIT},
 		{s: `LOG`, tok: LOG},
 		{s: `NAMESPACE`, tok: NAMESPACE},
+		{s: `NOT`, tok: NOT},
 		{s: `OFFSET`, tok: OFFSET},
 		{s: `ON`, tok: ON},
 		{s: `OPTIONAL`, tok: OPTIONAL},
@@ -64,6 +69,7 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `USE`, tok: USE},
 		{s: `USER`, tok: USER},
 		{s: `USING`, tok: USING},
+		{s: `VALUES`, tok: VALUES},
 		{s: `VIEW`, tok: VIEW},
 		{s: `WHERE`, tok: WHERE},
 		{s: `WITH`, tok: WITH},
@@ -3,8 +3,11 @@ package skl
 import (
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/eliquious/lexer"
 )
@@ -32,6 +35,54 @@ func ParseStatement(s string) (Statement, error) {
 	return NewParser(strings.NewReader(s)).ParseStatement()
 }
 
+// ParseQuery parses a string of semicolon separated statements and returns a Query.
+func ParseQuery(s string) (*Query, error) {
+	return NewParser(strings.NewReader(s)).ParseQuery()
+}
+
+// SessionOptions are the options accepted by SET and their allowed values. The first value is the default.
+var SessionOptions = map[string][]string{
+	"on_error": {"stop", "continue"},
+	"format":   {"table", "json", "csv", "tsv"},
+}
+
+// ParseQuery parses a list of semicolon separated statements and returns a Query.
+func (p *Parser) ParseQuery() (*Query, error) {
+	stmts, err := p.ParseStatements()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Statements: stmts}, nil
+}
+
+// ParseStatements parses a list of statements separated by semicolons. Empty statements are skipped.
+// Each statement must be followed by a semicolon or the end of the input.
+func (p *Parser) ParseStatements() ([]ParsedStatement, error) {
+	var stmts []ParsedStatement
+	for {
+		tok, pos, _ := p.scanIgnoreWhitespace()
+		if tok == lexer.EOF {
+			return stmts, nil
+		} else if tok == lexer.SEMICOLON {
+			continue
+		}
+		p.unscan()
+
+		stmt, err := p.ParseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, ParsedStatement{stmt, pos})
+
+		// Reject trailing input
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok == lexer.EOF {
+			return stmts, nil
+		} else if tok != lexer.SEMICOLON {
+			return nil, newParseError(tokstr(tok, lit), []string{";"}, pos)
+		}
+	}
+}
+
 // ParseStatement parses a string and returns a Statement AST object.
 func (p *Parser) ParseStatement() (Statement, error) {
 
@@ -46,8 +97,28 @@ func (p *Parser) ParseStatement() (Statement, error) {
 		return p.parseDropStatement()
 	case SHOW:
 		return p.parseShowStatement()
+	case INSERT:
+		return p.parseInsertStatement()
+	case SELECT:
+		return p.parseSelectStatement()
+	case SUBSCRIBE:
+		return p.parseSubscribeStatement()
+	case UNSUBSCRIBE:
+		return p.parseUnsubscribeStatement()
+	case SET:
+		return p.parseSetStatement()
+	case ADD:
+		return p.parseAddStatement()
+	case REMOVE:
+		return p.parseRemoveStatement()
+	case DESCRIBE:
+		return p.parseDescribeStatement()
+	case PROMOTE:
+		return p.parsePromoteUserStatement()
+	case DEMOTE:
+		return p.parseDemoteUserStatement()
 	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"USE", "CREATE", "SHOW", "DROP"}, pos)
+		return nil, newParseError(tokstr(tok, lit), []string{"USE", "CREATE", "SHOW", "DROP", "INSERT", "SELECT", "SUBSCRIBE", "UNSUBSCRIBE", "SET", "ADD", "REMOVE", "DESCRIBE", "PROMOTE", "DEMOTE"}, pos)
 	}
 }
 
@@ -75,8 +146,18 @@ func (p *Parser) parseCreateStatement() (Statement, error) {
 	switch tok {
 	case NAMESPACE:
 		return p.parseCreateNamespaceStatement()
+	case LOG:
+		return p.parseCreateLogStatement()
+	case TYPE:
+		return p.parseCreateTypeStatement()
+	case VIEW:
+		return p.parseCreateViewStatement()
+	case USER:
+		return p.parseCreateUserStatement()
+	case ROLE:
+		return p.parseCreateRoleStatement()
 	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACE"}, pos)
+		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACE", "LOG", "TYPE", "VIEW", "USER", "ROLE"}, pos)
 	}
 }
 
@@ -95,6 +176,99 @@ func (p *Parser) parseCreateNamespaceStatement() (*CreateNamespaceStatement, err
 	return stmt, nil
 }
 
+// parseCreateLogStatement parses a string and returns a CreateLogStatement.
+// This function assumes the "CREATE LOG" tokens have already been consumed.
+func (p *Parser) parseCreateLogStatement() (*CreateLogStatement, error) {
+	stmt := &CreateLogStatement{}
+
+	// Parse the name of the log to be created
+	lit, err := p.parseQualifiedName("log name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.name = lit
+
+	// Parse optional USING TYPE clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != USING {
+		p.unscan()
+		return stmt, nil
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TYPE {
+		return nil, newParseError(tokstr(tok, lit), []string{"TYPE"}, pos)
+	}
+
+	lit, err = p.parseQualifiedName("type name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.typeName = lit
+
+	return stmt, nil
+}
+
+// parseCreateTypeStatement parses a string and returns a CreateTypeStatement.
+//
+//	CREATE TYPE name (field type [REQUIRED | OPTIONAL], ...)
+//
+// Fields are optional unless declared REQUIRED.
+// This function assumes the "CREATE TYPE" tokens have already been consumed.
+func (p *Parser) parseCreateTypeStatement() (*CreateTypeStatement, error) {
+	stmt := &CreateTypeStatement{}
+
+	// Parse the name of the type to be created
+	lit, err := p.parseQualifiedName("type name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.name = lit
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
+
+	// Parse field definitions
+	for {
+		field := FieldDefinition{}
+
+		// Parse field name
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != lexer.IDENT {
+			return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos)
+		}
+
+		// Field names must be unique
+		for _, f := range stmt.fields {
+			if f.Name == lit {
+				return nil, &ParseError{Message: fmt.Sprintf("duplicate field '%s'", lit), Pos: pos}
+			}
+		}
+		field.Name = lit
+
+		// Parse field type
+		tok, pos, lit = p.scanIgnoreWhitespace()
+		if tok <= startTypes || tok >= endTypes {
+			return nil, newParseError(tokstr(tok, lit), []string{"type"}, pos)
+		}
+		field.Type = tok.String()
+
+		// Parse optional REQUIRED or OPTIONAL modifier
+		tok, pos, lit = p.scanIgnoreWhitespace()
+		if tok == REQUIRED || tok == OPTIONAL {
+			field.Required = tok == REQUIRED
+			tok, pos, lit = p.scanIgnoreWhitespace()
+		}
+		stmt.fields = append(stmt.fields, field)
+
+		if tok == lexer.RPAREN {
+			break
+		} else if tok != lexer.COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+		}
+	}
+
+	return stmt, nil
+}
+
 // parseDropStatement parses a string and returns a Statement AST object.
 // This function assumes the "DROP" token has already been consumed.
 func (p *Parser) parseDropStatement() (Statement, error) {
@@ -103,8 +277,10 @@ func (p *Parser) parseDropStatement() (Statement, error) {
 	switch tok {
 	case NAMESPACE:
 		return p.parseDropNamespaceStatement()
+	case USER:
+		return p.parseDropUserStatement()
 	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACE"}, pos)
+		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACE", "USER"}, pos)
 	}
 }
 
@@ -120,65 +296,1346 @@ func (p *Parser) parseDropNamespaceStatement() (*DropNamespaceStatement, error)
 	}
 	stmt.name = lit
 
+	// Parse optional CASCADE
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == CASCADE {
+		stmt.cascade = true
+	} else {
+		p.unscan()
+	}
 	return stmt, nil
 }
 
-// parseShowStatement parses a string and returns a Statement AST object.
-// This function assumes the "SHOW" token has already been consumed.
-func (p *Parser) parseShowStatement() (Statement, error) {
+// parseCreateUserStatement parses a string and returns a CreateUserStatement.
+// This function assumes the "CREATE USER" tokens have already been consumed.
+func (p *Parser) parseCreateUserStatement() (*CreateUserStatement, error) {
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateUserStatement{name: name}, nil
+}
+
+// parseDropUserStatement parses a string and returns a DropUserStatement.
+// This function assumes the "DROP USER" tokens have already been consumed.
+func (p *Parser) parseDropUserStatement() (*DropUserStatement, error) {
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &DropUserStatement{name: name}, nil
+}
+
+// parsePromoteUserStatement parses a string and returns a PromoteUserStatement.
+// This function assumes the "PROMOTE" token has already been consumed.
+func (p *Parser) parsePromoteUserStatement() (*PromoteUserStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &PromoteUserStatement{name: name}, nil
+}
+
+// parseDemoteUserStatement parses a string and returns a DemoteUserStatement.
+// This function assumes the "DEMOTE" token has already been consumed.
+func (p *Parser) parseDemoteUserStatement() (*DemoteUserStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &DemoteUserStatement{name: name}, nil
+}
+
+// parseSetStatement parses a string and returns a Statement AST object.
+// This function assumes the "SET" token has already been consumed.
+func (p *Parser) parseSetStatement() (Statement, error) {
 
 	// Inspect the first token.
 	tok, pos, lit := p.scanIgnoreWhitespace()
 	switch tok {
-	case NAMESPACES:
-		return &ShowNamespacesStatement{}, nil
-	default:
-		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACES"}, pos)
+	case PASSWORD:
+		return p.parseSetPasswordStatement()
+	case lexer.IDENT:
+		if _, ok := SessionOptions[strings.ToLower(lit)]; ok {
+			return p.parseSetOptionStatement(strings.ToLower(lit))
+		}
+	}
+
+	expected := []string{"PASSWORD"}
+	for option := range SessionOptions {
+		expected = append(expected, strings.ToUpper(option))
 	}
+	sort.Strings(expected[1:])
+	return nil, newParseError(tokstr(tok, lit), expected, pos)
 }
 
-// parseNamespace returns a namespace title or an error
-func (p *Parser) parseNamespace() (string, error) {
-	var namespace string
+// parseSetOptionStatement parses a string and returns a SetOptionStatement.
+//
+//	SET ON_ERROR CONTINUE
+//
+// This function assumes the "SET" token and the option have already been consumed.
+func (p *Parser) parseSetOptionStatement(option string) (*SetOptionStatement, error) {
+	values := SessionOptions[option]
+
 	tok, pos, lit := p.scanIgnoreWhitespace()
-	if tok != lexer.IDENT {
-		return "", newParseError(tokstr(tok, lit), []string{"namespace"}, pos)
+	if tok == lexer.IDENT {
+		for _, value := range values {
+			if strings.ToLower(lit) == value {
+				return &SetOptionStatement{option: option, value: value}, nil
+			}
+		}
 	}
-	namespace = lit
 
-	// Scan entire namespace
-	// Namespaces are a period delimited list of identifiers
-	var endPeriod bool
+	var expected []string
+	for _, value := range values {
+		expected = append(expected, strings.ToUpper(value))
+	}
+	return nil, newParseError(tokstr(tok, lit), expected, pos)
+}
+
+// parseSetPasswordStatement parses a string and returns a SetPasswordStatement.
+//
+//	SET PASSWORD FOR user = 'password'
+//
+// This function assumes the "SET PASSWORD" tokens have already been consumed.
+func (p *Parser) parseSetPasswordStatement() (*SetPasswordStatement, error) {
+	stmt := &SetPasswordStatement{}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FOR {
+		return nil, newParseError(tokstr(tok, lit), []string{"FOR"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	stmt.name = name
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.EQ {
+		return nil, newParseError(tokstr(tok, lit), []string{"="}, pos)
+	}
+
+	if stmt.password, err = p.parseString(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseAddStatement parses a string and returns a Statement AST object.
+// This function assumes the "ADD" token has already been consumed.
+func (p *Parser) parseAddStatement() (Statement, error) {
+
+	// Inspect the first token.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case KEY:
+		return p.parseAddKeyStatement()
+	case PERMISSION, PERMISSIONS:
+		return p.parseAddPermissionsStatement()
+	case ROLE:
+		return p.parseAddRoleStatement()
+	case TOTP:
+		return p.parseAddTOTPStatement()
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"KEY", "PERMISSIONS", "ROLE", "TOTP"}, pos)
+	}
+}
+
+// parseAddKeyStatement parses a string and returns an AddKeyStatement.
+//
+//	ADD KEY 'key' TO USER user [WITH option = value, ...]
+//
+// This function assumes the "ADD KEY" tokens have already been consumed.
+func (p *Parser) parseAddKeyStatement() (*AddKeyStatement, error) {
+	stmt := &AddKeyStatement{}
+
+	key, err := p.parseString()
+	if err != nil {
+		return nil, err
+	}
+	stmt.key = key
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	if stmt.name, err = p.parseUsername(); err != nil {
+		return nil, err
+	}
+
+	// Parse optional attributes
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != WITH {
+		p.unscan()
+		return stmt, nil
+	}
+	if stmt.options, err = p.parseKeyOptions(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseKeyOptions parses a comma delimited list of key attributes. Keys expire at an RFC 3339 timestamp or after
+// a duration such as '720h'.
+//
+//	comment = 'string', expires = 'timestamp|duration', namespaces = (namespace, ...), read_only = true|false
+//
+// This function assumes the "WITH" token has already been consumed.
+func (p *Parser) parseKeyOptions() (KeyOptions, error) {
+	var options KeyOptions
 	for {
-		tok, pos, lit = p.scan()
-		if tok == lexer.DOT {
-			namespace += "."
-			endPeriod = true
-		} else if tok == lexer.IDENT {
-			namespace += lit
-			endPeriod = false
-		} else {
-			break
+
+		// NAMESPACES is a keyword so it is not scanned as an identifier
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		option := strings.ToLower(lit)
+		if tok == NAMESPACES {
+			option = "namespaces"
+		} else if tok != lexer.IDENT {
+			option = ""
+		}
+
+		switch option {
+		case "comment", "expires", "namespaces", "read_only":
+		default:
+			return options, newParseError(tokstr(tok, lit), []string{"COMMENT", "EXPIRES", "NAMESPACES", "READ_ONLY"}, pos)
+		}
+
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.EQ {
+			return options, newParseError(tokstr(tok, lit), []string{"="}, pos)
+		}
+
+		// Parse value
+		switch option {
+		case "comment":
+			comment, err := p.parseString()
+			if err != nil {
+				return options, err
+			}
+			options.Comment = comment
+		case "expires":
+			tok, pos, lit := p.scanIgnoreWhitespace()
+			if tok != lexer.STRING {
+				return options, newParseError(tokstr(tok, lit), []string{"string"}, pos)
+			}
+			if expires, err := time.Parse(time.RFC3339Nano, lit); err == nil {
+				options.Expires, options.ValidFor = expires, 0
+			} else if duration, err := time.ParseDuration(lit); err == nil && duration > 0 {
+				options.Expires, options.ValidFor = time.Time{}, duration
+			} else {
+				return options, newParseError(lit, []string{"timestamp", "duration"}, pos)
+			}
+		case "namespaces":
+			namespaces, err := p.parseNamespaceList()
+			if err != nil {
+				return options, err
+			}
+			options.Namespaces = namespaces
+		case "read_only":
+			tok, pos, lit := p.scanIgnoreWhitespace()
+			if tok != lexer.TRUE && tok != lexer.FALSE {
+				return options, newParseError(tokstr(tok, lit), []string{"TRUE", "FALSE"}, pos)
+			}
+			options.ReadOnly = tok == lexer.TRUE
+		}
+
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.COMMA {
+			p.unscan()
+			return options, nil
 		}
 	}
+}
 
-	// remove last token
-	p.unscan()
+// parseNamespaceList parses a parenthesized, comma delimited list of namespaces.
+func (p *Parser) parseNamespaceList() ([]string, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
 
-	// Namespaces can't end on a period
-	if endPeriod {
-		return "", newParseError(tokstr(tok, lit), []string{"identifier"}, pos)
+	var namespaces []string
+	for {
+		namespace, err := p.parseNamespace()
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok == lexer.RPAREN {
+			return namespaces, nil
+		} else if tok != lexer.COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+		}
 	}
-	return namespace, nil
 }
 
-// parserString parses a string.
-func (p *Parser) parseString() (string, error) {
+// parseRemoveStatement parses a string and returns a Statement AST object.
+// This function assumes the "REMOVE" token has already been consumed.
+func (p *Parser) parseRemoveStatement() (Statement, error) {
+
+	// Inspect the first token.
 	tok, pos, lit := p.scanIgnoreWhitespace()
-	if tok != STRING {
-		return "", newParseError(tokstr(tok, lit), []string{"string"}, pos)
+	switch tok {
+	case KEY:
+		return p.parseRemoveKeyStatement()
+	case PERMISSION, PERMISSIONS:
+		return p.parseRemovePermissionsStatement()
+	case ROLE:
+		return p.parseRemoveRoleStatement()
+	case TOTP:
+		return p.parseRemoveTOTPStatement()
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"KEY", "PERMISSIONS", "ROLE", "TOTP"}, pos)
+	}
+}
+
+// parseRemoveKeyStatement parses a string and returns a RemoveKeyStatement.
+//
+//	REMOVE KEY 'fingerprint' FROM USER user
+//
+// This function assumes the "REMOVE KEY" tokens have already been consumed.
+func (p *Parser) parseRemoveKeyStatement() (*RemoveKeyStatement, error) {
+	stmt := &RemoveKeyStatement{}
+
+	fingerprint, err := p.parseString()
+	if err != nil {
+		return nil, err
+	}
+	stmt.fingerprint = fingerprint
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	if stmt.name, err = p.parseUsername(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseAddTOTPStatement parses a string and returns an AddTOTPStatement.
+//
+//	ADD TOTP TO USER user
+//
+// This function assumes the "ADD TOTP" tokens have already been consumed.
+func (p *Parser) parseAddTOTPStatement() (*AddTOTPStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &AddTOTPStatement{name: name}, nil
+}
+
+// parseRemoveTOTPStatement parses a string and returns a RemoveTOTPStatement.
+//
+//	REMOVE TOTP FROM USER user
+//
+// This function assumes the "REMOVE TOTP" tokens have already been consumed.
+func (p *Parser) parseRemoveTOTPStatement() (*RemoveTOTPStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveTOTPStatement{name: name}, nil
+}
+
+// parseCreateRoleStatement parses a string and returns a CreateRoleStatement.
+//
+//	CREATE ROLE role ON namespace
+//
+// This function assumes the "CREATE ROLE" tokens have already been consumed.
+func (p *Parser) parseCreateRoleStatement() (*CreateRoleStatement, error) {
+	stmt := &CreateRoleStatement{}
+
+	role, err := p.parseRole()
+	if err != nil {
+		return nil, err
+	}
+	stmt.role = role
+
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseAddPermissionsStatement parses a string and returns an AddPermissionsStatement.
+//
+//	ADD PERMISSIONS permission, ... TO ROLE role ON namespace
+//
+// This function assumes the "ADD PERMISSIONS" tokens have already been consumed.
+func (p *Parser) parseAddPermissionsStatement() (*AddPermissionsStatement, error) {
+	stmt := &AddPermissionsStatement{}
+
+	permissions, err := p.parsePermissionList()
+	if err != nil {
+		return nil, err
+	}
+	stmt.permissions = permissions
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ROLE {
+		return nil, newParseError(tokstr(tok, lit), []string{"ROLE"}, pos)
+	}
+
+	if stmt.role, err = p.parseRole(); err != nil {
+		return nil, err
+	}
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseRemovePermissionsStatement parses a string and returns a RemovePermissionsStatement.
+//
+//	REMOVE PERMISSION permission, ... FROM ROLE role ON namespace
+//
+// This function assumes the "REMOVE PERMISSION" tokens have already been consumed.
+func (p *Parser) parseRemovePermissionsStatement() (*RemovePermissionsStatement, error) {
+	stmt := &RemovePermissionsStatement{}
+
+	permissions, err := p.parsePermissionList()
+	if err != nil {
+		return nil, err
+	}
+	stmt.permissions = permissions
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ROLE {
+		return nil, newParseError(tokstr(tok, lit), []string{"ROLE"}, pos)
+	}
+
+	if stmt.role, err = p.parseRole(); err != nil {
+		return nil, err
+	}
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseAddRoleStatement parses a string and returns an AddRoleStatement.
+//
+//	ADD ROLE role TO USER user ON namespace
+//
+// This function assumes the "ADD ROLE" tokens have already been consumed.
+func (p *Parser) parseAddRoleStatement() (*AddRoleStatement, error) {
+	stmt := &AddRoleStatement{}
+
+	role, err := p.parseRole()
+	if err != nil {
+		return nil, err
+	}
+	stmt.role = role
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != TO {
+		return nil, newParseError(tokstr(tok, lit), []string{"TO"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	if stmt.user, err = p.parseUsername(); err != nil {
+		return nil, err
+	}
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseRemoveRoleStatement parses a string and returns a RemoveRoleStatement.
+//
+//	REMOVE ROLE role FROM USER user ON namespace
+//
+// This function assumes the "REMOVE ROLE" tokens have already been consumed.
+func (p *Parser) parseRemoveRoleStatement() (*RemoveRoleStatement, error) {
+	stmt := &RemoveRoleStatement{}
+
+	role, err := p.parseRole()
+	if err != nil {
+		return nil, err
+	}
+	stmt.role = role
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	if stmt.user, err = p.parseUsername(); err != nil {
+		return nil, err
+	}
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseShowStatement parses a string and returns a Statement AST object.
+// This function assumes the "SHOW" token has already been consumed.
+func (p *Parser) parseShowStatement() (Statement, error) {
+
+	// Inspect the first token.
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case NAMESPACES:
+		return &ShowNamespacesStatement{}, nil
+	case USERS:
+		return &ShowUsersStatement{}, nil
+	case ROLES:
+		return p.parseShowRolesStatement()
+	case PERMISSIONS:
+		return p.parseShowPermissionsStatement()
+	case LOGS:
+		return &ShowLogsStatement{}, nil
+	case VIEWS:
+		return &ShowViewsStatement{}, nil
+	case TYPES:
+		return &ShowTypesStatement{}, nil
+	case KEYS:
+		return p.parseShowKeysStatement()
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACES", "USERS", "ROLES", "PERMISSIONS", "LOGS", "VIEWS", "TYPES", "KEYS"}, pos)
+	}
+}
+
+// parseShowRolesStatement parses a string and returns a ShowRolesStatement.
+//
+//	SHOW ROLES [ON namespace]
+//
+// This function assumes the "SHOW ROLES" tokens have already been consumed.
+func (p *Parser) parseShowRolesStatement() (*ShowRolesStatement, error) {
+	stmt := &ShowRolesStatement{}
+
+	// Parse optional namespace
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != ON {
+		p.unscan()
+		return stmt, nil
+	}
+
+	namespace, err := p.parseNamespace()
+	if err != nil {
+		return nil, err
+	}
+	stmt.namespace = namespace
+	return stmt, nil
+}
+
+// parseShowPermissionsStatement parses a string and returns a ShowPermissionsStatement.
+//
+//	SHOW PERMISSIONS FOR ROLE role ON namespace
+//
+// This function assumes the "SHOW PERMISSIONS" tokens have already been consumed.
+func (p *Parser) parseShowPermissionsStatement() (*ShowPermissionsStatement, error) {
+	stmt := &ShowPermissionsStatement{}
+
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FOR {
+		return nil, newParseError(tokstr(tok, lit), []string{"FOR"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ROLE {
+		return nil, newParseError(tokstr(tok, lit), []string{"ROLE"}, pos)
+	}
+
+	role, err := p.parseRole()
+	if err != nil {
+		return nil, err
+	}
+	stmt.role = role
+
+	if stmt.namespace, err = p.parseOnNamespace(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseShowKeysStatement parses a string and returns a ShowKeysStatement.
+//
+//	SHOW KEYS FOR USER user
+//
+// This function assumes the "SHOW KEYS" tokens have already been consumed.
+func (p *Parser) parseShowKeysStatement() (*ShowKeysStatement, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FOR {
+		return nil, newParseError(tokstr(tok, lit), []string{"FOR"}, pos)
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != USER {
+		return nil, newParseError(tokstr(tok, lit), []string{"USER"}, pos)
+	}
+
+	name, err := p.parseUsername()
+	if err != nil {
+		return nil, err
+	}
+	return &ShowKeysStatement{name: name}, nil
+}
+
+// parseDescribeStatement parses a string and returns a Statement AST object.
+// This function assumes the "DESCRIBE" token has already been consumed.
+func (p *Parser) parseDescribeStatement() (Statement, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case NAMESPACE:
+		name, err := p.parseNamespace()
+		if err != nil {
+			return nil, err
+		}
+		return &DescribeNamespaceStatement{name: name}, nil
+	case TYPE:
+		name, err := p.parseQualifiedName("type name")
+		if err != nil {
+			return nil, err
+		}
+		return &DescribeTypeStatement{name: name}, nil
+	case LOG:
+		name, err := p.parseQualifiedName("log name")
+		if err != nil {
+			return nil, err
+		}
+		return &DescribeLogStatement{name: name}, nil
+	case VIEW:
+		name, err := p.parseQualifiedName("view name")
+		if err != nil {
+			return nil, err
+		}
+		return &DescribeViewStatement{name: name}, nil
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"NAMESPACE", "TYPE", "LOG", "VIEW"}, pos)
+	}
+}
+
+// parseInsertStatement parses a string and returns an InsertStatement.
+// Records are either a column list followed by VALUES or a list of object literals.
+// This function assumes the "INSERT" token has already been consumed.
+func (p *Parser) parseInsertStatement() (*InsertStatement, error) {
+	stmt := &InsertStatement{}
+
+	// Parse INTO
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != INTO {
+		return nil, newParseError(tokstr(tok, lit), []string{"INTO"}, pos)
+	}
+
+	// Parse the log name
+	lit, err := p.parseQualifiedName("log name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.log = lit
+
+	// Determine the record syntax
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case lexer.LPAREN:
+		p.unscan()
+		stmt.records, err = p.parseValuesList()
+	case lexer.LCURLY:
+		p.unscan()
+		stmt.records, err = p.parseObjectList()
+	default:
+		return nil, newParseError(tokstr(tok, lit), []string{"(", "{"}, pos)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseValuesList parses a column list followed by one or more value lists.
+//
+//	(field, ...) VALUES (value, ...), ...
+func (p *Parser) parseValuesList() ([]RecordLiteral, error) {
+
+	// Parse column names
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
+
+	var columns []string
+	for {
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != lexer.IDENT {
+			return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos)
+		}
+
+		// Field names must be unique
+		for _, c := range columns {
+			if c == lit {
+				return nil, &ParseError{Message: fmt.Sprintf("duplicate field '%s'", lit), Pos: pos}
+			}
+		}
+		columns = append(columns, lit)
+
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok == lexer.RPAREN {
+			break
+		} else if tok != lexer.COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+		}
+	}
+
+	// Parse VALUES
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != VALUES {
+		return nil, newParseError(tokstr(tok, lit), []string{"VALUES"}, pos)
+	}
+
+	// Parse each value list
+	var records []RecordLiteral
+	for {
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != lexer.LPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+		}
+
+		var record RecordLiteral
+		for {
+			value, pos, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			} else if len(record) == len(columns) {
+				return nil, &ParseError{Message: fmt.Sprintf("expected %d values", len(columns)), Pos: pos}
+			}
+			record = append(record, Field{columns[len(record)], value})
+
+			if tok, pos, lit := p.scanIgnoreWhitespace(); tok == lexer.RPAREN {
+				break
+			} else if tok != lexer.COMMA {
+				return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+			}
+		}
+
+		// Every column requires a value
+		if len(record) != len(columns) {
+			return nil, &ParseError{Message: fmt.Sprintf("expected %d values, found %d", len(columns), len(record)), Pos: pos}
+		}
+		records = append(records, record)
+
+		// Continue if there is another value list
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.COMMA {
+			p.unscan()
+			return records, nil
+		}
+	}
+}
+
+// parseObjectList parses one or more JSON style object literals.
+//
+//	{"field": value, ...}, ...
+//
+// Field names may be identifiers or quoted strings.
+func (p *Parser) parseObjectList() ([]RecordLiteral, error) {
+	var records []RecordLiteral
+	for {
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != lexer.LCURLY {
+			return nil, newParseError(tokstr(tok, lit), []string{"{"}, pos)
+		}
+
+		var record RecordLiteral
+		for {
+
+			// Parse field name
+			tok, pos, lit := p.scanIgnoreWhitespace()
+			if tok != lexer.IDENT && tok != lexer.STRING {
+				return nil, newParseError(tokstr(tok, lit), []string{"field"}, pos)
+			}
+
+			// Field names must be unique
+			for _, f := range record {
+				if f.Name == lit {
+					return nil, &ParseError{Message: fmt.Sprintf("duplicate field '%s'", lit), Pos: pos}
+				}
+			}
+			name := lit
+
+			if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.COLON {
+				return nil, newParseError(tokstr(tok, lit), []string{":"}, pos)
+			}
+
+			// Double quoted strings are scanned as identifiers, so they are accepted as values here
+			var value interface{}
+			if tok, _, lit := p.scanIgnoreWhitespace(); tok == lexer.IDENT {
+				value = lit
+			} else {
+				p.unscan()
+				v, _, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				value = v
+			}
+			record = append(record, Field{name, value})
+
+			if tok, pos, lit := p.scanIgnoreWhitespace(); tok == lexer.RCURLY {
+				break
+			} else if tok != lexer.COMMA {
+				return nil, newParseError(tokstr(tok, lit), []string{",", "}"}, pos)
+			}
+		}
+		records = append(records, record)
+
+		// Continue if there is another object
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.COMMA {
+			p.unscan()
+			return records, nil
+		}
+	}
+}
+
+// parseValue parses a string, number or boolean literal
+func (p *Parser) parseValue() (interface{}, lexer.Pos, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case lexer.STRING:
+		return lit, pos, nil
+	case lexer.TRUE:
+		return true, pos, nil
+	case lexer.FALSE:
+		return false, pos, nil
+	case lexer.NUMBER:
+		n, err := parseNumber(lit, pos)
+		return n, pos, err
+	}
+	return nil, pos, newParseError(tokstr(tok, lit), []string{"string", "number", "boolean"}, pos)
+}
+
+// parseNumber converts a number literal into an int64, a uint64 if it is too large for an int64, or a float64 if
+// it has a fractional part.
+func parseNumber(lit string, pos lexer.Pos) (interface{}, error) {
+
+	// Numbers with a fractional part are floats
+	if strings.Contains(lit, ".") {
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, &ParseError{Message: err.Error(), Pos: pos}
+		}
+		return f, nil
+	}
+
+	// Integers which are too large for an int64 are unsigned
+	if n, err := strconv.ParseInt(lit, 10, 64); err == nil {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(lit, 10, 64)
+	if err != nil {
+		return nil, &ParseError{Message: err.Error(), Pos: pos}
+	}
+	return n, nil
+}
+
+// parseSelectStatement parses a string and returns a SelectStatement.
+//
+//	SELECT (* | field, ...) FROM source [WHERE expr] [LIMIT n] [OFFSET n]
+//
+// This function assumes the "SELECT" token has already been consumed.
+func (p *Parser) parseSelectStatement() (*SelectStatement, error) {
+	stmt := &SelectStatement{}
+
+	// Parse field list
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.MUL {
+		p.unscan()
+		for {
+			tok, pos, lit := p.scanIgnoreWhitespace()
+			if tok != lexer.IDENT {
+				return nil, newParseError(tokstr(tok, lit), []string{"field", "*"}, pos)
+			}
+			stmt.fields = append(stmt.fields, lit)
+
+			if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	}
+
+	// Parse FROM
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != FROM {
+		return nil, newParseError(tokstr(tok, lit), []string{"FROM"}, pos)
+	}
+
+	lit, err := p.parseQualifiedName("log or view name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.source = lit
+
+	// Parse optional WHERE clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == WHERE {
+		if stmt.condition, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse optional LIMIT clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == LIMIT {
+		if stmt.limit, err = p.parseUInt64(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+
+	// Parse optional OFFSET clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == OFFSET {
+		if stmt.offset, err = p.parseUInt64(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+	return stmt, nil
+}
+
+// parseCreateViewStatement parses a string and returns a CreateViewStatement.
+//
+//	CREATE VIEW name AS SELECT ... FROM log [WHERE expr] [CLUSTERED BY field]
+//
+// Views are maintained incrementally so the query may not have a LIMIT or OFFSET.
+// This function assumes the "CREATE VIEW" tokens have already been consumed.
+func (p *Parser) parseCreateViewStatement() (*CreateViewStatement, error) {
+	stmt := &CreateViewStatement{}
+
+	// Parse the name of the view to be created
+	lit, err := p.parseQualifiedName("view name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.name = lit
+
+	// Parse AS SELECT
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != AS {
+		return nil, newParseError(tokstr(tok, lit), []string{"AS"}, pos)
+	}
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != SELECT {
+		return nil, newParseError(tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+
+	if stmt.query, err = p.parseSelectStatement(); err != nil {
+		return nil, err
+	}
+	if stmt.query.limit > 0 || stmt.query.offset > 0 {
+		return nil, &ParseError{Message: "views do not support LIMIT or OFFSET", Pos: pos}
+	}
+
+	// Parse optional CLUSTERED BY clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok != CLUSTERED {
+		p.unscan()
+		return stmt, nil
+	}
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != BY {
+		return nil, newParseError(tokstr(tok, lit), []string{"BY"}, pos)
+	}
+	if stmt.clusteredBy, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseSubscribeStatement parses a string and returns a SubscribeStatement.
+//
+//	SUBSCRIBE log [FROM OFFSET n] [WHERE expr]
+//
+// This function assumes the "SUBSCRIBE" token has already been consumed.
+func (p *Parser) parseSubscribeStatement() (*SubscribeStatement, error) {
+	stmt := &SubscribeStatement{}
+
+	lit, err := p.parseQualifiedName("log name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.log = lit
+
+	// Parse optional FROM OFFSET clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == FROM {
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != OFFSET {
+			return nil, newParseError(tokstr(tok, lit), []string{"OFFSET"}, pos)
+		}
+		if stmt.offset, err = p.parseUInt64(); err != nil {
+			return nil, err
+		}
+		stmt.hasOffset = true
+	} else {
+		p.unscan()
+	}
+
+	// Parse optional WHERE clause
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == WHERE {
+		if stmt.condition, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.unscan()
+	}
+	return stmt, nil
+}
+
+// parseUnsubscribeStatement parses a string and returns an UnsubscribeStatement.
+// The log name is optional.
+// This function assumes the "UNSUBSCRIBE" token has already been consumed.
+func (p *Parser) parseUnsubscribeStatement() (*UnsubscribeStatement, error) {
+	stmt := &UnsubscribeStatement{}
+
+	// The log name is optional at the end of the statement
+	tok, _, _ := p.scanIgnoreWhitespace()
+	p.unscan()
+	if tok == lexer.EOF || tok == lexer.SEMICOLON {
+		return stmt, nil
+	}
+
+	lit, err := p.parseQualifiedName("log name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.log = lit
+	return stmt, nil
+}
+
+// ParseExpr parses an expression. Binary operators are grouped by their precedence.
+func (p *Parser) ParseExpr() (Expr, error) {
+	return p.parseBinaryExpr(lexer.OR.Precedence())
+}
+
+// parseBinaryExpr parses an expression containing only binary operators with at least the given precedence.
+func (p *Parser) parseBinaryExpr(precedence int) (Expr, error) {
+	var err error
+
+	// Parse a non-binary expression type to start.
+	// This variable will always be the root of the expression tree.
+	root := &BinaryExpr{}
+	if root.RHS, err = p.parseUnaryExpr(); err != nil {
+		return nil, err
+	}
+
+	// Loop over operations and unary exprs and build a tree based on precedence.
+	for {
+		var rhs Expr
+
+		// If the next token is NOT an operator then return the expression.
+		op, pos, lit := p.scanIgnoreWhitespace()
+		if op == lexer.NUMBER && (lit[0] == '-' || lit[0] == '+') {
+
+			// The scanner reads a sign followed by a digit as a signed number
+			if lit[0] == '-' {
+				op = lexer.MINUS
+			} else {
+				op = lexer.PLUS
+			}
+			if op.Precedence() < precedence {
+				p.unscan()
+				return root.RHS, nil
+			}
+
+			n, err := parseNumber(lit[1:], pos)
+			if err != nil {
+				return nil, err
+			}
+			rhs = &NumberLiteral{Val: n}
+		} else if !isBinaryOperator(op) || op.Precedence() < precedence {
+			p.unscan()
+			return root.RHS, nil
+		} else if op == lexer.EQREGEX || op == lexer.NEQREGEX {
+
+			// Regular expression operators require a regex on the right
+			if rhs, err = p.parseRegex(); err != nil {
+				return nil, err
+			}
+		} else if rhs, err = p.parseUnaryExpr(); err != nil {
+			return nil, err
+		}
+
+		// Find the right spot in the tree to add the new expression by
+		// descending the RHS of the expression tree until we reach the last
+		// BinaryExpr or a BinaryExpr whose RHS has an operator with
+		// precedence >= the operator being added.
+		for node := root; ; {
+			r, ok := node.RHS.(*BinaryExpr)
+			if !ok || r.Op.Precedence() >= op.Precedence() {
+				// Add the new expression here and break.
+				node.RHS = &BinaryExpr{LHS: node.RHS, RHS: rhs, Op: op}
+				break
+			}
+			node = r
+		}
+	}
+}
+
+// parseUnaryExpr parses a non-binary expression.
+func (p *Parser) parseUnaryExpr() (Expr, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case lexer.LPAREN:
+
+		// Parse the parenthesized expression
+		expr, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		// Expect a closing parenthesis
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != lexer.RPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+		}
+		return &ParenExpr{Expr: expr}, nil
+	case lexer.MINUS:
+
+		// Negation binds tighter than any binary operator
+		expr, err := p.parseUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: lexer.MINUS, Expr: expr}, nil
+	case NOT:
+
+		// NOT applies to a whole comparison, so NOT a = 1 is NOT (a = 1)
+		expr, err := p.parseBinaryExpr(lexer.EQ.Precedence())
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: NOT, Expr: expr}, nil
+	case TIMESTAMP:
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		if tok != lexer.STRING {
+			return nil, newParseError(tokstr(tok, lit), []string{"string"}, pos)
+		}
+
+		ts, err := parseTimestamp(lit)
+		if err != nil {
+			return nil, &ParseError{Message: fmt.Sprintf("invalid timestamp '%s'", lit), Pos: pos}
+		}
+		return &TimestampLiteral{Val: ts}, nil
+	case lexer.IDENT:
+		return &VarRef{Val: lit}, nil
+	case lexer.STRING:
+		return &StringLiteral{Val: lit}, nil
+	case lexer.TRUE, lexer.FALSE:
+		return &BooleanLiteral{Val: tok == lexer.TRUE}, nil
+	case lexer.NUMBER:
+		n, err := parseNumber(lit, pos)
+		if err != nil {
+			return nil, err
+		}
+		return &NumberLiteral{Val: n}, nil
+	}
+	return nil, newParseError(tokstr(tok, lit), []string{"identifier", "string", "number", "boolean", "timestamp", "("}, pos)
+}
+
+// parseTimestamp parses a timestamp in RFC 3339, date time or date format. Timestamps without a zone are UTC.
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, DateTimeFormat, DateFormat} {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp '%s'", s)
+}
+
+// parseRegex parses a regular expression delimited by forward slashes.
+func (p *Parser) parseRegex() (*RegexLiteral, error) {
+
+	// Skip whitespace before the regex
+	if p.peekRune() == ' ' || p.peekRune() == '\t' || p.peekRune() == '\n' {
+		p.scan()
+	}
+
+	// Regular expressions must start with a forward slash
+	if p.peekRune() != '/' {
+		tok, pos, lit := p.scanIgnoreWhitespace()
+		return nil, newParseError(tokstr(tok, lit), []string{"regex"}, pos)
+	}
+
+	tok, pos, lit := p.s.ScanRegex()
+	if tok == lexer.BADESCAPE {
+		return nil, &ParseError{Message: "bad escape in regex", Pos: pos}
+	} else if tok != lexer.REGEX {
+		return nil, &ParseError{Message: "unterminated regex", Pos: pos}
+	}
+
+	re, err := regexp.Compile(lit)
+	if err != nil {
+		return nil, &ParseError{Message: err.Error(), Pos: pos}
+	}
+	return &RegexLiteral{Val: re}, nil
+}
+
+// isBinaryOperator determines if a token is an operator supported by expressions
+func isBinaryOperator(tok lexer.Token) bool {
+	switch tok {
+	case lexer.AND, lexer.OR, lexer.EQ, lexer.NEQ, lexer.EQREGEX, lexer.NEQREGEX, lexer.LT, lexer.LTE, lexer.GT,
+		lexer.GTE, lexer.PLUS, lexer.MINUS, lexer.MUL, lexer.DIV:
+		return true
+	}
+	return false
+}
+
+// parseNamespace returns a namespace title or an error
+func (p *Parser) parseNamespace() (string, error) {
+	return p.parseQualifiedName("namespace")
+}
+
+// parseQualifiedName returns a period delimited name such as a namespace or a log.
+// The expected argument describes the name in the error returned if one isn't found.
+func (p *Parser) parseQualifiedName(expected string) (string, error) {
+	var name string
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != lexer.IDENT {
+		return "", newParseError(tokstr(tok, lit), []string{expected}, pos)
+	}
+	name = lit
+
+	// Scan entire name
+	// Names are a period delimited list of identifiers
+	var endPeriod bool
+	for {
+		tok, pos, lit = p.scan()
+		if tok == lexer.DOT {
+			name += "."
+			endPeriod = true
+		} else if tok == lexer.IDENT {
+			name += lit
+			endPeriod = false
+		} else {
+			break
+		}
+	}
+
+	// remove last token
+	p.unscan()
+
+	// Names can't end on a period
+	if endPeriod {
+		return "", newParseError(tokstr(tok, lit), []string{"identifier"}, pos)
+	}
+	return name, nil
+}
+
+// parserString parses a string.
+func (p *Parser) parseString() (string, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != lexer.STRING {
+		return "", newParseError(tokstr(tok, lit), []string{"string"}, pos)
+	}
+	return lit, nil
+}
+
+// parseUsername parses a username. Usernames are identifiers and may be quoted.
+func (p *Parser) parseUsername() (string, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != lexer.IDENT {
+		return "", newParseError(tokstr(tok, lit), []string{"username"}, pos)
+	}
+	return lit, nil
+}
+
+// parseRole parses a role name. Role names are identifiers and may be quoted.
+func (p *Parser) parseRole() (string, error) {
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok != lexer.IDENT {
+		return "", newParseError(tokstr(tok, lit), []string{"role"}, pos)
+	}
+	return lit, nil
+}
+
+// parseOnNamespace parses the ON clause naming the namespace of a role
+func (p *Parser) parseOnNamespace() (string, error) {
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != ON {
+		return "", newParseError(tokstr(tok, lit), []string{"ON"}, pos)
+	}
+	return p.parseNamespace()
+}
+
+// parsePermissionList parses a comma delimited list of permissions
+func (p *Parser) parsePermissionList() ([]string, error) {
+	var permissions []string
+	for {
+		permission, err := p.parsePermission()
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != lexer.COMMA {
+			p.unscan()
+			return permissions, nil
+		}
+	}
+}
+
+// parsePermission parses a period delimited permission such as 'create.log'.
+// Keywords other than TO and FROM are allowed in permissions so 'select.log' and 'insert.log' can be named.
+// They are returned in lower case. The last part may be a '*' wildcard, as in 'create.*' or '*', and a leading
+// '-' denies the permission rather than granting it.
+func (p *Parser) parsePermission() (string, error) {
+	word := func(tok lexer.Token, lit string) (string, bool) {
+		if tok == lexer.IDENT {
+			return lit, true
+		} else if tok > startKeywords && tok < endKeywords && tok != TO && tok != FROM {
+			return strings.ToLower(tok.String()), true
+		}
+		return "", false
+	}
+
+	var permission string
+	tok, pos, lit := p.scanIgnoreWhitespace()
+	if tok == lexer.MINUS {
+		permission = "-"
+		tok, pos, lit = p.scan()
+	}
+
+	// Scan parts until a wildcard or a part without a trailing period
+	for {
+		if tok == lexer.MUL {
+			return permission + "*", nil
+		}
+
+		part, ok := word(tok, lit)
+		if !ok {
+			if permission == "" || permission == "-" {
+				return "", newParseError(tokstr(tok, lit), []string{"permission"}, pos)
+			}
+			return "", newParseError(tokstr(tok, lit), []string{"identifier"}, pos)
+		}
+		permission += part
+
+		if tok, _, _ = p.scan(); tok != lexer.DOT {
+			p.unscan()
+			return permission, nil
+		}
+		permission += "."
+		tok, pos, lit = p.scan()
 	}
-	return lit, nil
 }
 
 // parseIdent parses an identifier.
@@ -2,9 +2,12 @@ package skl
 
 import (
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/eliquious/lexer"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -53,7 +56,7 @@ func (suite *ParserTestSuite) TestInvalidStatement() {
 	var tests = []TestCase{
 
 		// Errors
-		{s: `a bad statement.`, err: `found a, expected USE, CREATE, SHOW, DROP at line 1, char 1`},
+		{s: `a bad statement.`, err: `found a, expected USE, CREATE, SHOW, DROP, INSERT, SELECT, SUBSCRIBE, UNSUBSCRIBE, SET, ADD, REMOVE, DESCRIBE, PROMOTE, DEMOTE at line 1, char 1`},
 	}
 
 	suite.validate(tests)
@@ -86,7 +89,7 @@ func (suite *ParserTestSuite) TestCreateNamespace() {
 		},
 
 		// Errors
-		{s: `CREATE `, err: `found EOF, expected NAMESPACE at line 1, char 9`},
+		{s: `CREATE `, err: `found EOF, expected NAMESPACE, LOG, TYPE, VIEW, USER, ROLE at line 1, char 9`},
 		{s: `CREATE NAMESPACE `, err: `found EOF, expected namespace at line 1, char 19`},
 		{s: `CREATE NAMESPACE acme.example.`, err: `found EOF, expected identifier at line 1, char 31`},
 		{s: `CREATE NAMESPACE acme.example. `, err: `found WS, expected identifier at line 1, char 31`},
@@ -96,6 +99,448 @@ func (suite *ParserTestSuite) TestCreateNamespace() {
 	suite.validate(tests)
 }
 
+// Ensure the parser can parse strings into CREATE LOG statements
+func (suite *ParserTestSuite) TestCreateLog() {
+	var tests = []TestCase{
+		{
+			s:    `CREATE LOG acme.events`,
+			stmt: &CreateLogStatement{name: "acme.events"},
+		},
+		{
+			s:    `CREATE LOG events`,
+			stmt: &CreateLogStatement{name: "events"},
+		},
+		{
+			s:    `CREATE LOG acme.events USING TYPE acme.Event`,
+			stmt: &CreateLogStatement{name: "acme.events", typeName: "acme.Event"},
+		},
+
+		// Errors
+		{s: `CREATE LOG `, err: `found EOF, expected log name at line 1, char 13`},
+		{s: `CREATE LOG acme.events.`, err: `found EOF, expected identifier at line 1, char 24`},
+		{s: `CREATE LOG .events`, err: `found ., expected log name at line 1, char 12`},
+		{s: `CREATE LOG acme.events USING acme.Event`, err: `found acme, expected TYPE at line 1, char 30`},
+		{s: `CREATE LOG acme.events USING TYPE`, err: `found EOF, expected type name at line 1, char 35`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into CREATE TYPE statements
+func (suite *ParserTestSuite) TestCreateType() {
+	var tests = []TestCase{
+		{
+			s: `CREATE TYPE acme.Event (id uint64 REQUIRED, name string OPTIONAL, at timestamp REQUIRED)`,
+			stmt: &CreateTypeStatement{name: "acme.Event", fields: []FieldDefinition{
+				{"id", "uint64", true},
+				{"name", "string", false},
+				{"at", "timestamp", true},
+			}},
+		},
+		{
+			s: `CREATE TYPE Event (score FLOAT64, active boolean, "type" int8)`,
+			stmt: &CreateTypeStatement{name: "Event", fields: []FieldDefinition{
+				{"score", "float64", false},
+				{"active", "boolean", false},
+				{"type", "int8", false},
+			}},
+		},
+
+		// Errors
+		{s: `CREATE TYPE `, err: `found EOF, expected type name at line 1, char 14`},
+		{s: `CREATE TYPE acme.Event`, err: `found EOF, expected ( at line 1, char 24`},
+		{s: `CREATE TYPE acme.Event ()`, err: `found ), expected field at line 1, char 25`},
+		{s: `CREATE TYPE acme.Event (id)`, err: `found ), expected type at line 1, char 27`},
+		{s: `CREATE TYPE acme.Event (id uint128)`, err: `found uint128, expected type at line 1, char 28`},
+		{s: `CREATE TYPE acme.Event (id uint64 REQUIRED name string)`, err: `found name, expected ,, ) at line 1, char 44`},
+		{s: `CREATE TYPE acme.Event (id uint64, id string)`, err: `duplicate field 'id' at line 1, char 36`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into SELECT statements
+func (suite *ParserTestSuite) TestSelect() {
+	var tests = []TestCase{
+		{
+			s:    `SELECT * FROM events`,
+			stmt: &SelectStatement{source: "events"},
+		},
+		{
+			s:    `SELECT id, name, _offset FROM acme.events LIMIT 10 OFFSET 5`,
+			stmt: &SelectStatement{fields: []string{"id", "name", "_offset"}, source: "acme.events", limit: 10, offset: 5},
+		},
+		{
+			s: `SELECT * FROM events WHERE id = 1`,
+			stmt: &SelectStatement{
+				source:    "events",
+				condition: &BinaryExpr{Op: lexer.EQ, LHS: &VarRef{"id"}, RHS: &NumberLiteral{int64(1)}},
+			},
+		},
+		{
+			s: `SELECT * FROM events WHERE name = 'login' OR id > 1 AND id <= 2.5`,
+			stmt: &SelectStatement{
+				source: "events",
+				condition: &BinaryExpr{
+					Op:  lexer.OR,
+					LHS: &BinaryExpr{Op: lexer.EQ, LHS: &VarRef{"name"}, RHS: &StringLiteral{"login"}},
+					RHS: &BinaryExpr{
+						Op:  lexer.AND,
+						LHS: &BinaryExpr{Op: lexer.GT, LHS: &VarRef{"id"}, RHS: &NumberLiteral{int64(1)}},
+						RHS: &BinaryExpr{Op: lexer.LTE, LHS: &VarRef{"id"}, RHS: &NumberLiteral{2.5}},
+					},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM events WHERE (active = true OR id != 1) AND name !~ /^test/ LIMIT 1`,
+			stmt: &SelectStatement{
+				source: "events",
+				condition: &BinaryExpr{
+					Op: lexer.AND,
+					LHS: &ParenExpr{&BinaryExpr{
+						Op:  lexer.OR,
+						LHS: &BinaryExpr{Op: lexer.EQ, LHS: &VarRef{"active"}, RHS: &BooleanLiteral{true}},
+						RHS: &BinaryExpr{Op: lexer.NEQ, LHS: &VarRef{"id"}, RHS: &NumberLiteral{int64(1)}},
+					}},
+					RHS: &BinaryExpr{Op: lexer.NEQREGEX, LHS: &VarRef{"name"}, RHS: &RegexLiteral{regexp.MustCompile("^test")}},
+				},
+				limit: 1,
+			},
+		},
+		{
+			s: `SELECT * FROM events WHERE a + b * 2 > c-1`,
+			stmt: &SelectStatement{
+				source: "events",
+				condition: &BinaryExpr{
+					Op: lexer.GT,
+					LHS: &BinaryExpr{
+						Op:  lexer.PLUS,
+						LHS: &VarRef{"a"},
+						RHS: &BinaryExpr{Op: lexer.MUL, LHS: &VarRef{"b"}, RHS: &NumberLiteral{int64(2)}},
+					},
+					RHS: &BinaryExpr{Op: lexer.MINUS, LHS: &VarRef{"c"}, RHS: &NumberLiteral{int64(1)}},
+				},
+			},
+		},
+
+		{
+			s: `SELECT * FROM events WHERE NOT active = true AND -score < -1.5`,
+			stmt: &SelectStatement{
+				source: "events",
+				condition: &BinaryExpr{
+					Op: lexer.AND,
+					LHS: &UnaryExpr{Op: NOT, Expr: &BinaryExpr{
+						Op: lexer.EQ, LHS: &VarRef{"active"}, RHS: &BooleanLiteral{true},
+					}},
+					RHS: &BinaryExpr{
+						Op:  lexer.LT,
+						LHS: &UnaryExpr{Op: lexer.MINUS, Expr: &VarRef{"score"}},
+						RHS: &NumberLiteral{-1.5},
+					},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM events WHERE at >= TIMESTAMP '2015-06-01' AND _timestamp < timestamp '2015-06-01 12:30:00'`,
+			stmt: &SelectStatement{
+				source: "events",
+				condition: &BinaryExpr{
+					Op: lexer.AND,
+					LHS: &BinaryExpr{
+						Op:  lexer.GTE,
+						LHS: &VarRef{"at"},
+						RHS: &TimestampLiteral{time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)},
+					},
+					RHS: &BinaryExpr{
+						Op:  lexer.LT,
+						LHS: &VarRef{"_timestamp"},
+						RHS: &TimestampLiteral{time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC)},
+					},
+				},
+			},
+		},
+
+		// Errors
+		{s: `SELECT`, err: `found EOF, expected field, * at line 1, char 8`},
+		{s: `SELECT id,`, err: `found EOF, expected field, * at line 1, char 11`},
+		{s: `SELECT id events`, err: `found events, expected FROM at line 1, char 11`},
+		{s: `SELECT * FROM`, err: `found EOF, expected log or view name at line 1, char 15`},
+		{s: `SELECT * FROM events WHERE`, err: `found EOF, expected identifier, string, number, boolean, timestamp, ( at line 1, char 28`},
+		{s: `SELECT * FROM events WHERE (id = 1`, err: `found EOF, expected ) at line 1, char 35`},
+		{s: `SELECT * FROM events WHERE name =~ 'login'`, err: `found login, expected regex at line 1, char 35`},
+		{s: `SELECT * FROM events WHERE name =~ /(/`, err: "error parsing regexp: missing closing ): `(` at line 1, char 35"},
+		{s: `SELECT * FROM events WHERE at > TIMESTAMP 1`, err: `found 1, expected string at line 1, char 43`},
+		{s: `SELECT * FROM events WHERE at > TIMESTAMP 'yesterday'`, err: `invalid timestamp 'yesterday' at line 1, char 42`},
+		{s: `SELECT * FROM events LIMIT x`, err: `found x, expected number at line 1, char 28`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into CREATE VIEW statements
+func (suite *ParserTestSuite) TestCreateView() {
+	var tests = []TestCase{
+		{
+			s:    `CREATE VIEW acme.all AS SELECT * FROM acme.events`,
+			stmt: &CreateViewStatement{name: "acme.all", query: &SelectStatement{source: "acme.events"}},
+		},
+		{
+			s: `CREATE VIEW sessions AS SELECT account, at FROM events WHERE name = 'login' CLUSTERED BY account`,
+			stmt: &CreateViewStatement{
+				name: "sessions",
+				query: &SelectStatement{
+					fields:    []string{"account", "at"},
+					source:    "events",
+					condition: &BinaryExpr{Op: lexer.EQ, LHS: &VarRef{"name"}, RHS: &StringLiteral{"login"}},
+				},
+				clusteredBy: "account",
+			},
+		},
+
+		// Errors
+		{s: `CREATE VIEW`, err: `found EOF, expected view name at line 1, char 13`},
+		{s: `CREATE VIEW v SELECT`, err: `found SELECT, expected AS at line 1, char 15`},
+		{s: `CREATE VIEW v AS events`, err: `found events, expected SELECT at line 1, char 18`},
+		{s: `CREATE VIEW v AS SELECT * FROM events LIMIT 1`, err: `views do not support LIMIT or OFFSET at line 1, char 18`},
+		{s: `CREATE VIEW v AS SELECT * FROM events CLUSTERED account`, err: `found account, expected BY at line 1, char 49`},
+		{s: `CREATE VIEW v AS SELECT * FROM events CLUSTERED BY`, err: `found EOF, expected identifier at line 1, char 52`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse user management statements
+func (suite *ParserTestSuite) TestUserStatements() {
+	var tests = []TestCase{
+		{
+			s:    `CREATE USER bob`,
+			stmt: &CreateUserStatement{name: "bob"},
+		},
+		{
+			s:    `DROP USER "acme.bob"`,
+			stmt: &DropUserStatement{name: "acme.bob"},
+		},
+		{
+			s:    `PROMOTE USER alice`,
+			stmt: &PromoteUserStatement{name: "alice"},
+		},
+		{
+			s:    `DEMOTE USER "acme.alice"`,
+			stmt: &DemoteUserStatement{name: "acme.alice"},
+		},
+		{
+			s:    `SET PASSWORD FOR bob = 's3cr3t'`,
+			stmt: &SetPasswordStatement{name: "bob", password: "s3cr3t"},
+		},
+		{
+			s:    `ADD KEY '-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----' TO USER bob`,
+			stmt: &AddKeyStatement{name: "bob", key: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"},
+		},
+		{
+			s: `ADD KEY 'pem' TO USER ci WITH comment = 'nightly build', expires = '2026-12-31T00:00:00Z', ` +
+				`namespaces = (acme.metrics, acme.events), read_only = true`,
+			stmt: &AddKeyStatement{name: "ci", key: "pem", options: KeyOptions{
+				Comment:    "nightly build",
+				Expires:    time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+				Namespaces: []string{"acme.metrics", "acme.events"},
+				ReadOnly:   true,
+			}},
+		},
+		{
+			s:    `ADD KEY 'pem' TO USER ci WITH EXPIRES = '720h', READ_ONLY = false`,
+			stmt: &AddKeyStatement{name: "ci", key: "pem", options: KeyOptions{ValidFor: 720 * time.Hour}},
+		},
+		{
+			s:    `SHOW KEYS FOR USER ci`,
+			stmt: &ShowKeysStatement{name: "ci"},
+		},
+		{
+			s:    `REMOVE KEY 'ab:cd' FROM USER bob`,
+			stmt: &RemoveKeyStatement{name: "bob", fingerprint: "ab:cd"},
+		},
+		{
+			s:    `ADD TOTP TO USER analyst`,
+			stmt: &AddTOTPStatement{name: "analyst"},
+		},
+		{
+			s:    `REMOVE TOTP FROM USER analyst`,
+			stmt: &RemoveTOTPStatement{name: "analyst"},
+		},
+
+		// Errors
+		{s: `CREATE USER`, err: `found EOF, expected username at line 1, char 13`},
+		{s: `DROP USER 'bob'`, err: `found bob, expected username at line 1, char 10`},
+		{s: `PROMOTE alice`, err: `found alice, expected USER at line 1, char 9`},
+		{s: `DEMOTE USER`, err: `found EOF, expected username at line 1, char 13`},
+		{s: `SET bob`, err: `found bob, expected PASSWORD, FORMAT, ON_ERROR at line 1, char 5`},
+		{s: `SET PASSWORD bob`, err: `found bob, expected FOR at line 1, char 14`},
+		{s: `SET PASSWORD FOR bob 'x'`, err: `found x, expected = at line 1, char 21`},
+		{s: `SET PASSWORD FOR bob = x`, err: `found x, expected string at line 1, char 24`},
+		{s: `ADD bob`, err: `found bob, expected KEY, PERMISSIONS, ROLE, TOTP at line 1, char 5`},
+		{s: `ADD KEY bob`, err: `found bob, expected string at line 1, char 9`},
+		{s: `ADD KEY 'pem' TO USER ci WITH`, err: `found EOF, expected COMMENT, EXPIRES, NAMESPACES, READ_ONLY at line 1, char 31`},
+		{s: `ADD KEY 'pem' TO USER ci WITH owner = 'x'`, err: `found owner, expected COMMENT, EXPIRES, NAMESPACES, READ_ONLY at line 1, char 31`},
+		{s: `ADD KEY 'pem' TO USER ci WITH comment 'x'`, err: `found x, expected = at line 1, char 38`},
+		{s: `ADD KEY 'pem' TO USER ci WITH expires = 'soon'`, err: `found soon, expected timestamp, duration at line 1, char 40`},
+		{s: `ADD KEY 'pem' TO USER ci WITH expires = '-1h'`, err: `found -1h, expected timestamp, duration at line 1, char 40`},
+		{s: `ADD KEY 'pem' TO USER ci WITH namespaces = acme`, err: `found acme, expected ( at line 1, char 44`},
+		{s: `ADD KEY 'pem' TO USER ci WITH namespaces = (acme acme.events)`, err: `found acme, expected ,, ) at line 1, char 50`},
+		{s: `ADD KEY 'pem' TO USER ci WITH read_only = 'yes'`, err: `found yes, expected TRUE, FALSE at line 1, char 42`},
+		{s: `SHOW KEYS USER ci`, err: `found USER, expected FOR at line 1, char 11`},
+		{s: `SHOW KEYS FOR ci`, err: `found ci, expected USER at line 1, char 15`},
+		{s: `ADD KEY 'x' USER bob`, err: `found USER, expected TO at line 1, char 13`},
+		{s: `ADD KEY 'x' TO bob`, err: `found bob, expected USER at line 1, char 16`},
+		{s: `REMOVE bob`, err: `found bob, expected KEY, PERMISSIONS, ROLE, TOTP at line 1, char 8`},
+		{s: `REMOVE KEY 'x' TO USER bob`, err: `found TO, expected FROM at line 1, char 16`},
+		{s: `REMOVE KEY 'x' FROM bob`, err: `found bob, expected USER at line 1, char 21`},
+		{s: `ADD TOTP USER bob`, err: `found USER, expected TO at line 1, char 10`},
+		{s: `ADD TOTP TO bob`, err: `found bob, expected USER at line 1, char 13`},
+		{s: `REMOVE TOTP TO USER bob`, err: `found TO, expected FROM at line 1, char 13`},
+		{s: `REMOVE TOTP FROM analyst`, err: `found analyst, expected USER at line 1, char 18`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse role and permission statements
+func (suite *ParserTestSuite) TestRoleStatements() {
+	var tests = []TestCase{
+		{
+			s:    `CREATE ROLE analyst ON acme.metrics`,
+			stmt: &CreateRoleStatement{role: "analyst", namespace: "acme.metrics"},
+		},
+		{
+			s:    `ADD PERMISSIONS create.namespace, select, insert.log TO ROLE analyst ON acme`,
+			stmt: &AddPermissionsStatement{permissions: []string{"create.namespace", "select", "insert.log"}, role: "analyst", namespace: "acme"},
+		},
+		{
+			s:    `ADD PERMISSION subscribe.log TO ROLE analyst ON acme`,
+			stmt: &AddPermissionsStatement{permissions: []string{"subscribe.log"}, role: "analyst", namespace: "acme"},
+		},
+		{
+			s:    `ADD PERMISSIONS select.*, -insert.log, * TO ROLE analyst ON acme`,
+			stmt: &AddPermissionsStatement{permissions: []string{"select.*", "-insert.log", "*"}, role: "analyst", namespace: "acme"},
+		},
+		{
+			s:    `REMOVE PERMISSIONS -drop.*, create.log FROM ROLE analyst ON acme`,
+			stmt: &RemovePermissionsStatement{permissions: []string{"-drop.*", "create.log"}, role: "analyst", namespace: "acme"},
+		},
+		{
+			s:    `REMOVE PERMISSION create.log FROM ROLE analyst ON acme`,
+			stmt: &RemovePermissionsStatement{permissions: []string{"create.log"}, role: "analyst", namespace: "acme"},
+		},
+		{
+			s:    `ADD ROLE analyst TO USER bob ON acme`,
+			stmt: &AddRoleStatement{role: "analyst", user: "bob", namespace: "acme"},
+		},
+		{
+			s:    `REMOVE ROLE analyst FROM USER bob ON acme`,
+			stmt: &RemoveRoleStatement{role: "analyst", user: "bob", namespace: "acme"},
+		},
+
+		// Errors
+		{s: `CREATE ROLE`, err: `found EOF, expected role at line 1, char 13`},
+		{s: `CREATE ROLE analyst acme`, err: `found acme, expected ON at line 1, char 21`},
+		{s: `CREATE ROLE analyst ON`, err: `found EOF, expected namespace at line 1, char 24`},
+		{s: `ADD PERMISSIONS TO ROLE analyst ON acme`, err: `found TO, expected permission at line 1, char 17`},
+		{s: `ADD PERMISSIONS create. TO ROLE analyst ON acme`, err: `found WS, expected identifier at line 1, char 24`},
+		{s: `ADD PERMISSIONS select, TO ROLE analyst ON acme`, err: `found TO, expected permission at line 1, char 25`},
+		{s: `ADD PERMISSIONS - TO ROLE analyst ON acme`, err: `found WS, expected permission at line 1, char 18`},
+		{s: `ADD PERMISSIONS *.log TO ROLE analyst ON acme`, err: `found ., expected TO at line 1, char 18`},
+		{s: `ADD PERMISSIONS select.-log TO ROLE analyst ON acme`, err: `found -, expected identifier at line 1, char 24`},
+		{s: `ADD PERMISSIONS select ROLE analyst ON acme`, err: `found ROLE, expected TO at line 1, char 24`},
+		{s: `ADD PERMISSIONS select TO analyst ON acme`, err: `found analyst, expected ROLE at line 1, char 27`},
+		{s: `REMOVE PERMISSION select TO ROLE analyst ON acme`, err: `found TO, expected FROM at line 1, char 26`},
+		{s: `ADD ROLE analyst TO bob ON acme`, err: `found bob, expected USER at line 1, char 21`},
+		{s: `REMOVE ROLE analyst FROM USER bob`, err: `found EOF, expected ON at line 1, char 35`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into SUBSCRIBE and UNSUBSCRIBE statements
+func (suite *ParserTestSuite) TestSubscribe() {
+	var tests = []TestCase{
+		{
+			s:    `SUBSCRIBE events`,
+			stmt: &SubscribeStatement{log: "events"},
+		},
+		{
+			s:    `SUBSCRIBE acme.events FROM OFFSET 0`,
+			stmt: &SubscribeStatement{log: "acme.events", offset: 0, hasOffset: true},
+		},
+		{
+			s: `SUBSCRIBE acme.events FROM OFFSET 42 WHERE name = 'login'`,
+			stmt: &SubscribeStatement{
+				log:       "acme.events",
+				offset:    42,
+				hasOffset: true,
+				condition: &BinaryExpr{Op: lexer.EQ, LHS: &VarRef{"name"}, RHS: &StringLiteral{"login"}},
+			},
+		},
+		{
+			s:    `UNSUBSCRIBE`,
+			stmt: &UnsubscribeStatement{},
+		},
+		{
+			s:    `UNSUBSCRIBE acme.events`,
+			stmt: &UnsubscribeStatement{log: "acme.events"},
+		},
+
+		// Errors
+		{s: `SUBSCRIBE`, err: `found EOF, expected log name at line 1, char 11`},
+		{s: `SUBSCRIBE events FROM 42`, err: `found 42, expected OFFSET at line 1, char 23`},
+		{s: `SUBSCRIBE events FROM OFFSET`, err: `found EOF, expected number at line 1, char 30`},
+		{s: `SUBSCRIBE events WHERE`, err: `found EOF, expected identifier, string, number, boolean, timestamp, ( at line 1, char 24`},
+		{s: `UNSUBSCRIBE .events`, err: `found ., expected log name at line 1, char 13`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into INSERT statements
+func (suite *ParserTestSuite) TestInsert() {
+	var tests = []TestCase{
+		{
+			s: `INSERT INTO acme.events (id, name, score, active) VALUES (1, 'login', 1.5, true)`,
+			stmt: &InsertStatement{log: "acme.events", records: []RecordLiteral{
+				{{"id", int64(1)}, {"name", "login"}, {"score", 1.5}, {"active", true}},
+			}},
+		},
+		{
+			s: `INSERT INTO events(id) VALUES (-1), (18446744073709551615)`,
+			stmt: &InsertStatement{log: "events", records: []RecordLiteral{
+				{{"id", int64(-1)}},
+				{{"id", uint64(18446744073709551615)}},
+			}},
+		},
+		{
+			s: `INSERT INTO acme.events {"id": 1, 'name': "login"}, {id: 2, active: false}`,
+			stmt: &InsertStatement{log: "acme.events", records: []RecordLiteral{
+				{{"id", int64(1)}, {"name", "login"}},
+				{{"id", int64(2)}, {"active", false}},
+			}},
+		},
+
+		// Errors
+		{s: `INSERT `, err: `found EOF, expected INTO at line 1, char 9`},
+		{s: `INSERT INTO `, err: `found EOF, expected log name at line 1, char 14`},
+		{s: `INSERT INTO acme.events`, err: `found EOF, expected (, { at line 1, char 25`},
+		{s: `INSERT INTO acme.events (id`, err: `found EOF, expected ,, ) at line 1, char 29`},
+		{s: `INSERT INTO acme.events (id, id) VALUES (1, 2)`, err: `duplicate field 'id' at line 1, char 30`},
+		{s: `INSERT INTO acme.events (id) (1)`, err: `found (, expected VALUES at line 1, char 30`},
+		{s: `INSERT INTO acme.events (id, name) VALUES (1)`, err: `expected 2 values, found 1 at line 1, char 43`},
+		{s: `INSERT INTO acme.events (id) VALUES (1, 2)`, err: `expected 1 values at line 1, char 41`},
+		{s: `INSERT INTO acme.events (id) VALUES (id)`, err: `found id, expected string, number, boolean at line 1, char 38`},
+		{s: `INSERT INTO acme.events {id 1}`, err: `found 1, expected : at line 1, char 29`},
+		{s: `INSERT INTO acme.events {id: 1, id: 2}`, err: `duplicate field 'id' at line 1, char 33`},
+		{s: `INSERT INTO acme.events {id: 1`, err: `found EOF, expected ,, } at line 1, char 31`},
+	}
+
+	suite.validate(tests)
+}
+
 // Ensure the parser can parse strings into DROP NAMESPACE statements
 func (suite *ParserTestSuite) TestDropNamespace() {
 	var tests = []TestCase{
@@ -103,9 +548,13 @@ func (suite *ParserTestSuite) TestDropNamespace() {
 			s:    `DROP NAMESPACE acme`,
 			stmt: &DropNamespaceStatement{name: "acme"},
 		},
+		{
+			s:    `DROP NAMESPACE acme.example CASCADE`,
+			stmt: &DropNamespaceStatement{name: "acme.example", cascade: true},
+		},
 
 		// Errors
-		{s: `DROP `, err: `found EOF, expected NAMESPACE at line 1, char 7`},
+		{s: `DROP `, err: `found EOF, expected NAMESPACE, USER at line 1, char 7`},
 		{s: `DROP NAMESPACE `, err: `found EOF, expected namespace at line 1, char 17`},
 		{s: `DROP NAMESPACE acme.example.`, err: `found EOF, expected identifier at line 1, char 29`},
 		{s: `DROP NAMESPACE acme.example. `, err: `found WS, expected identifier at line 1, char 29`},
@@ -124,13 +573,126 @@ func (suite *ParserTestSuite) TestShowNamespaces() {
 		},
 
 		// Errors
-		{s: `SHOW `, err: `found EOF, expected NAMESPACES at line 1, char 7`},
-		{s: `SHOW NAMESPACE`, err: `found NAMESPACE, expected NAMESPACES at line 1, char 6`},
+		{s: `SHOW `, err: `found EOF, expected NAMESPACES, USERS, ROLES, PERMISSIONS, LOGS, VIEWS, TYPES, KEYS at line 1, char 7`},
+		{s: `SHOW NAMESPACE`, err: `found NAMESPACE, expected NAMESPACES, USERS, ROLES, PERMISSIONS, LOGS, VIEWS, TYPES, KEYS at line 1, char 6`},
 	}
 
 	suite.validate(tests)
 }
 
+// Ensure the parser can parse strings into the remaining SHOW statements
+func (suite *ParserTestSuite) TestShowStatements() {
+	var tests = []TestCase{
+		{s: `SHOW USERS`, stmt: &ShowUsersStatement{}},
+		{s: `SHOW ROLES`, stmt: &ShowRolesStatement{}},
+		{s: `SHOW ROLES ON acme.metrics`, stmt: &ShowRolesStatement{namespace: "acme.metrics"}},
+		{s: `SHOW PERMISSIONS FOR ROLE analyst ON acme`, stmt: &ShowPermissionsStatement{role: "analyst", namespace: "acme"}},
+		{s: `SHOW LOGS`, stmt: &ShowLogsStatement{}},
+		{s: `SHOW VIEWS`, stmt: &ShowViewsStatement{}},
+		{s: `SHOW TYPES`, stmt: &ShowTypesStatement{}},
+
+		// Errors
+		{s: `SHOW ROLES ON`, err: `found EOF, expected namespace at line 1, char 15`},
+		{s: `SHOW PERMISSIONS ROLE analyst ON acme`, err: `found ROLE, expected FOR at line 1, char 18`},
+		{s: `SHOW PERMISSIONS FOR analyst ON acme`, err: `found analyst, expected ROLE at line 1, char 22`},
+		{s: `SHOW PERMISSIONS FOR ROLE analyst`, err: `found EOF, expected ON at line 1, char 35`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into DESCRIBE statements
+func (suite *ParserTestSuite) TestDescribe() {
+	var tests = []TestCase{
+		{s: `DESCRIBE NAMESPACE acme.metrics`, stmt: &DescribeNamespaceStatement{name: "acme.metrics"}},
+		{s: `DESCRIBE TYPE acme.point`, stmt: &DescribeTypeStatement{name: "acme.point"}},
+		{s: `DESCRIBE LOG events`, stmt: &DescribeLogStatement{name: "events"}},
+		{s: `DESCRIBE VIEW acme.latest`, stmt: &DescribeViewStatement{name: "acme.latest"}},
+
+		// Errors
+		{s: `DESCRIBE `, err: `found EOF, expected NAMESPACE, TYPE, LOG, VIEW at line 1, char 11`},
+		{s: `DESCRIBE USER bob`, err: `found USER, expected NAMESPACE, TYPE, LOG, VIEW at line 1, char 10`},
+		{s: `DESCRIBE LOG`, err: `found EOF, expected log name at line 1, char 14`},
+		{s: `DESCRIBE VIEW acme.`, err: `found EOF, expected identifier at line 1, char 20`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into SET statements for session options
+func (suite *ParserTestSuite) TestSetOption() {
+	var tests = []TestCase{
+		{s: `SET ON_ERROR CONTINUE`, stmt: &SetOptionStatement{option: "on_error", value: "continue"}},
+		{s: `set on_error stop`, stmt: &SetOptionStatement{option: "on_error", value: "stop"}},
+		{s: `SET FORMAT json`, stmt: &SetOptionStatement{option: "format", value: "json"}},
+		{s: `SET FORMAT TSV`, stmt: &SetOptionStatement{option: "format", value: "tsv"}},
+
+		// Errors
+		{s: `SET VERBOSE true`, err: `found VERBOSE, expected PASSWORD, FORMAT, ON_ERROR at line 1, char 5`},
+		{s: `SET ON_ERROR ignore`, err: `found ignore, expected STOP, CONTINUE at line 1, char 14`},
+		{s: `SET FORMAT xml`, err: `found xml, expected TABLE, JSON, CSV, TSV at line 1, char 12`},
+	}
+
+	suite.validate(tests)
+}
+
+// Ensure the parser can parse strings into lists of statements
+func (suite *ParserTestSuite) TestParseStatements() {
+	var tests = []struct {
+		s     string
+		stmts []string
+		pos   []lexer.Pos
+		err   string
+	}{
+		{s: ``},
+		{s: ` ; ;`},
+		{s: `USE acme`, stmts: []string{`USE acme`}, pos: []lexer.Pos{{Line: 0, Char: 0}}},
+		{
+			s:     "USE acme;\n  SHOW LOGS;SELECT * FROM events WHERE id = 1;",
+			stmts: []string{`USE acme`, `SHOW LOGS`, `SELECT * FROM events WHERE id = 1`},
+			pos:   []lexer.Pos{{Line: 0, Char: 0}, {Line: 1, Char: 2}, {Line: 1, Char: 12}},
+		},
+		{
+			s:     `ADD KEY 'pem' TO USER ci WITH read_only = true, namespaces = (acme), expires = '1h30m'`,
+			stmts: []string{`ADD KEY 'pem' TO USER ci WITH expires = '1h30m0s', namespaces = (acme), read_only = true`},
+			pos:   []lexer.Pos{{Line: 0, Char: 0}},
+		},
+		{s: `UNSUBSCRIBE;`, stmts: []string{`UNSUBSCRIBE`}, pos: []lexer.Pos{{Line: 0, Char: 0}}},
+		{
+			s:     `UNSUBSCRIBE; SHOW LOGS`,
+			stmts: []string{`UNSUBSCRIBE`, `SHOW LOGS`},
+			pos:   []lexer.Pos{{Line: 0, Char: 0}, {Line: 0, Char: 13}},
+		},
+		{
+			s:     `SET PASSWORD FOR bob = 'a;b'; DROP USER bob`,
+			stmts: []string{`SET PASSWORD FOR bob = '********'`, `DROP USER bob`},
+			pos:   []lexer.Pos{{Line: 0, Char: 0}, {Line: 0, Char: 30}},
+		},
+
+		// Errors
+		{s: `USE acme SHOW LOGS`, err: `found SHOW, expected ; at line 1, char 10`},
+		{s: `SHOW LOGS extra; USE acme`, err: `found extra, expected ; at line 1, char 11`},
+		{s: `USE acme; SHOW`, err: `found EOF, expected NAMESPACES, USERS, ROLES, PERMISSIONS, LOGS, VIEWS, TYPES, KEYS at line 1, char 16`},
+	}
+
+	for i, tt := range tests {
+		stmts, err := NewParser(strings.NewReader(tt.s)).ParseStatements()
+		if tt.err != errstring(err) {
+			suite.T().Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+			continue
+		}
+
+		var strs []string
+		var pos []lexer.Pos
+		for _, stmt := range stmts {
+			strs = append(strs, stmt.String())
+			pos = append(pos, stmt.Pos)
+		}
+		suite.Equal(tt.stmts, strs, tt.s)
+		suite.Equal(tt.pos, pos, tt.s)
+	}
+}
+
 // errstring converts an error to its string representation.
 func errstring(err error) string {
 	if err != nil {
@@ -167,6 +729,34 @@ func BenchmarkCreateNamespaceStatement(b *testing.B) {
 	}
 }
 
+func BenchmarkCreateLogStatement(b *testing.B) {
+	stmt := "CREATE LOG acme.events"
+	for i := 0; i < b.N; i++ {
+		NewParser(strings.NewReader(stmt)).ParseStatement()
+	}
+}
+
+func BenchmarkSelectStatement(b *testing.B) {
+	stmt := "SELECT id, name FROM acme.events WHERE name =~ /^log/ AND (id > 10 OR active = true) LIMIT 10 OFFSET 5"
+	for i := 0; i < b.N; i++ {
+		NewParser(strings.NewReader(stmt)).ParseStatement()
+	}
+}
+
+func BenchmarkCreateTypeStatement(b *testing.B) {
+	stmt := "CREATE TYPE acme.Event (id uint64 REQUIRED, name string OPTIONAL, at timestamp REQUIRED)"
+	for i := 0; i < b.N; i++ {
+		NewParser(strings.NewReader(stmt)).ParseStatement()
+	}
+}
+
+func BenchmarkInsertStatement(b *testing.B) {
+	stmt := "INSERT INTO acme.events (id, name) VALUES (1, 'login')"
+	for i := 0; i < b.N; i++ {
+		NewParser(strings.NewReader(stmt)).ParseStatement()
+	}
+}
+
 func BenchmarkDropNamespaceStatement(b *testing.B) {
 	stmt := "DROP NAMESPACE acme"
 	for i := 0; i < b.N; i++ {
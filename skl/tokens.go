@@ -29,19 +29,26 @@ const (
 
 	startKeywords
 	ADD
+	AS
 	BY
+	CASCADE
 	CLUSTERED
 	CREATE
+	DEMOTE
 	DESCRIBE
 	DROP
 	FOR
 	FROM
 	INSERT
+	INTO
+	KEY
+	KEYS
 	LIMIT
 	LOG
 	LOGS
 	NAMESPACE
 	NAMESPACES
+	NOT
 	OFFSET
 	ON
 	OPTIONAL
@@ -49,6 +56,7 @@ const (
 	PASSWORD
 	PERMISSION
 	PERMISSIONS
+	PROMOTE
 	REMOVE
 	REQUIRED
 	ROLE
@@ -58,6 +66,7 @@ const (
 	SHOW
 	SUBSCRIBE
 	TO
+	TOTP
 	TYPE
 	TYPES
 	UNSUBSCRIBE
@@ -66,6 +75,7 @@ const (
 	USER
 	USERS
 	USING
+	VALUES
 	VIEW
 	VIEWS
 	WHERE
@@ -90,19 +100,26 @@ var testKeywords = map[lexer.Token]string{
 	BOOLEAN:   "boolean",
 
 	ADD:         "ADD",
+	AS:          "AS",
 	BY:          "BY",
+	CASCADE:     "CASCADE",
 	CLUSTERED:   "CLUSTERED",
 	CREATE:      "CREATE",
+	DEMOTE:      "DEMOTE",
 	DESCRIBE:    "DESCRIBE",
 	DROP:        "DROP",
 	FOR:         "FOR",
 	FROM:        "FROM",
 	INSERT:      "INSERT",
+	INTO:        "INTO",
+	KEY:         "KEY",
+	KEYS:        "KEYS",
 	LIMIT:       "LIMIT",
 	LOG:         "LOG",
 	LOGS:        "LOGS",
 	NAMESPACE:   "NAMESPACE",
 	NAMESPACES:  "NAMESPACES",
+	NOT:         "NOT",
 	OFFSET:      "OFFSET",
 	ON:          "ON",
 	OPTIONAL:    "OPTIONAL",
@@ -110,6 +127,7 @@ var testKeywords = map[lexer.Token]string{
 	PASSWORD:    "PASSWORD",
 	PERMISSION:  "PERMISSION",
 	PERMISSIONS: "PERMISSIONS",
+	PROMOTE:     "PROMOTE",
 	REMOVE:      "REMOVE",
 	REQUIRED:    "REQUIRED",
 	ROLE:        "ROLE",
@@ -119,6 +137,7 @@ var testKeywords = map[lexer.Token]string{
 	SHOW:        "SHOW",
 	SUBSCRIBE:   "SUBSCRIBE",
 	TO:          "TO",
+	TOTP:        "TOTP",
 	TYPE:        "TYPE",
 	TYPES:       "TYPES",
 	UNSUBSCRIBE: "UNSUBSCRIBE",
@@ -127,6 +146,7 @@ var testKeywords = map[lexer.Token]string{
 	USER:        "USER",
 	USERS:       "USERS",
 	USING:       "USING",
+	VALUES:      "VALUES",
 	VIEW:        "VIEW",
 	VIEWS:       "VIEWS",
 	WHERE:       "WHERE",
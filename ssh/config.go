@@ -1,17 +1,30 @@
 package ssh
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	log "github.com/mgutz/logxi/v1"
+	"github.com/subsilent/kappa/auth"
 	"github.com/subsilent/kappa/datamodel"
 	"github.com/subsilent/kappa/ssh/handlers"
 	"golang.org/x/crypto/ssh"
 )
 
+// Errors of password logins
+var (
+
+	// errInvalidPassword is returned for unknown users and wrong passwords alike
+	errInvalidPassword = errors.New("invalid username or password")
+
+	// errVerificationCodeRequired is returned for correct passwords of users with a TOTP second factor
+	errVerificationCodeRequired = errors.New("verification code required")
+)
+
 // Config is used to setup the SSHServer.
 type Config struct {
 	sync.Mutex
@@ -37,8 +50,34 @@ type Config struct {
 	// System is the System datamodel
 	System datamodel.System
 
+	// CertificateAuthorities sign the SSH user certificates which are accepted without adding keys to key rings.
+	// The principals of a certificate are the usernames it may log in as and certificates without principals are
+	// rejected.
+	CertificateAuthorities []ssh.PublicKey
+
+	// AutoCreateUsers creates users without roles when they first log in with a certificate
+	AutoCreateUsers bool
+
+	// PasswordAuth enables logging in with passwords. Users with a TOTP second factor can't log in with a password
+	// alone and must use keyboard-interactive authentication.
+	PasswordAuth bool
+
+	// KeyboardInteractiveAuth enables logging in by answering a password prompt, followed by a verification code
+	// prompt for users with a TOTP second factor
+	KeyboardInteractiveAuth bool
+
+	// MaxAuthFailures is the number of failed password logins after which the user and the source address are
+	// locked out. Zero disables lockouts.
+	MaxAuthFailures int
+
+	// LockoutDuration is how long users and source addresses are locked out
+	LockoutDuration time.Duration
+
 	// sshConfig is used to verify incoming connections
 	sshConfig *ssh.ServerConfig
+
+	// lockout tracks failed password logins
+	lockout *lockout
 }
 
 func (c *Config) SSHConfig() (*ssh.ServerConfig, error) {
@@ -52,10 +91,11 @@ func (c *Config) SSHConfig() (*ssh.ServerConfig, error) {
 		return &ssh.ServerConfig{}, fmt.Errorf("ssh server: user store: %s", err)
 	}
 
-	// Create server config
-	sshConfig := &ssh.ServerConfig{
-		NoClientAuth: false,
-		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (perm *ssh.Permissions, err error) {
+	// Accept certificates signed by the certificate authorities and keys from key rings
+	checker := &ssh.CertChecker{
+		SupportedCriticalOptions: []string{handlers.ForceCommandOption},
+		IsUserAuthority:          c.isUserAuthority,
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (perm *ssh.Permissions, err error) {
 
 			// Get user if exists, otherwise return error
 			user, err := users.Get(conn.User())
@@ -64,32 +104,197 @@ func (c *Config) SSHConfig() (*ssh.ServerConfig, error) {
 			}
 
 			// Check keyring for public key
-			if keyring := user.KeyRing(); !keyring.Contains(key.Marshal()) {
+			keyring := user.KeyRing()
+			if !keyring.Contains(key.Marshal()) {
 				err = fmt.Errorf("invalid public key")
 				return
 			}
 
-			// Add pubkey and username to permissions
+			// Reject expired keys
+			fingerprint := auth.CreateFingerprint(key.Marshal())
+			attributes, err := keyring.Attributes(fingerprint)
+			if err != nil {
+				return
+			} else if attributes.Expired(time.Now()) {
+				err = fmt.Errorf("public key expired")
+				return
+			}
+
+			// Add pubkey, fingerprint and username to permissions
 			perm = &ssh.Permissions{
 				Extensions: map[string]string{
-					"pubkey":   string(key.Marshal()),
-					"username": conn.User(),
+					"pubkey":      string(key.Marshal()),
+					"fingerprint": fingerprint,
+					"username":    conn.User(),
 				},
 			}
+
+			// Keys may force a script to be executed like certificates
+			if attributes.Command != "" {
+				perm.CriticalOptions = map[string]string{handlers.ForceCommandOption: attributes.Command}
+			}
 			return
 		},
-		AuthLogCallback: func(conn ssh.ConnMetadata, method string, err error) {
+	}
+
+	// Create server config
+	sshConfig := &ssh.ServerConfig{
+		NoClientAuth: false,
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+
+			// Certificates without principals would be valid for every user
+			if cert, ok := key.(*ssh.Certificate); ok && len(cert.ValidPrincipals) == 0 {
+				return nil, fmt.Errorf("certificate has no principals")
+			}
+
+			perm, err := checker.Authenticate(conn, key)
 			if err != nil {
-				c.Logger.Info("Login attempt", "user", conn.User(), "method", method, "error", err.Error())
-			} else {
+				return nil, err
+			}
+
+			cert, ok := key.(*ssh.Certificate)
+			if !ok {
+				return perm, nil
+			}
+			return c.certificatePermissions(users, conn, cert)
+		},
+		AuthLogCallback: func(conn ssh.ConnMetadata, method string, err error) {
+			if err == nil {
+				c.lockout.succeed(conn)
 				c.Logger.Info("Successful login", "user", conn.User(), "method", method)
+				return
+			}
+			c.Logger.Info("Login attempt", "user", conn.User(), "method", method, "error", err.Error())
+
+			// Count failed passwords and verification codes towards lockouts. Correct passwords of users who must
+			// enter a verification code aren't failures.
+			if (method == "password" || method == "keyboard-interactive") && err != errLockedOut &&
+				err != errVerificationCodeRequired {
+				for _, key := range c.lockout.fail(conn, time.Now()) {
+					c.Logger.Warn("Locked out", "key", key, "duration", c.LockoutDuration.String())
+				}
 			}
 		},
 	}
+
+	// Enable password logins
+	c.lockout = newLockout(c.MaxAuthFailures, c.LockoutDuration)
+
+	// Hash the password unknown users are verified against up front so the first of them isn't slower
+	if c.PasswordAuth || c.KeyboardInteractiveAuth {
+		datamodel.RejectPassword("")
+	}
+	if c.PasswordAuth {
+		sshConfig.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			user, err := c.authenticatePassword(users, conn, string(password))
+			if err != nil {
+				return nil, err
+			} else if user.TOTPSecret() != nil {
+				return nil, errVerificationCodeRequired
+			}
+			return passwordPermissions(user), nil
+		}
+	}
+	if c.KeyboardInteractiveAuth {
+		sshConfig.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+
+			// Prompt for password
+			answers, err := client("", "", []string{"Password: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			} else if len(answers) != 1 {
+				return nil, fmt.Errorf("invalid number of answers")
+			}
+
+			user, err := c.authenticatePassword(users, conn, answers[0])
+			if err != nil {
+				return nil, err
+			}
+
+			// Prompt for verification code, which can only be used once
+			if user.TOTPSecret() != nil {
+				answers, err := client("", "", []string{"Verification code: "}, []bool{true})
+				if err != nil {
+					return nil, err
+				} else if len(answers) != 1 || !user.AcceptTOTP(strings.TrimSpace(answers[0]), time.Now()) {
+					return nil, fmt.Errorf("invalid verification code")
+				}
+			}
+			return passwordPermissions(user), nil
+		}
+	}
+
 	sshConfig.AddHostKey(c.PrivateKey)
 	return sshConfig, nil
 }
 
+// authenticatePassword returns the user logging in if the password is valid. Locked out users and addresses are
+// rejected without checking the password. Errors don't reveal whether the user exists.
+func (c *Config) authenticatePassword(users datamodel.UserStore, conn ssh.ConnMetadata, password string) (datamodel.User, error) {
+	if c.lockout.locked(conn, time.Now()) {
+		return nil, errLockedOut
+	}
+
+	// Unknown users are rejected as slowly as wrong passwords
+	user, err := users.Get(conn.User())
+	if err != nil {
+		datamodel.RejectPassword(password)
+		return nil, errInvalidPassword
+	} else if !user.ValidatePassword(password) {
+		return nil, errInvalidPassword
+	}
+	return user, nil
+}
+
+// passwordPermissions returns the permissions of users logged in with a password
+func passwordPermissions(user datamodel.User) *ssh.Permissions {
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			"username": user.Username(),
+		},
+	}
+}
+
+// isUserAuthority determines if a key is one of the certificate authorities
+func (c *Config) isUserAuthority(key ssh.PublicKey) bool {
+	for _, authority := range c.CertificateAuthorities {
+		if bytes.Equal(authority.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificatePermissions maps a verified certificate to the user it logs in as, creating the user if allowed. Only
+// the critical options of the certificate are kept, which are enforced by the server and the session handler.
+func (c *Config) certificatePermissions(users datamodel.UserStore, conn ssh.ConnMetadata, cert *ssh.Certificate) (*ssh.Permissions, error) {
+
+	// Get user if exists, otherwise create it if allowed
+	user, err := users.Get(conn.User())
+	if err == datamodel.ErrUserDoesNotExist && c.AutoCreateUsers {
+		if user, err = users.Create(conn.User()); err != nil {
+			return nil, err
+		}
+		c.Logger.Info("Created user from certificate", "user", user.Username(), "certificate", cert.KeyId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Add pubkey, certificate and username to permissions
+	criticalOptions := make(map[string]string, len(cert.CriticalOptions))
+	for option, value := range cert.CriticalOptions {
+		criticalOptions[option] = value
+	}
+	return &ssh.Permissions{
+		CriticalOptions: criticalOptions,
+		Extensions: map[string]string{
+			"pubkey":      string(cert.Key.Marshal()),
+			"certificate": cert.KeyId,
+			"username":    user.Username(),
+		},
+	}, nil
+}
+
 func (c *Config) Handler(channel string) (handler handlers.SSHHandler, ok bool) {
 	c.Lock()
 	handler, ok = c.Handlers[channel]
@@ -0,0 +1,209 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	log "github.com/mgutz/logxi/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/ssh/handlers"
+	"github.com/subsilent/kappa/storage"
+	"golang.org/x/crypto/ssh"
+)
+
+// testConn is the metadata of a connection logging in as a user
+type testConn string
+
+func (c testConn) User() string          { return string(c) }
+func (c testConn) SessionID() []byte     { return []byte("session") }
+func (c testConn) ClientVersion() []byte { return []byte("SSH-2.0-test") }
+func (c testConn) ServerVersion() []byte { return []byte("SSH-2.0-kappa") }
+func (c testConn) RemoteAddr() net.Addr  { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000} }
+func (c testConn) LocalAddr() net.Addr   { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9022} }
+
+// newSigner creates an ed25519 signer
+func newSigner(t *testing.T) ssh.Signer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromSigner(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newConfig completes a config with a new system and creates the server config
+func newConfig(t *testing.T, config *Config) (*ssh.ServerConfig, datamodel.UserStore) {
+	dir, err := ioutil.TempDir("", "ssh.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	system, err := datamodel.NewSystem(path.Join(dir, "test.db"), storage.NewEngine(path.Join(dir, "logs"), storage.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(system.Close)
+
+	config.Logger = log.NullLog
+	config.PrivateKey = newSigner(t)
+	config.System = system
+	sshConfig, err := config.SSHConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := system.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshConfig, users
+}
+
+// newCertificate creates a user certificate signed by an authority for the given principals
+func newCertificate(t *testing.T, authority ssh.Signer, principals ...string) *ssh.Certificate {
+	cert := &ssh.Certificate{
+		Key:             newSigner(t).PublicKey(),
+		KeyId:           "test",
+		CertType:        ssh.UserCert,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, authority); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// Ensure certificates log in as their principals
+func TestCertificatePrincipals(t *testing.T) {
+	authority := newSigner(t)
+	sshConfig, users := newConfig(t, &Config{CertificateAuthorities: []ssh.PublicKey{authority.PublicKey()}})
+	_, err := users.Create("ana")
+	assert.Nil(t, err)
+
+	cert := newCertificate(t, authority, "ana")
+	perm, err := sshConfig.PublicKeyCallback(testConn("ana"), cert)
+	assert.Nil(t, err)
+	assert.Equal(t, "ana", perm.Extensions["username"])
+
+	// Other users can't be logged in as
+	_, err = sshConfig.PublicKeyCallback(testConn("admin"), cert)
+	assert.NotNil(t, err)
+}
+
+// Ensure certificates without principals are rejected rather than valid for every user
+func TestCertificateWithoutPrincipals(t *testing.T) {
+	authority := newSigner(t)
+	sshConfig, users := newConfig(t, &Config{CertificateAuthorities: []ssh.PublicKey{authority.PublicKey()}, AutoCreateUsers: true})
+	admin, err := users.Create("admin")
+	assert.Nil(t, err)
+	assert.Nil(t, admin.SetAdmin(true))
+
+	cert := newCertificate(t, authority)
+	for _, username := range []string{"admin", "mallory"} {
+		perm, err := sshConfig.PublicKeyCallback(testConn(username), cert)
+		assert.Nil(t, perm, username)
+		assert.EqualError(t, err, "certificate has no principals", username)
+	}
+
+	// No user is created
+	_, err = users.Get("mallory")
+	assert.Equal(t, datamodel.ErrUserDoesNotExist, err)
+}
+
+// Ensure keys with a command force it like certificates
+func TestKeyCommand(t *testing.T) {
+	sshConfig, users := newConfig(t, &Config{})
+	user, err := users.Create("ci")
+	assert.Nil(t, err)
+
+	forced, plain := newSigner(t).PublicKey(), newSigner(t).PublicKey()
+	_, err = user.KeyRing().AddPublicKey(append([]byte(`command="SHOW NAMESPACES" `), ssh.MarshalAuthorizedKey(forced)...))
+	assert.Nil(t, err)
+	_, err = user.KeyRing().AddPublicKey(ssh.MarshalAuthorizedKey(plain))
+	assert.Nil(t, err)
+
+	perm, err := sshConfig.PublicKeyCallback(testConn("ci"), forced)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{handlers.ForceCommandOption: "SHOW NAMESPACES"}, perm.CriticalOptions)
+
+	perm, err = sshConfig.PublicKeyCallback(testConn("ci"), plain)
+	assert.Nil(t, err)
+	assert.Empty(t, perm.CriticalOptions)
+}
+
+// newTOTPUser creates a user with a password and a TOTP second factor
+func newTOTPUser(t *testing.T, users datamodel.UserStore, username, password string) []byte {
+	user, err := users.Create(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := datamodel.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = user.UpdatePassword(password); err != nil {
+		t.Fatal(err)
+	} else if err = user.SetTOTPSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// Ensure correct passwords of users with a second factor don't count towards lockouts
+func TestPasswordVerificationCodeRequired(t *testing.T) {
+	sshConfig, users := newConfig(t, &Config{PasswordAuth: true, MaxAuthFailures: 1, LockoutDuration: time.Minute})
+	newTOTPUser(t, users, "ana", "secret")
+
+	for i := 0; i < 2; i++ {
+		_, err := sshConfig.PasswordCallback(testConn("ana"), []byte("secret"))
+		assert.Equal(t, errVerificationCodeRequired, err)
+		sshConfig.AuthLogCallback(testConn("ana"), "password", err)
+	}
+
+	// Wrong passwords do
+	_, err := sshConfig.PasswordCallback(testConn("ana"), []byte("guess"))
+	assert.Equal(t, errInvalidPassword, err)
+	sshConfig.AuthLogCallback(testConn("ana"), "password", err)
+	_, err = sshConfig.PasswordCallback(testConn("ana"), []byte("secret"))
+	assert.Equal(t, errLockedOut, err)
+}
+
+// Ensure unknown users are rejected like wrong passwords
+func TestPasswordUnknownUser(t *testing.T) {
+	sshConfig, _ := newConfig(t, &Config{PasswordAuth: true})
+	_, err := sshConfig.PasswordCallback(testConn("mallory"), []byte("secret"))
+	assert.Equal(t, errInvalidPassword, err)
+}
+
+// Ensure verification codes can't be used twice
+func TestVerificationCodeReplay(t *testing.T) {
+	sshConfig, users := newConfig(t, &Config{KeyboardInteractiveAuth: true})
+	secret := newTOTPUser(t, users, "ana", "secret")
+	code := datamodel.TOTPCode(secret, time.Now())
+
+	client := func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		if questions[0] == "Password: " {
+			return []string{"secret"}, nil
+		}
+		return []string{code}, nil
+	}
+	perm, err := sshConfig.KeyboardInteractiveCallback(testConn("ana"), client)
+	assert.Nil(t, err)
+	assert.Equal(t, "ana", perm.Extensions["username"])
+
+	_, err = sshConfig.KeyboardInteractiveCallback(testConn("ana"), client)
+	assert.EqualError(t, err, "invalid verification code")
+}
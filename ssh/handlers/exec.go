@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+
+	"github.com/subsilent/kappa/common"
+	"github.com/subsilent/kappa/datamodel"
+	"github.com/subsilent/kappa/executor"
+	"github.com/subsilent/kappa/skl"
+	"golang.org/x/crypto/ssh"
+)
+
+// execute runs the statements of an exec request without a terminal and closes the channel with an exit status
+// reflecting the status code of the last statement executed. Output is written without colors in the given format.
+func (s *shellHandler) execute(channel ssh.Channel, system datamodel.System, session executor.Session, script, format string) {
+	defer channel.Close()
+
+	w := common.ResponseWriter{Writer: channel, Format: format}
+
+	// Parse statements
+	query, err := skl.ParseQuery(script)
+	if err != nil {
+		s.logParseError(err)
+		w.Error(err)
+		sendExitStatus(channel, common.AsError(err).Code.ExitStatus())
+		return
+	}
+
+	// Create query executor
+	executor := executor.NewExecutor(session, &execTerminal{channel}, system)
+	defer executor.Close()
+
+	// Execute statements
+	executor.ExecuteQuery(&w, query)
+	sendExitStatus(channel, w.Status().ExitStatus())
+}
+
+// sendExitStatus sends the "exit-status" request which sets the exit status of the client
+func sendExitStatus(channel ssh.Channel, status uint32) {
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
+
+// execTerminal implements common.Terminal for exec requests, which have no prompt
+type execTerminal struct {
+	io.Writer
+}
+
+func (t *execTerminal) GetPrompt() string  { return "" }
+func (t *execTerminal) ResetPrompt()       {}
+func (t *execTerminal) SetPrompt(p string) {}
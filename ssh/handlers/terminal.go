@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"fmt"
 	"strings"
+	"time"
 
 	log "github.com/mgutz/logxi/v1"
 
@@ -16,8 +16,17 @@ import (
 	tomb "gopkg.in/tomb.v2"
 )
 
-func NewShellHandler(logger log.Logger) SSHHandler {
-	return &shellHandler{}
+// formatVariable is the environment variable selecting the output format of a session
+const formatVariable = "KAPPA_FORMAT"
+
+// ForceCommandOption is the critical option of SSH certificates replacing the shell or script requested by the
+// client with a fixed script. Keys with a command have the option as well.
+const ForceCommandOption = "force-command"
+
+// NewShellHandler creates a handler for "session" channels. Sessions either start an interactive shell or execute
+// the statements of an exec request.
+func NewShellHandler(logger log.Logger, system datamodel.System) SSHHandler {
+	return &shellHandler{logger, system}
 }
 
 type shellHandler struct {
@@ -38,49 +47,111 @@ func (s *shellHandler) Handle(parentTomb tomb.Tomb, sshConn *ssh.ServerConn, cha
 		return err
 	}
 
+	// Restrict the session to what the key allows and record its use
+	session := executor.NewSession("", user)
+	if fingerprint, ok := sshConn.Permissions.Extensions["fingerprint"]; ok {
+		keyring := user.KeyRing()
+		attributes, err := keyring.Attributes(fingerprint)
+		if err != nil {
+			return err
+		}
+		if err := keyring.Touch(fingerprint, time.Now()); err != nil {
+			s.logger.Warn("Could not record key use", "user", user.Username(), "error", err)
+		}
+		session = session.Restrict(attributes)
+	}
+
+	// Certificates and keys may force a script to be executed instead of the shell or script requested
+	forceCommand, forced := sshConn.Permissions.CriticalOptions[ForceCommandOption]
+
 	// Create tomb for terminal goroutines
 	var t tomb.Tomb
 
+	// Output format requested with the KAPPA_FORMAT environment variable
+	var format string
+
+	// Whether a shell or script has been started. Sessions run a single one.
+	var started bool
+
 	// Sessions have out-of-band requests such as "shell",
-	// "pty-req" and "env".  Here we handle only the
-	// "shell" request.
+	// "exec", "pty-req" and "env".
 	for {
 		select {
 		case <-parentTomb.Dying():
 			t.Kill(nil)
 			return t.Wait()
-		case req := <-requests:
+		case req, ok := <-requests:
+
+			// The channel has been closed
+			if !ok {
+				return nil
+			}
 
-			ok := false
+			ok = false
+			var start func()
 			switch req.Type {
 			case "shell":
-				ok = true
 
-				if len(req.Payload) > 0 {
-					fmt.Println(string(req.Payload))
-					// We don't accept any
-					// commands, only the
-					// default shell.
-					ok = false
+				// We don't accept any commands, only the
+				// default shell.
+				if len(req.Payload) == 0 && forced {
+					ok = true
+					start = func() { s.execute(channel, s.system, session, forceCommand, format) }
+				} else if len(req.Payload) == 0 {
+					ok = true
+					start = func() { s.startTerminal(t, channel, s.system, session, format) }
+				}
+
+			case "exec":
+
+				// The payload is the script to execute
+				var payload struct{ Command string }
+				if err := ssh.Unmarshal(req.Payload, &payload); err == nil {
+					if forced {
+						payload.Command = forceCommand
+					}
+					ok = true
+					start = func() { s.execute(channel, s.system, session, payload.Command, format) }
+				}
+
+			case "env":
+
+				// Only the output format can be set
+				var payload struct{ Name, Value string }
+				if err := ssh.Unmarshal(req.Payload, &payload); err == nil && payload.Name == formatVariable {
+					if _, ok = common.Renderers[payload.Value]; ok {
+						format = payload.Value
+					}
 				}
 
 			case "pty-req":
 				// Responding 'ok' here will let the client
 				// know we have a pty ready for input
 				ok = true
-
-				go s.startTerminal(t, channel, s.system, user)
 			default:
 				// fmt.Println("default req: ", req)
 			}
 
-			req.Reply(ok, nil)
+			// Reject shell and exec requests once the session has started
+			if start != nil && started {
+				ok, start = false, nil
+			} else if start != nil {
+				started = true
+			}
+
+			// Reply before starting so the reply isn't sent after the session output
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+			if start != nil {
+				go start()
+			}
 		}
 	}
 	return nil
 }
 
-func (s *shellHandler) startTerminal(parentTomb tomb.Tomb, channel ssh.Channel, system datamodel.System, user datamodel.User) {
+func (s *shellHandler) startTerminal(parentTomb tomb.Tomb, channel ssh.Channel, system datamodel.System, session executor.Session, format string) {
 	defer channel.Close()
 
 	prompt := "kappa> "
@@ -106,7 +177,14 @@ func (s *shellHandler) startTerminal(parentTomb tomb.Tomb, channel ssh.Channel,
 	term.Write([]byte("\n"))
 
 	// Create query executor
-	executor := executor.NewExecutor(executor.NewSession("", user), common.NewTerminal(term, prompt), system)
+	shell := common.NewTerminal(term, prompt)
+	executor := executor.NewExecutor(session, shell, system)
+	defer executor.Close()
+
+	// Statements can span multiple lines. The prompt in use before a statement was started is restored once
+	// the statement is complete.
+	var buffer skl.StatementBuffer
+	var statementPrompt string
 
 	// Start REPL
 	for {
@@ -117,18 +195,29 @@ func (s *shellHandler) startTerminal(parentTomb tomb.Tomb, channel ssh.Channel,
 		default:
 			input, err := term.ReadLine()
 			if err != nil {
-				fmt.Errorf("Readline() error")
-				break
+
+				// Ctrl-C discards a partial statement
+				if !buffer.Empty() {
+					buffer.Reset()
+					shell.SetPrompt(statementPrompt)
+					continue
+				}
+
+				// Ctrl-C stops active subscriptions, otherwise the session is closed
+				if stopped := executor.StopSubscriptions(); stopped > 0 {
+					w := common.ResponseWriter{Colors: common.DefaultColorCodes, Writer: term}
+					w.Success(common.OK, "%d subscriptions stopped", stopped)
+					continue
+				}
+				return
 			}
 
-			// Process line
+			// Handle commands and comments which are only recognized at the start of a statement
 			line := strings.TrimSpace(input)
-			if len(line) > 0 {
-
-				// Log input and handle exit requests
+			if buffer.Empty() {
 				if line == "exit" || line == "quit" {
 					s.logger.Info("Closing connection")
-					break
+					return
 				} else if line == "quote me" {
 					term.Write([]byte("\r\n"))
 					client.GetMessage(channel, common.DefaultColorCodes)
@@ -141,24 +230,43 @@ func (s *shellHandler) startTerminal(parentTomb tomb.Tomb, channel ssh.Channel,
 					channel.Write(common.DefaultColorCodes.Reset)
 					continue
 				}
+				statementPrompt = shell.GetPrompt()
+			}
 
-				// Parse statement
-				stmt, err := skl.ParseStatement(line)
-
-				// Return parse error in red
-				if err != nil {
-					s.logger.Warn("Bad Statement", "statement", line, "error", err)
-					channel.Write(common.DefaultColorCodes.LightRed)
-					channel.Write([]byte(err.Error()))
-					channel.Write([]byte("\r\n"))
-					channel.Write(common.DefaultColorCodes.Reset)
-					continue
+			// Buffer lines until the statement is complete
+			if !buffer.Write(input) {
+				if !buffer.Empty() {
+					shell.SetPrompt("kappa ...> ")
 				}
+				continue
+			}
+			script := buffer.String()
+			buffer.Reset()
+			shell.SetPrompt(statementPrompt)
+
+			// Output goes through the terminal so background subscriptions don't overwrite the prompt
+			w := common.ResponseWriter{Colors: common.DefaultColorCodes, Writer: term, Format: format}
 
-				// Execute statements
-				w := common.ResponseWriter{common.DefaultColorCodes, channel}
-				executor.Execute(&w, stmt)
+			// Parse statements
+			query, err := skl.ParseQuery(script)
+			if err != nil {
+				s.logParseError(err)
+				w.Error(err)
+				continue
 			}
+
+			// Execute statements
+			executor.ExecuteQuery(&w, query)
 		}
 	}
 }
+
+// logParseError logs a statement which could not be parsed. Only the position of the error is logged as the
+// statement and the tokens in the error may contain passwords.
+func (s *shellHandler) logParseError(err error) {
+	if e, ok := err.(*skl.ParseError); ok {
+		s.logger.Warn("Bad Statement", "line", e.Pos.Line+1, "char", e.Pos.Char+1)
+		return
+	}
+	s.logger.Warn("Bad Statement")
+}
@@ -0,0 +1,105 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// errLockedOut is returned by password callbacks while the user or the source address is locked out
+var errLockedOut = errors.New("too many failed attempts")
+
+// lockout locks out users and source addresses after too many failed password attempts. Failures are forgotten once
+// no further attempt failed for the lockout duration.
+type lockout struct {
+	sync.Mutex
+
+	// max is the number of failures after which a user or address is locked out. Zero disables lockouts.
+	max int
+
+	// duration is how long users and addresses are locked out
+	duration time.Duration
+
+	// failures are the recent failures by user and address
+	failures map[string]*failures
+}
+
+// failures are the recent failed attempts of a user or address
+type failures struct {
+	count  int
+	last   time.Time
+	locked time.Time
+}
+
+// newLockout creates a lockout locking out users and addresses for a duration after max failed attempts
+func newLockout(max int, duration time.Duration) *lockout {
+	return &lockout{max: max, duration: duration, failures: make(map[string]*failures)}
+}
+
+// lockoutKeys returns the keys of the user and the source address of a connection
+func lockoutKeys(conn ssh.ConnMetadata) []string {
+	address := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return []string{"user:" + conn.User(), "address:" + address}
+}
+
+// locked determines if the user or the source address of a connection is locked out
+func (l *lockout) locked(conn ssh.ConnMetadata, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, key := range lockoutKeys(conn) {
+		if f, ok := l.failures[key]; ok && now.Before(f.locked) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail records a failed attempt of a connection and returns the keys which have been locked out by it
+func (l *lockout) fail(conn ssh.ConnMetadata, now time.Time) (locked []string) {
+	if l.max <= 0 {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	// Forget old failures
+	for key, f := range l.failures {
+		if now.Sub(f.last) > l.duration && !now.Before(f.locked) {
+			delete(l.failures, key)
+		}
+	}
+
+	for _, key := range lockoutKeys(conn) {
+		f, ok := l.failures[key]
+		if !ok {
+			f = &failures{}
+			l.failures[key] = f
+		}
+
+		f.count++
+		f.last = now
+		if f.count >= l.max {
+			f.count = 0
+			f.locked = now.Add(l.duration)
+			locked = append(locked, key)
+		}
+	}
+	return
+}
+
+// succeed forgets the failed attempts of the user of a connection. Failures of the source address are kept so
+// logging in with one account doesn't allow guessing the passwords of others.
+func (l *lockout) succeed(conn ssh.ConnMetadata) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.failures, lockoutKeys(conn)[0])
+}
@@ -46,6 +46,7 @@ func NewSSHServer(cfg *Config) (server SSHServer, err error) {
 		err = e
 		return
 	}
+	server.config = cfg
 	server.listener = listener
 	return
 }
@@ -70,8 +71,13 @@ func (s *SSHServer) Stop() error {
 func (s *SSHServer) listen() error {
 	defer s.listener.Close()
 
-	// Create tomb for connection goroutines
+	// Create tomb for connection goroutines. The tomb is kept alive until the server stops as goroutines can't
+	// be added once all of them have returned.
 	var t tomb.Tomb
+	t.Go(func() error {
+		<-s.t.Dying()
+		return nil
+	})
 
 	for {
 
@@ -113,8 +119,10 @@ func (s *SSHServer) handleTCPConnection(parentTomb tomb.Tomb, conn net.Conn) err
 	// Convert to SSH connection
 	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.config.sshConfig)
 	if err != nil {
-		s.config.Logger.Warn("SSH handshake failed: %s", conn.RemoteAddr())
-		return err
+
+		// A failed handshake must not kill the tomb shared with other connections
+		s.config.Logger.Warn("SSH handshake failed", "addr", conn.RemoteAddr(), "error", err)
+		return nil
 	}
 
 	// Close connection on exit
@@ -124,12 +132,23 @@ func (s *SSHServer) handleTCPConnection(parentTomb tomb.Tomb, conn net.Conn) err
 	// Discard requests
 	go ssh.DiscardRequests(requests)
 
-	// Create new tomb stone
+	// Create new tomb stone. The tomb is kept alive until it is killed so channels can be opened after
+	// others have closed.
 	var t tomb.Tomb
+	t.Go(func() error {
+		<-t.Dying()
+		return nil
+	})
 
 	for {
 		select {
-		case ch := <-channels:
+		case ch, ok := <-channels:
+
+			// The connection has been closed
+			if !ok {
+				t.Kill(nil)
+				return t.Wait()
+			}
 			chType := ch.ChannelType()
 
 			// Determine if channel is acceptable (has a registered handler)
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+)
+
+// ErrEngineClosed is returned when a log is requested after the engine has been closed
+var ErrEngineClosed = errors.New("storage engine is closed")
+
+// Engine manages all the logs stored under a data directory. Logs returned by an Engine are owned by it and
+// should not be closed by the caller.
+type Engine interface {
+
+	// Open returns the log with the given name, creating it if it does not exist
+	Open(name string) (Log, error)
+
+	// Drop closes a log and removes all of its segments
+	Drop(name string) error
+
+	// Close closes all open logs
+	Close() error
+}
+
+// NewEngine creates an Engine which stores each log in a sub-directory of dir
+func NewEngine(dir string, opts Options) Engine {
+	return &fileEngine{dir: dir, opts: opts, logs: make(map[string]Log)}
+}
+
+// fileEngine implements the Engine interface on the local file system
+type fileEngine struct {
+	sync.Mutex
+	dir    string
+	opts   Options
+	logs   map[string]Log
+	closed bool
+}
+
+// Open returns the log with the given name, creating it if it does not exist
+func (e *fileEngine) Open(name string) (Log, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return nil, ErrEngineClosed
+	}
+
+	// Return the cached log
+	if log, ok := e.logs[name]; ok {
+		return log, nil
+	}
+
+	log, err := OpenLog(path.Join(e.dir, name), e.opts)
+	if err != nil {
+		return nil, err
+	}
+	e.logs[name] = log
+	return log, nil
+}
+
+// Drop closes a log and removes all of its segments
+func (e *fileEngine) Drop(name string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	// Close the log if it is open
+	if log, ok := e.logs[name]; ok {
+		log.Close()
+		delete(e.logs, name)
+	}
+	return os.RemoveAll(path.Join(e.dir, name))
+}
+
+// Close closes all open logs
+func (e *fileEngine) Close() (err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.closed = true
+	for name, log := range e.logs {
+		if closeErr := log.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(e.logs, name)
+	}
+	return
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size in bytes at which segments are rolled if no size is configured
+const DefaultSegmentSize int64 = 64 * 1024 * 1024
+
+var (
+
+	// ErrCorruptSegment is returned when a segment contains an invalid record
+	ErrCorruptSegment = errors.New("corrupt log segment")
+
+	// ErrLogClosed is returned when an operation is attempted on a closed log
+	ErrLogClosed = errors.New("log is closed")
+)
+
+// Options configures a Log
+type Options struct {
+
+	// SegmentSize is the maximum size in bytes of a segment before a new segment is started
+	SegmentSize int64
+}
+
+// Record is a single entry in a Log
+type Record struct {
+	Offset    uint64
+	Timestamp time.Time
+	Data      []byte
+}
+
+// Log is an append-only sequence of records. Each record is assigned a monotonically increasing offset.
+type Log interface {
+
+	// Append writes records to the end of the log and returns their offsets
+	Append(data ...[]byte) ([]uint64, error)
+
+	// Scan calls fn for each record starting at offset until fn returns false or the end of the log is reached
+	Scan(offset uint64, fn func(Record) bool) error
+
+	// OldestOffset returns the offset of the first record in the log
+	OldestOffset() uint64
+
+	// NextOffset returns the offset which will be assigned to the next record
+	NextOffset() uint64
+
+	// Segments returns the number of segments in the log
+	Segments() int
+
+	// Size returns the total size in bytes of all segments
+	Size() int64
+
+	// Notify returns a channel which is closed the next time records are appended or the log is closed.
+	// Callers should get the channel before scanning so appends made during the scan are not missed.
+	Notify() <-chan struct{}
+
+	// Close syncs and closes all segments
+	Close() error
+}
+
+// OpenLog opens or creates a segmented log in the given directory
+func OpenLog(dir string, opts Options) (Log, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+
+	// Create log directory
+	if err := os.MkdirAll(dir, os.ModeDir|0755); err != nil {
+		return nil, err
+	}
+
+	// Find existing segments
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var bases []uint64
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), segmentExt) {
+			continue
+		}
+
+		base, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Sort(offsets(bases))
+
+	l := &segmentedLog{dir: dir, opts: opts, notify: make(chan struct{})}
+
+	// Open segments, only the last segment may be repaired
+	for i, base := range bases {
+		seg, err := openSegment(path.Join(dir, segmentName(base)), base, i == len(bases)-1)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+
+		// Segments must be contiguous
+		if i > 0 && l.active().next != base {
+			seg.close()
+			l.Close()
+			return nil, ErrCorruptSegment
+		}
+		l.segments = append(l.segments, seg)
+	}
+
+	// Create the first segment for a new log
+	if len(l.segments) == 0 {
+		seg, err := createSegment(path.Join(dir, segmentName(0)), 0)
+		if err != nil {
+			return nil, err
+		}
+		l.segments = append(l.segments, seg)
+	}
+	return l, nil
+}
+
+// segmentedLog implements the Log interface on top of a directory of segment files
+type segmentedLog struct {
+	sync.RWMutex
+	dir      string
+	opts     Options
+	segments []*segment
+	notify   chan struct{}
+	closed   bool
+}
+
+// active returns the segment currently being written to
+func (l *segmentedLog) active() *segment {
+	return l.segments[len(l.segments)-1]
+}
+
+// roll starts a new segment at the next offset
+func (l *segmentedLog) roll() error {
+	current := l.active()
+	if err := current.file.Sync(); err != nil {
+		return err
+	}
+
+	seg, err := createSegment(path.Join(l.dir, segmentName(current.next)), current.next)
+	if err != nil {
+		return err
+	}
+	l.segments = append(l.segments, seg)
+	return nil
+}
+
+// Append writes records to the end of the log and returns their offsets
+func (l *segmentedLog) Append(data ...[]byte) (offsets []uint64, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		return nil, ErrLogClosed
+	}
+
+	// Wake readers if any records were written, even if a later record failed
+	defer func() {
+		if len(offsets) > 0 {
+			l.wake()
+		}
+	}()
+
+	now := time.Now()
+	for _, d := range data {
+
+		// Roll the segment if the record will not fit. Empty segments always accept a record.
+		if seg := l.active(); seg.size > 0 && seg.size+int64(headerSize+len(d)) > l.opts.SegmentSize {
+			if err = l.roll(); err != nil {
+				return
+			}
+		}
+
+		seg := l.active()
+		offset := seg.next
+		if err = seg.append(offset, now, d); err != nil {
+			return
+		}
+		offsets = append(offsets, offset)
+	}
+
+	err = l.active().file.Sync()
+	return
+}
+
+// Notify returns a channel which is closed the next time records are appended or the log is closed
+func (l *segmentedLog) Notify() <-chan struct{} {
+	l.RLock()
+	defer l.RUnlock()
+	return l.notify
+}
+
+// wake notifies waiting readers and replaces the notification channel. The write lock must be held.
+func (l *segmentedLog) wake() {
+	close(l.notify)
+	if !l.closed {
+		l.notify = make(chan struct{})
+	}
+}
+
+// Scan calls fn for each record starting at offset until fn returns false or the end of the log is reached
+func (l *segmentedLog) Scan(offset uint64, fn func(Record) bool) error {
+
+	// Snapshot the segments so appends are not blocked while reading
+	l.RLock()
+	if l.closed {
+		l.RUnlock()
+		return ErrLogClosed
+	}
+
+	var views []segmentView
+	for _, seg := range l.segments {
+		if seg.next > offset {
+			views = append(views, segmentView{seg, seg.size, seg.index})
+		}
+	}
+	l.RUnlock()
+
+	for _, view := range views {
+		if more, err := view.scan(offset, fn); err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// OldestOffset returns the offset of the first record in the log
+func (l *segmentedLog) OldestOffset() uint64 {
+	l.RLock()
+	defer l.RUnlock()
+	return l.segments[0].base
+}
+
+// NextOffset returns the offset which will be assigned to the next record
+func (l *segmentedLog) NextOffset() uint64 {
+	l.RLock()
+	defer l.RUnlock()
+	return l.active().next
+}
+
+// Segments returns the number of segments in the log
+func (l *segmentedLog) Segments() int {
+	l.RLock()
+	defer l.RUnlock()
+	return len(l.segments)
+}
+
+// Size returns the total size in bytes of all segments
+func (l *segmentedLog) Size() (size int64) {
+	l.RLock()
+	defer l.RUnlock()
+	for _, seg := range l.segments {
+		size += seg.size
+	}
+	return
+}
+
+// Close syncs and closes all segments
+func (l *segmentedLog) Close() (err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	l.wake()
+
+	for _, seg := range l.segments {
+		if e := seg.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// offsets implements sort.Interface for segment base offsets
+type offsets []uint64
+
+func (o offsets) Len() int           { return len(o) }
+func (o offsets) Less(i, j int) bool { return o[i] < o[j] }
+func (o offsets) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
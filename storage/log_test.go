@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// TestLogTestSuite runs the LogTestSuite
+func TestLogTestSuite(t *testing.T) {
+	suite.Run(t, new(LogTestSuite))
+}
+
+// LogTestSuite tests the segmented log and storage engine
+type LogTestSuite struct {
+	suite.Suite
+	Dir string
+}
+
+// SetupTest prepares each test before execution
+func (suite *LogTestSuite) SetupTest() {
+
+	// Create temp directory
+	suite.Dir, _ = ioutil.TempDir("", "storage.test")
+}
+
+// TearDownTest cleans up after each test
+func (suite *LogTestSuite) TearDownTest() {
+
+	// Clear test directory
+	os.RemoveAll(suite.Dir)
+}
+
+// readAll returns every record from the given offset
+func (suite *LogTestSuite) readAll(log Log, offset uint64) (records []Record) {
+	err := log.Scan(offset, func(rec Record) bool {
+		records = append(records, rec)
+		return true
+	})
+	suite.Nil(err)
+	return
+}
+
+// TestAppend ensures offsets are assigned sequentially
+func (suite *LogTestSuite) TestAppend() {
+	log, err := OpenLog(path.Join(suite.Dir, "acme.events"), Options{})
+	suite.Nil(err)
+	defer log.Close()
+
+	suite.Equal(uint64(0), log.OldestOffset())
+	suite.Equal(uint64(0), log.NextOffset())
+
+	offsets, err := log.Append([]byte("a"), []byte("b"))
+	suite.Nil(err)
+	suite.Equal([]uint64{0, 1}, offsets)
+
+	offsets, err = log.Append([]byte("c"))
+	suite.Nil(err)
+	suite.Equal([]uint64{2}, offsets)
+	suite.Equal(uint64(3), log.NextOffset())
+
+	// Read records back
+	records := suite.readAll(log, 0)
+	suite.Len(records, 3)
+	for i, rec := range records {
+		suite.Equal(uint64(i), rec.Offset)
+		suite.False(rec.Timestamp.IsZero())
+	}
+	suite.Equal("c", string(records[2].Data))
+}
+
+// TestScanFromOffset ensures scans can start and stop in the middle of the log
+func (suite *LogTestSuite) TestScanFromOffset() {
+	log, err := OpenLog(path.Join(suite.Dir, "acme.events"), Options{SegmentSize: 128})
+	suite.Nil(err)
+	defer log.Close()
+
+	for i := 0; i < 100; i++ {
+		_, err := log.Append([]byte(fmt.Sprintf("record %d", i)))
+		suite.Nil(err)
+	}
+
+	// Start in the middle
+	records := suite.readAll(log, 42)
+	suite.Len(records, 58)
+	suite.Equal(uint64(42), records[0].Offset)
+	suite.Equal("record 42", string(records[0].Data))
+
+	// Stop early
+	var count int
+	log.Scan(0, func(rec Record) bool {
+		count++
+		return count < 10
+	})
+	suite.Equal(10, count)
+
+	// Past the end
+	suite.Len(suite.readAll(log, 100), 0)
+}
+
+// TestSegmentRoll ensures segments are rolled at the configured size
+func (suite *LogTestSuite) TestSegmentRoll() {
+	dir := path.Join(suite.Dir, "acme.events")
+	log, err := OpenLog(dir, Options{SegmentSize: 100})
+	suite.Nil(err)
+	defer log.Close()
+
+	// Each record is 24 + 26 = 50 bytes, so two fit in a segment
+	for i := 0; i < 5; i++ {
+		_, err := log.Append([]byte("abcdefghijklmnopqrstuvwxyz"))
+		suite.Nil(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	suite.Nil(err)
+	suite.Len(files, 3)
+	suite.Equal(segmentName(0), files[0].Name())
+	suite.Equal(segmentName(2), files[1].Name())
+	suite.Equal(segmentName(4), files[2].Name())
+	suite.Equal(3, log.Segments())
+	suite.Equal(int64(250), log.Size())
+
+	// Records larger than a segment still get written
+	offsets, err := log.Append(make([]byte, 200))
+	suite.Nil(err)
+	suite.Equal([]uint64{5}, offsets)
+	suite.Len(suite.readAll(log, 0), 6)
+}
+
+// TestReopen ensures offsets continue after a log is reopened
+func (suite *LogTestSuite) TestReopen() {
+	dir := path.Join(suite.Dir, "acme.events")
+	log, err := OpenLog(dir, Options{SegmentSize: 100})
+	suite.Nil(err)
+
+	for i := 0; i < 5; i++ {
+		_, err := log.Append([]byte(fmt.Sprintf("record %d", i)))
+		suite.Nil(err)
+	}
+	suite.Nil(log.Close())
+
+	// Closed logs return an error
+	_, err = log.Append([]byte("closed"))
+	suite.Equal(ErrLogClosed, err)
+
+	// Reopen the log
+	log, err = OpenLog(dir, Options{SegmentSize: 100})
+	suite.Nil(err)
+	defer log.Close()
+	suite.Equal(uint64(5), log.NextOffset())
+
+	offsets, err := log.Append([]byte("record 5"))
+	suite.Nil(err)
+	suite.Equal([]uint64{5}, offsets)
+
+	records := suite.readAll(log, 0)
+	suite.Len(records, 6)
+	suite.Equal("record 5", string(records[5].Data))
+}
+
+// TestRepairTail ensures a partially written record at the end of the log is truncated
+func (suite *LogTestSuite) TestRepairTail() {
+	dir := path.Join(suite.Dir, "acme.events")
+	log, err := OpenLog(dir, Options{})
+	suite.Nil(err)
+
+	_, err = log.Append([]byte("first"), []byte("second"))
+	suite.Nil(err)
+	suite.Nil(log.Close())
+
+	// Simulate a torn write
+	file, err := os.OpenFile(path.Join(dir, segmentName(0)), os.O_WRONLY|os.O_APPEND, 0644)
+	suite.Nil(err)
+	file.Write([]byte{0, 0, 0, 0, 0, 0, 0, 2, 1, 2, 3})
+	file.Close()
+
+	log, err = OpenLog(dir, Options{})
+	suite.Nil(err)
+	defer log.Close()
+	suite.Equal(uint64(2), log.NextOffset())
+
+	offsets, err := log.Append([]byte("third"))
+	suite.Nil(err)
+	suite.Equal([]uint64{2}, offsets)
+	suite.Len(suite.readAll(log, 0), 3)
+}
+
+// TestCorruptSegment ensures checksum failures in sealed segments are reported
+func (suite *LogTestSuite) TestCorruptSegment() {
+	dir := path.Join(suite.Dir, "acme.events")
+	log, err := OpenLog(dir, Options{SegmentSize: 50})
+	suite.Nil(err)
+
+	_, err = log.Append([]byte("first"), []byte("second"))
+	suite.Nil(err)
+	suite.Nil(log.Close())
+
+	// Flip a byte in the first record's data
+	file, err := os.OpenFile(path.Join(dir, segmentName(0)), os.O_WRONLY, 0644)
+	suite.Nil(err)
+	file.WriteAt([]byte("F"), headerSize)
+	file.Close()
+
+	_, err = OpenLog(dir, Options{SegmentSize: 50})
+	suite.Equal(ErrCorruptSegment, err)
+}
+
+// TestNotify ensures readers are woken when records are appended or the log is closed
+func (suite *LogTestSuite) TestNotify() {
+	log, err := OpenLog(path.Join(suite.Dir, "acme.events"), Options{})
+	suite.Nil(err)
+
+	notify := log.Notify()
+	select {
+	case <-notify:
+		suite.Fail("notified before append")
+	default:
+	}
+
+	_, err = log.Append([]byte("a"))
+	suite.Nil(err)
+	select {
+	case <-notify:
+	case <-time.After(time.Second):
+		suite.Fail("not notified after append")
+	}
+
+	// A new channel is returned after each append
+	notify = log.Notify()
+	suite.Nil(log.Close())
+	select {
+	case <-notify:
+	case <-time.After(time.Second):
+		suite.Fail("not notified after close")
+	}
+}
+
+// TestEngine ensures logs can be opened and dropped through an Engine
+func (suite *LogTestSuite) TestEngine() {
+	engine := NewEngine(suite.Dir, Options{})
+
+	log, err := engine.Open("acme.events")
+	suite.Nil(err)
+	_, err = log.Append([]byte("a"))
+	suite.Nil(err)
+
+	// The same log is returned
+	same, err := engine.Open("acme.events")
+	suite.Nil(err)
+	suite.Equal(uint64(1), same.NextOffset())
+
+	// Drop removes the segments
+	suite.Nil(engine.Drop("acme.events"))
+	_, err = os.Stat(path.Join(suite.Dir, "acme.events"))
+	suite.True(os.IsNotExist(err))
+
+	log, err = engine.Open("acme.events")
+	suite.Nil(err)
+	suite.Equal(uint64(0), log.NextOffset())
+
+	// Closed engines do not open logs
+	suite.Nil(engine.Close())
+	_, err = engine.Open("acme.events")
+	suite.Equal(ErrEngineClosed, err)
+}
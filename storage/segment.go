@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+
+	// headerSize is the size of the fixed record header.
+	// Each record is laid out as: offset (8) | timestamp (8) | length (4) | crc (4) | data
+	headerSize = 24
+
+	// indexInterval is the number of bytes between sparse index entries
+	indexInterval = 4096
+
+	// segmentExt is the file extension for segment files
+	segmentExt = ".log"
+)
+
+// crcTable is used to checksum every record
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// indexEntry maps a record offset to its byte position in a segment
+type indexEntry struct {
+	offset   uint64
+	position int64
+}
+
+// segment is a single file of a segmented log. The file name is the offset of the first record in the segment.
+type segment struct {
+	base        uint64
+	next        uint64
+	size        int64
+	file        *os.File
+	index       []indexEntry
+	lastIndexed int64
+}
+
+// segmentName returns the file name for a segment starting at the given offset
+func segmentName(base uint64) string {
+	return fmt.Sprintf("%020d%s", base, segmentExt)
+}
+
+// createSegment creates a new, empty segment file
+func createSegment(filename string, base uint64) (*segment, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{base: base, next: base, file: file}, nil
+}
+
+// openSegment opens an existing segment file and rebuilds its index. If repair is true, a partially written
+// or corrupt tail is truncated. Otherwise ErrCorruptSegment is returned.
+func openSegment(filename string, base uint64, repair bool) (*segment, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &segment{base: base, next: base, file: file}
+	if err := s.recover(repair); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover scans every record in the segment, validating offsets and checksums
+func (s *segment) recover(repair bool) error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var position int64
+	for {
+		rec, n, err := s.readAt(position, info.Size())
+		if err == io.EOF {
+			break
+		} else if err == nil && rec.Offset != s.next {
+			err = ErrCorruptSegment
+		}
+
+		if err != nil {
+			if !repair {
+				return ErrCorruptSegment
+			}
+
+			// Truncate the partial or corrupt tail
+			if err := s.file.Truncate(position); err != nil {
+				return err
+			}
+			break
+		}
+
+		s.track(rec.Offset, position)
+		position += n
+		s.next++
+	}
+	s.size = position
+	return nil
+}
+
+// track adds a sparse index entry if enough bytes have been written since the last entry
+func (s *segment) track(offset uint64, position int64) {
+	if len(s.index) == 0 || position-s.lastIndexed >= indexInterval {
+		s.index = append(s.index, indexEntry{offset, position})
+		s.lastIndexed = position
+	}
+}
+
+// append writes a record to the end of the segment
+func (s *segment) append(offset uint64, timestamp time.Time, data []byte) error {
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], offset)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(timestamp.UnixNano()))
+	binary.BigEndian.PutUint32(buf[16:20], uint32(len(data)))
+	copy(buf[headerSize:], data)
+
+	// Checksum everything but the checksum itself
+	crc := crc32.Update(crc32.Checksum(buf[0:20], crcTable), crcTable, data)
+	binary.BigEndian.PutUint32(buf[20:24], crc)
+
+	if _, err := s.file.WriteAt(buf, s.size); err != nil {
+		return err
+	}
+
+	s.track(offset, s.size)
+	s.size += int64(len(buf))
+	s.next = offset + 1
+	return nil
+}
+
+// readAt reads the record at the given byte position. Records may not extend past limit. It returns the record,
+// the number of bytes it occupies and io.EOF if there are no more records.
+func (s *segment) readAt(position, limit int64) (rec Record, n int64, err error) {
+	header := make([]byte, headerSize)
+	if read, e := s.file.ReadAt(header, position); e == io.EOF && read == 0 {
+		return rec, 0, io.EOF
+	} else if e != nil {
+		return rec, 0, io.ErrUnexpectedEOF
+	}
+
+	// A length running past the end of the segment is a partial write
+	length := binary.BigEndian.Uint32(header[16:20])
+	if position+headerSize+int64(length) > limit {
+		return rec, 0, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, length)
+	if _, e := s.file.ReadAt(data, position+headerSize); e != nil {
+		return rec, 0, io.ErrUnexpectedEOF
+	}
+
+	// Verify checksum
+	crc := crc32.Update(crc32.Checksum(header[0:20], crcTable), crcTable, data)
+	if crc != binary.BigEndian.Uint32(header[20:24]) {
+		return rec, 0, ErrCorruptSegment
+	}
+
+	rec.Offset = binary.BigEndian.Uint64(header[0:8])
+	rec.Timestamp = time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16])))
+	rec.Data = data
+	return rec, headerSize + int64(length), nil
+}
+
+// close syncs and closes the segment file
+func (s *segment) close() error {
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// segmentView is a point-in-time snapshot of a segment which can be read without holding the log lock
+type segmentView struct {
+	seg   *segment
+	size  int64
+	index []indexEntry
+}
+
+// seek returns the byte position of the closest indexed record at or before the given offset
+func (v segmentView) seek(offset uint64) int64 {
+	i := sort.Search(len(v.index), func(i int) bool {
+		return v.index[i].offset > offset
+	})
+	if i == 0 {
+		return 0
+	}
+	return v.index[i-1].position
+}
+
+// scan calls fn for every record at or after offset. It returns false if fn stopped the scan.
+func (v segmentView) scan(offset uint64, fn func(Record) bool) (bool, error) {
+	for position := v.seek(offset); position < v.size; {
+		rec, n, err := v.seg.readAt(position, v.size)
+		if err != nil {
+			return false, err
+		}
+		position += n
+
+		// Skip records before the requested offset
+		if rec.Offset < offset {
+			continue
+		}
+
+		if !fn(rec) {
+			return false, nil
+		}
+	}
+	return true, nil
+}